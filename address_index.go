@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"log"
+)
+
+// 地址索引：按地址哈希（公钥hash或脚本hash）查询未花费输出和相关交易，不需要扫描整个utxo集合
+// addrutxo桶：地址哈希+txid+输出索引 -> 输出；addrhistory桶：地址哈希+高度+交易在区块中的位置 -> txid
+// 两个桶都以地址哈希为前缀，按前缀遍历；随链状态在连接、断开区块时更新
+
+const addrUTXOBucket = "addrutxo"
+const addrHistoryBucket = "addrhistory"
+
+// AddressIndex maps address hashes to their unspent outputs and transactions
+type AddressIndex struct {
+	Blockchain *Blockchain
+}
+
+// 涉及地址的交易：花费了地址的输出，或有输出锁定到地址
+type AddressTx struct {
+	Txid   []byte
+	Height int
+}
+
+// 地址哈希的键前缀：长度+哈希，不同长度的哈希互不为前缀
+func addrPrefix(hash []byte) []byte {
+	return append([]byte{byte(len(hash))}, hash...)
+}
+
+func addrUTXOKey(hash, txID []byte, vout int) []byte {
+	key := append(addrPrefix(hash), txID...)
+	return appendUint32(key, uint32(vout))
+}
+
+func addrHistoryKey(hash []byte, height, position int) []byte {
+	key := append(addrPrefix(hash), heightKey(height)...)
+	return appendUint32(key, uint32(position))
+}
+
+func serializeOutput(out TXOutput) []byte {
+	var buff bytes.Buffer
+
+	err := gob.NewEncoder(&buff).Encode(out)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return buff.Bytes()
+}
+
+func deserializeOutput(data []byte) TXOutput {
+	var out TXOutput
+
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&out)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return out
+}
+
+// 查询地址哈希的未花费输出，按txid和输出索引排序
+func (a AddressIndex) UnspentOutputs(hash []byte) []UnspentOutput {
+	var utxos []UnspentOutput
+	prefix := addrPrefix(hash)
+
+	err := a.Blockchain.db.ForEachPrefix(addrUTXOBucket, prefix, func(key, value []byte) error {
+		txID := key[len(prefix) : len(key)-4]
+		vout := int(binary.BigEndian.Uint32(key[len(key)-4:]))
+		utxos = append(utxos, UnspentOutput{txID, vout, deserializeOutput(value)})
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return utxos
+}
+
+// 查询涉及地址哈希的主链交易，按区块高度和在区块中的位置排序
+func (a AddressIndex) History(hash []byte) []AddressTx {
+	var history []AddressTx
+	prefix := addrPrefix(hash)
+
+	err := a.Blockchain.db.ForEachPrefix(addrHistoryBucket, prefix, func(key, value []byte) error {
+		height := int(binary.BigEndian.Uint64(key[len(prefix):]))
+		history = append(history, AddressTx{value, height})
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return history
+}
+
+// 重建地址索引：未花费输出来自utxo集合，交易记录按高度遍历主链区块得到
+func (a AddressIndex) Reindex() {
+	bc := a.Blockchain
+	batch := &WriteBatch{}
+	batch.DeleteBucket(addrUTXOBucket)
+	batch.DeleteBucket(addrHistoryBucket)
+
+	err := bc.db.ForEachUTXO(func(txID []byte, outs TXOutputs) error {
+		for outIdx, out := range outs.Outputs {
+			if hash := extractLockHash(out.ScriptPubKey); hash != nil {
+				batch.Put(addrUTXOBucket, addrUTXOKey(hash, txID, outs.Indexes[outIdx]), serializeOutput(out))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	//交易的每个输出锁定到的地址哈希，用于查找输入花费的地址
+	lockHashes := make(map[string][][]byte)
+	for height := 0; height <= bc.GetBestHeight(); height++ {
+		block, err := bc.GetBlockByHeight(height)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		for position, tx := range block.Transactions {
+			if !tx.IsCoinbase() {
+				for _, vin := range tx.Vin {
+					hashes := lockHashes[hex.EncodeToString(vin.Txid)]
+					if vin.Vout < len(hashes) && hashes[vin.Vout] != nil {
+						batch.Put(addrHistoryBucket, addrHistoryKey(hashes[vin.Vout], height, position), tx.ID)
+					}
+				}
+			}
+
+			hashes := make([][]byte, len(tx.Vout))
+			for outIdx, out := range tx.Vout {
+				hashes[outIdx] = extractLockHash(out.ScriptPubKey)
+				if hashes[outIdx] != nil {
+					batch.Put(addrHistoryBucket, addrHistoryKey(hashes[outIdx], height, position), tx.ID)
+				}
+			}
+			lockHashes[hex.EncodeToString(tx.ID)] = hashes
+		}
+	}
+
+	err = bc.db.Write(batch)
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// 把区块对地址索引的修改加入批量写，spent为区块花费的输出（撤销数据）
+func (a AddressIndex) connect(batch *WriteBatch, block *Block, spent []SpentOutput) {
+	spentOutputs := spentOutputMap(spent)
+
+	for position, tx := range block.Transactions {
+		if !tx.IsCoinbase() {
+			for _, vin := range tx.Vin {
+				out, ok := spentOutputs[outPoint(vin.Txid, vin.Vout)]
+				if !ok {
+					continue
+				}
+				if hash := extractLockHash(out.ScriptPubKey); hash != nil {
+					batch.Delete(addrUTXOBucket, addrUTXOKey(hash, vin.Txid, vin.Vout))
+					batch.Put(addrHistoryBucket, addrHistoryKey(hash, block.Height, position), tx.ID)
+				}
+			}
+		}
+
+		for outIdx, out := range tx.Vout {
+			hash := extractLockHash(out.ScriptPubKey)
+			if hash == nil {
+				continue
+			}
+			if out.IsSpendable() {
+				batch.Put(addrUTXOBucket, addrUTXOKey(hash, tx.ID, outIdx), serializeOutput(out))
+			}
+			batch.Put(addrHistoryBucket, addrHistoryKey(hash, block.Height, position), tx.ID)
+		}
+	}
+}
+
+// 撤销区块对地址索引的修改：逆序删除区块中交易的输出和记录，恢复区块花费的输出
+func (a AddressIndex) disconnect(batch *WriteBatch, block *Block, spent []SpentOutput) {
+	spentOutputs := spentOutputMap(spent)
+
+	for position := len(block.Transactions) - 1; position >= 0; position-- {
+		tx := block.Transactions[position]
+		for outIdx, out := range tx.Vout {
+			if hash := extractLockHash(out.ScriptPubKey); hash != nil {
+				batch.Delete(addrUTXOBucket, addrUTXOKey(hash, tx.ID, outIdx))
+				batch.Delete(addrHistoryBucket, addrHistoryKey(hash, block.Height, position))
+			}
+		}
+
+		if tx.IsCoinbase() {
+			continue
+		}
+		for _, vin := range tx.Vin {
+			out, ok := spentOutputs[outPoint(vin.Txid, vin.Vout)]
+			if !ok {
+				continue
+			}
+			if hash := extractLockHash(out.ScriptPubKey); hash != nil {
+				batch.Put(addrUTXOBucket, addrUTXOKey(hash, vin.Txid, vin.Vout), serializeOutput(out))
+				batch.Delete(addrHistoryBucket, addrHistoryKey(hash, block.Height, position))
+			}
+		}
+	}
+}
+
+func spentOutputMap(spent []SpentOutput) map[string]TXOutput {
+	outputs := make(map[string]TXOutput)
+	for _, s := range spent {
+		outputs[outPoint(s.Txid, s.Vout)] = s.Output
+	}
+
+	return outputs
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddressIndex(t *testing.T) {
+	wallet := NewWallet()
+	other := NewWallet()
+	bc := newTestBlockchain(t, wallet)
+	genesis := bc.Iterator().Next()
+	index := AddressIndex{bc}
+	pubKeyHash := HashPubKey(wallet.PublicKey)
+	otherHash := HashPubKey(other.PublicKey)
+
+	//花费创世块的输出，找零给自己，其余付给other
+	spend := newTestSpend(wallet, genesis.Transactions[0], 0, []int{4, 6}, SequenceFinal)
+	spend.Vout[1] = *NewTXOutput(6, string(other.GetAddress()))
+	spend.ID = spend.Hash()
+	spend.Sign(wallet.PrivateKey, map[string]Transaction{hexID(genesis.Transactions[0]): *genesis.Transactions[0]})
+	block := bc.MineBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), ""), spend})
+
+	utxos := index.UnspentOutputs(otherHash)
+	assert.Equal(t, []UnspentOutput{{spend.ID, 1, spend.Vout[1]}}, utxos)
+	assert.Equal(t, 4+subsidy, sumUnspentOutputs(UTXOSet{bc}.FindUnspentOutputs(pubKeyHash)))
+
+	history := index.History(pubKeyHash)
+	assert.Equal(t, []AddressTx{{genesis.Transactions[0].ID, 0}, {block.Transactions[0].ID, 1}, {spend.ID, 1}}, history)
+	assert.Equal(t, []AddressTx{{spend.ID, 1}}, index.History(otherHash))
+	assertChainstate(t, bc)
+
+	//链重组断开区块后，花费的输出恢复，交易记录删除
+	fork := NewBlock([]*Transaction{NewCoinbaseTX(string(other.GetAddress()), "fork")}, genesis.Hash, 1)
+	bc.AddBlock(fork)
+	bc.AddBlock(NewBlock([]*Transaction{NewCoinbaseTX(string(other.GetAddress()), "next")}, fork.Hash, 2))
+	assert.Equal(t, subsidy, sumUnspentOutputs(index.UnspentOutputs(pubKeyHash)))
+	assert.Equal(t, []AddressTx{{genesis.Transactions[0].ID, 0}}, index.History(pubKeyHash))
+	assert.Len(t, index.History(otherHash), 2)
+	assertChainstate(t, bc)
+}
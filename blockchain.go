@@ -2,24 +2,26 @@ package main
 
 import (
 	"bytes"
-	"crypto/ecdsa"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"log"
 	"os"
-
-	"github.com/boltdb/bolt"
+	"time"
 )
 
 const dbFile = "blockchain_%s.db" //数据文件
 const blocksBucket = "blocks"
 const genesisCoinbaseData = "The Times 03/Jan/2009 Chancellor on brink of second bailout for banks"
 
+// 旧格式的区块链数据库：交易输出以公钥哈希字段锁定，按当前格式解码后锁定脚本为空
+// 交易ID和签名都随输出格式改变，无法迁移
+var ErrLegacyChainFormat = errors.New("blockchain database was created by an older version that locks outputs to a public key hash field and cannot be migrated to scripts; remove the database file, then create the blockchain again or sync it from a node")
+
 //区块链
 type Blockchain struct {
-	tip []byte   //当前区块hash
-	db  *bolt.DB //db
+	tip []byte     //当前区块hash
+	db  ChainStore //db
 }
 
 // 创建区块链
@@ -32,41 +34,32 @@ func CreateBlockchain(address, nodeID string) *Blockchain {
 		os.Exit(1)
 	}
 
-	var tip []byte
-	//coinbase交易
-	cbtx := NewCoinbaseTX(address, genesisCoinbaseData)
-	genesis := NewGenesisBlock(cbtx) //创世块
-
-	db, err := bolt.Open(dbFile, 0600, nil)
+	db, err := NewBoltStore(dbFile)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	err = db.Update(func(tx *bolt.Tx) error {
-		b, err := tx.CreateBucket([]byte(blocksBucket))
-		if err != nil {
-			log.Panic(err)
-		}
-
-		err = b.Put(genesis.Hash, genesis.Serialize())
-		if err != nil {
-			log.Panic(err)
-		}
+	return CreateBlockchainWithStore(db, address)
+}
 
-		err = b.Put([]byte("l"), genesis.Hash)
-		if err != nil {
-			log.Panic(err)
-		}
-		tip = genesis.Hash
+// 在指定的存储中创建区块链，创世块奖励给address
+func CreateBlockchainWithStore(db ChainStore, address string) *Blockchain {
+	//coinbase交易
+	cbtx := NewCoinbaseTX(address, genesisCoinbaseData)
+	genesis := NewGenesisBlock(cbtx) //创世块
 
-		return nil
-	})
+	bc := Blockchain{genesis.Hash, db}
+	//创世块和链状态一起写入
+	batch := &WriteBatch{}
+	batch.PutBlock(genesis)
+	batch.SetTip(genesis)
+	bc.connectChainstate(batch, genesis)
+	batch.Put(metaBucket, []byte(chainstateKey), genesis.Hash)
+	err := db.Write(batch)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	bc := Blockchain{tip, db}
-
 	return &bc
 }
 
@@ -78,64 +71,144 @@ func NewBlockchain(nodeID string) *Blockchain {
 		os.Exit(1)
 	}
 
-	var tip []byte
-	db, err := bolt.Open(dbFile, 0600, nil)
+	db, err := NewBoltStore(dbFile)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	err = db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blocksBucket))
-		tip = b.Get([]byte("l"))
+	return NewBlockchainWithStore(db)
+}
 
+// 由已有区块链的存储加载区块链，链状态与链尾不一致时先修复
+func NewBlockchainWithStore(db ChainStore) *Blockchain {
+	if err := checkChainFormat(db); err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	bc := Blockchain{db.Tip(), db}
+	bc.checkChainstate()
+
+	return &bc
+}
+
+// 检查数据库是否为旧格式：没有meta桶（链状态元数据），且链尾区块中有锁定脚本为空的输出
+// 当前版本创建的输出都有锁定脚本
+func checkChainFormat(db ChainStore) error {
+	tip := db.Tip()
+	if tip == nil || !bucketEmpty(db, metaBucket) {
 		return nil
-	})
+	}
+	block, err := db.GetBlock(tip)
 	if err != nil {
-		log.Panic(err)
+		return err
+	}
+	for _, tx := range block.Transactions {
+		for _, out := range tx.Vout {
+			if len(out.ScriptPubKey) == 0 {
+				return ErrLegacyChainFormat
+			}
+		}
 	}
 
-	bc := Blockchain{tip, db}
-
-	return &bc
+	return nil
 }
 
 // AddBlock saves the block into the blockchain
-func (bc *Blockchain) AddBlock(block *Block) {
-	err := bc.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blocksBucket))
-		blockInDb := b.Get(block.Hash)
-
-		if blockInDb != nil {
-			return nil
+// 返回新连接到主链的区块和从主链断开的区块（均从链尾向前），链尾不变时为空
+func (bc *Blockchain) AddBlock(block *Block) ([]*Block, []*Block) {
+	//区块中的交易须在该区块的高度和时间已生效，数据输出不超过大小限制
+	for _, tx := range block.Transactions {
+		if !tx.IsFinal(block.Height, block.Timestamp) {
+			fmt.Printf("Rejected block %x: transaction %x is not final\n", block.Hash, tx.ID)
+			return nil, nil
 		}
+		if err := tx.CheckDataOutputs(); err != nil {
+			fmt.Printf("Rejected block %x: transaction %x: %s\n", block.Hash, tx.ID, err)
+			return nil, nil
+		}
+	}
+	if _, err := bc.db.GetBlock(block.Hash); err == nil {
+		return nil, nil
+	}
+	//父区块尚未连接时作为孤块保存，连接父区块时再校验
+	if !bc.isConnected(block.PrevBlockHash) {
+		bc.addOrphan(block)
+		fmt.Printf("Stored orphan block %x, waiting for its parent\n", block.Hash)
+		return nil, nil
+	}
+	//按区块连接到的分支（可能是侧链）校验相对时间锁
+	if err := bc.checkBlockLocks(block); err != nil {
+		fmt.Printf("Rejected block %x: %s\n", block.Hash, err)
+		return nil, nil
+	}
+	bc.writeBlock(block, false)
 
-		blockData := block.Serialize()
-		err := b.Put(block.Hash, blockData)
+	//高度更高的分支成为链尾，链状态随之更新（必要时断开旧分支上的区块）
+	best := bc.connectOrphans(block)
+	lastBlock, err := bc.db.GetBlock(bc.db.Tip())
+	if err != nil {
+		log.Panic(err)
+	}
+	if best.Height <= lastBlock.Height {
+		return nil, nil
+	}
+	var connected []*Block
+	fork := best
+	for !bc.isMainChain(fork) {
+		connected = append(connected, fork)
+		fork, err = bc.db.GetBlock(fork.PrevBlockHash)
 		if err != nil {
 			log.Panic(err)
 		}
+	}
+	//旧主链上分叉点之后的区块将被断开
+	var disconnected []*Block
+	for b := lastBlock; b.Height > fork.Height; {
+		disconnected = append(disconnected, b)
+		b, err = bc.db.GetBlock(b.PrevBlockHash)
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+	//新分支的区块都已保存，链状态无法增量更新时（如缺少撤销数据）重建
+	if !bc.writeBlock(best, true) {
+		bc.Reindex()
+	}
 
-		lastHash := b.Get([]byte("l"))
-		lastBlockData := b.Get(lastHash)
-		lastBlock := DeserializeBlock(lastBlockData)
+	return connected, disconnected
+}
 
-		if block.Height > lastBlock.Height {
-			err = b.Put([]byte("l"), block.Hash)
-			if err != nil {
-				log.Panic(err)
-			}
-			bc.tip = block.Hash
+// 按区块连接到的分支校验其中交易的相对时间锁，父区块须已保存
+func (bc *Blockchain) checkBlockLocks(block *Block) error {
+	for _, tx := range block.Transactions {
+		if err := bc.checkTransactionLocksOnBranch(tx, block.PrevBlockHash, block.Height, block.Timestamp); err != nil {
+			return fmt.Errorf("transaction %x: %s", tx.ID, err)
 		}
-
-		return nil
-	})
-	if err != nil {
-		log.Panic(err)
 	}
+
+	return nil
 }
 
 // FindTransaction finds a transaction by its ID
 func (bc *Blockchain) FindTransaction(ID []byte) (Transaction, error) {
+	tx, _, err := bc.findTransactionBlock(ID)
+
+	return tx, err
+}
+
+// 查找交易及其所在的区块：启用了交易索引时直接读取，否则从链尾向前遍历
+func (bc *Blockchain) findTransactionBlock(ID []byte) (Transaction, *Block, error) {
+	if txIndex := (TxIndex{bc}); txIndex.Enabled() {
+		location, ok := txIndex.Find(ID)
+		if !ok {
+			return Transaction{}, nil, errors.New("Transaction is not found")
+		}
+		block, err := bc.db.GetBlock(location.BlockHash)
+		if err != nil {
+			return Transaction{}, nil, err
+		}
+		return *block.Transactions[location.Position], block, nil
+	}
+
 	bci := bc.Iterator()
 
 	for {
@@ -143,7 +216,7 @@ func (bc *Blockchain) FindTransaction(ID []byte) (Transaction, error) {
 
 		for _, tx := range block.Transactions {
 			if bytes.Compare(tx.ID, ID) == 0 {
-				return *tx, nil
+				return *tx, block, nil
 			}
 		}
 
@@ -152,7 +225,7 @@ func (bc *Blockchain) FindTransaction(ID []byte) (Transaction, error) {
 		}
 	}
 
-	return Transaction{}, errors.New("Transaction is not found")
+	return Transaction{}, nil, errors.New("Transaction is not found")
 }
 
 // 遍历所有区块，查找所有未花费的交易输出，并删除已花费的交易输出
@@ -163,8 +236,9 @@ func (bc *Blockchain) FindUTXO() map[string]TXOutputs {
 	//遍历所有区块
 	for {
 		block := bci.Next()
-		//遍历当前区块所有交易
-		for _, tx := range block.Transactions {
+		//倒序遍历当前区块所有交易：同一区块中子交易排在父交易之后，需先记录其花费
+		for i := len(block.Transactions) - 1; i >= 0; i-- {
+			tx := block.Transactions[i]
 			txID := hex.EncodeToString(tx.ID)
 			//遍历当前交易的所有交易输出
 		Outputs:
@@ -177,9 +251,14 @@ func (bc *Blockchain) FindUTXO() map[string]TXOutputs {
 						}
 					}
 				}
+				//数据输出不可花费，不进入utxo集合
+				if !out.IsSpendable() {
+					continue
+				}
 				//未花费
 				outs := UTXO[txID]
 				outs.Outputs = append(outs.Outputs, out)
+				outs.Indexes = append(outs.Indexes, outIdx)
 				UTXO[txID] = outs
 			}
 			//不是coinbase交易：交易输入中引用的交易输出，意味着这笔交易输出已经被花费
@@ -206,47 +285,19 @@ func (bc *Blockchain) Iterator() *BlockchainIterator {
 	return bci
 }
 
-// 查询区块最新高度
+// 查询区块最新高度，从链尾元数据读取，不需要反序列化区块
 func (bc *Blockchain) GetBestHeight() int {
-	var lastBlock Block
-
-	err := bc.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blocksBucket))
-		lastHash := b.Get([]byte("l"))
-		blockData := b.Get(lastHash)
-		lastBlock = *DeserializeBlock(blockData)
-
-		return nil
-	})
-	if err != nil {
-		log.Panic(err)
-	}
-
-	return lastBlock.Height
+	return bc.TipInfo().Height
 }
 
 // GetBlock finds a block by its hash and returns it
 func (bc *Blockchain) GetBlock(blockHash []byte) (Block, error) {
-	var block Block
-
-	err := bc.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blocksBucket))
-
-		blockData := b.Get(blockHash)
-
-		if blockData == nil {
-			return errors.New("Block is not found.")
-		}
-
-		block = *DeserializeBlock(blockData)
-
-		return nil
-	})
+	block, err := bc.db.GetBlock(blockHash)
 	if err != nil {
-		return block, err
+		return Block{}, err
 	}
 
-	return block, nil
+	return *block, nil
 }
 
 // 查询当前区块链的所有区块的hash
@@ -267,75 +318,63 @@ func (bc *Blockchain) GetBlockHashes() [][]byte {
 	return blocks
 }
 
-// 挖矿，产生新的区块
+// 挖矿，产生新的区块，同时更新utxo集合和数据索引
 func (bc *Blockchain) MineBlock(transactions []*Transaction) *Block {
-	var lastHash []byte
-	var lastHeight int
-	//校验所有交易是否合法
+	//获取当前链上的最新区块高度
+	lastHash := bc.db.Tip()
+	lastBlock, err := bc.db.GetBlock(lastHash)
+	if err != nil {
+		log.Panic(err)
+	}
+	lastHeight := lastBlock.Height
+	//校验所有交易是否合法，以及在新区块中是否已生效；交易可以花费同一区块中排在前面的交易的输出
+	inBlock := make(map[string]Transaction)
 	for _, tx := range transactions {
 		// TODO: ignore transaction if it's not valid
-		if bc.VerifyTransaction(tx) != true {
+		if bc.verifyTransaction(tx, inBlock) != true {
 			log.Panic("ERROR: Invalid transaction")
 		}
-	}
-	//获取当前链上的最新区块高度
-	err := bc.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blocksBucket))
-		lastHash = b.Get([]byte("l"))
-
-		blockData := b.Get(lastHash)
-		block := DeserializeBlock(blockData)
-
-		lastHeight = block.Height
-
-		return nil
-	})
-	if err != nil {
-		log.Panic(err)
+		if err := bc.CheckTransactionLocks(tx, lastHeight+1, time.Now().Unix()); err != nil {
+			log.Panic("ERROR: ", err)
+		}
+		if err := tx.CheckDataOutputs(); err != nil {
+			log.Panic("ERROR: ", err)
+		}
+		inBlock[hex.EncodeToString(tx.ID)] = *tx
 	}
 	//创建新的区块
 	newBlock := NewBlock(transactions, lastHash, lastHeight+1)
 	//更新区块链，返回新的区块
-	err = bc.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blocksBucket))
-		err := b.Put(newBlock.Hash, newBlock.Serialize())
-		if err != nil {
-			log.Panic(err)
-		}
-
-		err = b.Put([]byte("l"), newBlock.Hash)
-		if err != nil {
-			log.Panic(err)
-		}
-
-		bc.tip = newBlock.Hash
-
-		return nil
-	})
-	if err != nil {
-		log.Panic(err)
+	//区块、链尾和链状态一起写入；链状态无法增量更新时重建
+	if !bc.writeBlock(newBlock, true) {
+		bc.Reindex()
 	}
 
 	return newBlock
 }
 
-// 交易签名
-func (bc *Blockchain) SignTransaction(tx *Transaction, privKey ecdsa.PrivateKey) {
+// 交易签名：由签名器对花费pubKey的输出的交易签名
+func (bc *Blockchain) SignTransaction(tx *Transaction, signer Signer, pubKey []byte) error {
 	prevTXs := make(map[string]Transaction)
 	//遍历交易中的inputs，查找交易并计算签名源串
 	for _, vin := range tx.Vin {
 		prevTX, err := bc.FindTransaction(vin.Txid)
 		if err != nil {
-			log.Panic(err)
+			return err
 		}
 		prevTXs[hex.EncodeToString(prevTX.ID)] = prevTX
 	}
 	//交易签名
-	tx.Sign(privKey, prevTXs)
+	return tx.SignWith(signer, pubKey, prevTXs)
 }
 
 // 校验交易中的所有input签名
 func (bc *Blockchain) VerifyTransaction(tx *Transaction) bool {
+	return bc.verifyTransaction(tx, nil)
+}
+
+// 校验交易，被花费的交易先在pending（如同一区块中的交易）中查找，再到链上查找
+func (bc *Blockchain) verifyTransaction(tx *Transaction, pending map[string]Transaction) bool {
 	if tx.IsCoinbase() {
 		return true
 	}
@@ -343,9 +382,13 @@ func (bc *Blockchain) VerifyTransaction(tx *Transaction) bool {
 	prevTXs := make(map[string]Transaction)
 	//遍历交易中的所有input
 	for _, vin := range tx.Vin {
-		prevTX, err := bc.FindTransaction(vin.Txid)
-		if err != nil {
-			log.Panic(err)
+		prevTX, ok := pending[hex.EncodeToString(vin.Txid)]
+		if !ok {
+			var err error
+			prevTX, err = bc.FindTransaction(vin.Txid)
+			if err != nil {
+				log.Panic(err)
+			}
 		}
 		prevTXs[hex.EncodeToString(prevTX.ID)] = prevTX
 	}
@@ -2,28 +2,17 @@ package main
 
 import (
 	"log"
-
-	"github.com/boltdb/bolt"
 )
 
 // BlockchainIterator is used to iterate over blockchain blocks
 type BlockchainIterator struct {
 	currentHash []byte
-	db          *bolt.DB
+	db          ChainStore
 }
 
 // Next returns next block starting from the tip
 func (i *BlockchainIterator) Next() *Block {
-	var block *Block
-
-	err := i.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blocksBucket))
-		encodedBlock := b.Get(i.currentHash)
-		block = DeserializeBlock(encodedBlock)
-
-		return nil
-	})
-
+	block, err := i.db.GetBlock(i.currentHash)
 	if err != nil {
 		log.Panic(err)
 	}
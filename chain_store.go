@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+)
+
+// 区块链存储：区块、链尾、utxo集合和索引都通过该接口读写，不依赖具体的数据库
+// 数据按桶（bucket）分组保存为键值对；写操作先加入WriteBatch，再由Write原子地提交
+// 已有BoltStore（文件）和MemoryStore（内存，用于测试），LevelDB等存储只需实现该接口
+type ChainStore interface {
+	GetBlock(hash []byte) (*Block, error)
+	Tip() []byte //链尾区块的hash，没有区块时为nil
+	GetUTXOs(txID []byte) (TXOutputs, bool)
+	ForEachUTXO(fn func(txID []byte, outs TXOutputs) error) error
+
+	Get(bucket string, key []byte) []byte //不存在时为nil
+	ForEach(bucket string, fn func(key, value []byte) error) error
+	ForEachPrefix(bucket string, prefix []byte, fn func(key, value []byte) error) error //只遍历以prefix开头的键
+	Write(batch *WriteBatch) error
+	Close() error
+}
+
+// 链尾区块hash在blocks桶中的键
+const tipKey = "l"
+
+// 区块不存在
+var ErrBlockNotFound = errors.New("Block is not found.")
+
+// 批量写操作的类型
+const (
+	batchPut = iota
+	batchDelete
+	batchDeleteBucket
+)
+
+type batchOp struct {
+	kind   int
+	bucket string
+	key    []byte
+	value  []byte
+}
+
+// 批量写：按加入的顺序执行，全部成功或全部不生效
+type WriteBatch struct {
+	ops []batchOp
+}
+
+// 写入键值对，桶不存在时创建
+func (b *WriteBatch) Put(bucket string, key, value []byte) {
+	b.ops = append(b.ops, batchOp{batchPut, bucket, append([]byte{}, key...), append([]byte{}, value...)})
+}
+
+// 删除键
+func (b *WriteBatch) Delete(bucket string, key []byte) {
+	b.ops = append(b.ops, batchOp{batchDelete, bucket, append([]byte{}, key...), nil})
+}
+
+// 删除整个桶，桶不存在时忽略
+func (b *WriteBatch) DeleteBucket(bucket string) {
+	b.ops = append(b.ops, batchOp{kind: batchDeleteBucket, bucket: bucket})
+}
+
+// 追加另一个批量写的操作
+func (b *WriteBatch) Append(other *WriteBatch) {
+	b.ops = append(b.ops, other.ops...)
+}
+
+// 读取提交该批量写之后的值：先在批量写中从后向前查找，没有修改过的键到存储中读取
+func (b *WriteBatch) Get(s ChainStore, bucket string, key []byte) []byte {
+	for i := len(b.ops) - 1; i >= 0; i-- {
+		op := b.ops[i]
+		if op.bucket != bucket {
+			continue
+		}
+		switch {
+		case op.kind == batchDeleteBucket:
+			return nil
+		case string(op.key) != string(key):
+			continue
+		case op.kind == batchPut:
+			return append([]byte{}, op.value...)
+		default:
+			return nil
+		}
+	}
+
+	return s.Get(bucket, key)
+}
+
+// 保存区块
+func (b *WriteBatch) PutBlock(block *Block) {
+	b.Put(blocksBucket, block.Hash, block.Serialize())
+}
+
+// 设置链尾区块，同时更新链尾元数据
+func (b *WriteBatch) SetTip(block *Block) {
+	b.Put(blocksBucket, []byte(tipKey), block.Hash)
+	b.Put(metaBucket, []byte(tipInfoKey), newTipInfo(block).Serialize())
+}
+
+// 保存交易的未花费输出
+func (b *WriteBatch) PutUTXOs(txID []byte, outs TXOutputs) {
+	b.Put(utxoBucket, txID, outs.Serialize())
+}
+
+// 删除交易的未花费输出
+func (b *WriteBatch) DeleteUTXOs(txID []byte) {
+	b.Delete(utxoBucket, txID)
+}
+
+// 以下由键值读写实现区块、链尾和utxo的访问，各存储共用
+
+func storedBlock(s ChainStore, hash []byte) (*Block, error) {
+	blockData := s.Get(blocksBucket, hash)
+	if blockData == nil || string(hash) == tipKey {
+		return nil, ErrBlockNotFound
+	}
+
+	return DeserializeBlock(blockData), nil
+}
+
+func storedTip(s ChainStore) []byte {
+	return s.Get(blocksBucket, []byte(tipKey))
+}
+
+func storedUTXOs(s ChainStore, txID []byte) (TXOutputs, bool) {
+	outsBytes := s.Get(utxoBucket, txID)
+	if outsBytes == nil {
+		return TXOutputs{}, false
+	}
+
+	return DeserializeOutputs(outsBytes), true
+}
+
+func forEachStoredUTXO(s ChainStore, fn func(txID []byte, outs TXOutputs) error) error {
+	return s.ForEach(utxoBucket, func(key, value []byte) error {
+		return fn(key, DeserializeOutputs(value))
+	})
+}
+
+// 桶是否为空（或不存在）
+func bucketEmpty(s ChainStore, bucket string) bool {
+	errStop := errors.New("stop")
+	err := s.ForEach(bucket, func(key, value []byte) error {
+		return errStop
+	})
+
+	return err != errStop
+}
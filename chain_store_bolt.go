@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"log"
+
+	"github.com/boltdb/bolt"
+)
+
+// 基于BoltDB文件的存储：每个桶对应一个Bolt桶，批量写在一个事务中提交
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// 打开（或创建）数据库文件
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BoltStore{db}, nil
+}
+
+// GetBlock finds a block by its hash
+func (s *BoltStore) GetBlock(hash []byte) (*Block, error) {
+	return storedBlock(s, hash)
+}
+
+// Tip returns the hash of the last block
+func (s *BoltStore) Tip() []byte {
+	return storedTip(s)
+}
+
+// GetUTXOs returns the unspent outputs of a transaction
+func (s *BoltStore) GetUTXOs(txID []byte) (TXOutputs, bool) {
+	return storedUTXOs(s, txID)
+}
+
+// ForEachUTXO calls fn for every transaction with unspent outputs
+func (s *BoltStore) ForEachUTXO(fn func(txID []byte, outs TXOutputs) error) error {
+	return forEachStoredUTXO(s, fn)
+}
+
+// Get returns a copy of the value, the value is only valid inside the transaction
+func (s *BoltStore) Get(bucket string, key []byte) []byte {
+	var value []byte
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		if v := b.Get(key); v != nil {
+			value = append([]byte{}, v...)
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return value
+}
+
+// ForEach calls fn for every key in key order, keys and values are copied
+func (s *BoltStore) ForEach(bucket string, fn func(key, value []byte) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			return fn(append([]byte{}, k...), append([]byte{}, v...))
+		})
+	})
+}
+
+// ForEachPrefix seeks to prefix and calls fn for the keys starting with it
+func (s *BoltStore) ForEachPrefix(bucket string, prefix []byte, fn func(key, value []byte) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		c := b.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			if err := fn(append([]byte{}, k...), append([]byte{}, v...)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Write applies the batch in one transaction
+func (s *BoltStore) Write(batch *WriteBatch) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, op := range batch.ops {
+			if op.kind == batchDeleteBucket {
+				err := tx.DeleteBucket([]byte(op.bucket))
+				if err != nil && err != bolt.ErrBucketNotFound {
+					return err
+				}
+				continue
+			}
+
+			b, err := tx.CreateBucketIfNotExists([]byte(op.bucket))
+			if err != nil {
+				return err
+			}
+			if op.kind == batchPut {
+				err = b.Put(op.key, op.value)
+			} else {
+				err = b.Delete(op.key)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Close closes the database file
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 内存存储：不写文件，进程退出后数据丢失，用于测试
+type MemoryStore struct {
+	mu      sync.RWMutex
+	buckets map[string]map[string][]byte
+}
+
+// 创建空的内存存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]map[string][]byte)}
+}
+
+// GetBlock finds a block by its hash
+func (s *MemoryStore) GetBlock(hash []byte) (*Block, error) {
+	return storedBlock(s, hash)
+}
+
+// Tip returns the hash of the last block
+func (s *MemoryStore) Tip() []byte {
+	return storedTip(s)
+}
+
+// GetUTXOs returns the unspent outputs of a transaction
+func (s *MemoryStore) GetUTXOs(txID []byte) (TXOutputs, bool) {
+	return storedUTXOs(s, txID)
+}
+
+// ForEachUTXO calls fn for every transaction with unspent outputs
+func (s *MemoryStore) ForEachUTXO(fn func(txID []byte, outs TXOutputs) error) error {
+	return forEachStoredUTXO(s, fn)
+}
+
+// Get returns a copy of the value
+func (s *MemoryStore) Get(bucket string, key []byte) []byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, ok := s.buckets[bucket][string(key)]
+	if !ok {
+		return nil
+	}
+
+	return append([]byte{}, value...)
+}
+
+// ForEach calls fn for every key in key order, like Bolt
+// 遍历的是调用时的快照，fn中可以写入存储
+func (s *MemoryStore) ForEach(bucket string, fn func(key, value []byte) error) error {
+	return s.ForEachPrefix(bucket, nil, fn)
+}
+
+// ForEachPrefix calls fn for the keys starting with prefix in key order
+func (s *MemoryStore) ForEachPrefix(bucket string, prefix []byte, fn func(key, value []byte) error) error {
+	s.mu.RLock()
+	var keys []string
+	for key := range s.buckets[bucket] {
+		if strings.HasPrefix(key, string(prefix)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		values[i] = append([]byte{}, s.buckets[bucket][key]...)
+	}
+	s.mu.RUnlock()
+
+	for i, key := range keys {
+		if err := fn([]byte(key), values[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Write applies the batch under the lock, so readers never see half of it
+func (s *MemoryStore) Write(batch *WriteBatch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, op := range batch.ops {
+		switch op.kind {
+		case batchDeleteBucket:
+			delete(s.buckets, op.bucket)
+		case batchPut:
+			b, ok := s.buckets[op.bucket]
+			if !ok {
+				b = make(map[string][]byte)
+				s.buckets[op.bucket] = b
+			}
+			b[string(op.key)] = op.value
+		case batchDelete:
+			delete(s.buckets[op.bucket], string(op.key))
+		}
+	}
+
+	return nil
+}
+
+// Close does nothing, the data stays in memory
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 各存储的行为须一致
+func TestChainStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chainstore")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	bolt, err := NewBoltStore(filepath.Join(dir, "chain.db"))
+	assert.Nil(t, err)
+	defer bolt.Close()
+
+	for name, store := range map[string]ChainStore{"memory": NewMemoryStore(), "bolt": bolt} {
+		t.Run(name, func(t *testing.T) {
+			assert.Nil(t, store.Tip())
+			assert.Nil(t, store.Get("missing", []byte("key")))
+			assert.True(t, bucketEmpty(store, "missing"))
+
+			wallet := NewWallet()
+			bc := CreateBlockchainWithStore(store, string(wallet.GetAddress()))
+			genesis, err := store.GetBlock(store.Tip())
+			assert.Nil(t, err)
+			assert.Equal(t, bc.tip, genesis.Hash)
+			_, err = store.GetBlock([]byte(tipKey))
+			assert.Equal(t, ErrBlockNotFound, err)
+
+			UTXOSet{bc}.Reindex()
+			outs, ok := store.GetUTXOs(genesis.Transactions[0].ID)
+			assert.True(t, ok)
+			assert.Equal(t, subsidy, outs.Outputs[0].Value)
+			assert.Equal(t, 1, UTXOSet{bc}.CountTransactions())
+
+			//批量写按顺序执行：删除桶后写入的键保留
+			batch := &WriteBatch{}
+			batch.Put("test", []byte("b"), []byte("2"))
+			batch.Put("test", []byte("a"), []byte("1"))
+			batch.DeleteBucket("test")
+			batch.Put("test", []byte("c"), []byte("3"))
+			batch.Put("test", []byte("a"), []byte("4"))
+			batch.Delete("test", []byte("c"))
+			assert.Nil(t, store.Write(batch))
+
+			var keys, values []string
+			assert.Nil(t, store.ForEach("test", func(key, value []byte) error {
+				keys = append(keys, string(key))
+				values = append(values, string(value))
+				return nil
+			}))
+			assert.Equal(t, []string{"a"}, keys)
+			assert.Equal(t, []string{"4"}, values)
+
+			batch = &WriteBatch{}
+			for _, key := range []string{"ab", "b", "aa", "a"} {
+				batch.Put("prefix", []byte(key), []byte(key))
+			}
+			assert.Nil(t, store.Write(batch))
+			keys = nil
+			assert.Nil(t, store.ForEachPrefix("prefix", []byte("a"), func(key, value []byte) error {
+				keys = append(keys, string(key))
+				return nil
+			}))
+			assert.Equal(t, []string{"a", "aa", "ab"}, keys)
+
+			reopened := NewBlockchainWithStore(store)
+			assert.Equal(t, bc.tip, reopened.tip)
+			assert.Equal(t, 0, reopened.GetBestHeight())
+		})
+	}
+}
+
+// 旧版本的数据库（输出以公钥哈希字段锁定）不能加载
+func TestLegacyChainFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chain")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	data, err := ioutil.ReadFile("blockchain_btnode1.db")
+	assert.Nil(t, err)
+	file := filepath.Join(dir, "chain.db")
+	assert.Nil(t, ioutil.WriteFile(file, data, 0600))
+
+	store, err := NewBoltStore(file)
+	assert.Nil(t, err)
+	defer store.Close()
+	assert.Equal(t, ErrLegacyChainFormat, checkChainFormat(store))
+	assert.Panics(t, func() { NewBlockchainWithStore(store) })
+
+	wallet := NewWallet()
+	assert.Nil(t, checkChainFormat(CreateBlockchainWithStore(NewMemoryStore(), string(wallet.GetAddress())).db))
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// 链状态：utxo集合和数据索引，与区块、链尾一起在一次批量写中更新
+// 每个连接的区块保存撤销数据（花费的输出），链重组时据此断开旧分支上的区块
+// meta桶中记录链状态对应的区块；启动时与链尾不一致（如旧版本写到一半退出）则修复
+
+const undoBucket = "undo"
+const metaBucket = "meta"
+
+// 链状态对应的区块hash在meta桶中的键
+const chainstateKey = "chainstate"
+
+// 无法增量更新链状态，需要重建
+var ErrNoUndoData = errors.New("undo data of the block is not found")
+
+// 区块花费的输出
+type SpentOutput struct {
+	Txid   []byte
+	Vout   int
+	Output TXOutput
+}
+
+// 撤销数据的序列化
+func serializeUndo(spent []SpentOutput) []byte {
+	var buff bytes.Buffer
+
+	err := gob.NewEncoder(&buff).Encode(spent)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return buff.Bytes()
+}
+
+func deserializeUndo(data []byte) []SpentOutput {
+	var spent []SpentOutput
+
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&spent)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return spent
+}
+
+// 链状态对应的区块，未记录时为nil（旧版本创建的数据库）
+func (bc *Blockchain) chainstateTip() []byte {
+	return bc.db.Get(metaBucket, []byte(chainstateKey))
+}
+
+// 把区块连接到链状态：更新utxo集合和各索引，保存撤销数据
+func (bc *Blockchain) connectChainstate(batch *WriteBatch, block *Block) {
+	spent := UTXOSet{bc}.connect(batch, block)
+	batch.Put(undoBucket, block.Hash, serializeUndo(spent))
+	DataIndex{bc}.connect(batch, block)
+	TxIndex{bc}.connect(batch, block)
+	AddressIndex{bc}.connect(batch, block, spent)
+	batch.Put(heightIndexBucket, heightKey(block.Height), block.Hash)
+}
+
+// 从链状态断开区块
+func (bc *Blockchain) disconnectChainstate(batch *WriteBatch, block *Block) error {
+	undo := batch.Get(bc.db, undoBucket, block.Hash)
+	if undo == nil {
+		return ErrNoUndoData
+	}
+	spent := deserializeUndo(undo)
+	UTXOSet{bc}.disconnect(batch, block, spent)
+	DataIndex{bc}.disconnect(batch, block)
+	AddressIndex{bc}.disconnect(batch, block, spent)
+	TxIndex{bc}.disconnect(batch, block)
+	batch.Delete(undoBucket, block.Hash)
+	batch.Delete(heightIndexBucket, heightKey(block.Height))
+
+	return nil
+}
+
+// 生成把链状态从区块from移到区块to的批量写：断开from到分叉点的区块，再连接分叉点到to的区块
+// to可以尚未保存；缺少区块或撤销数据时返回错误
+func (bc *Blockchain) chainstateBatch(from []byte, to *Block) (*WriteBatch, error) {
+	if from == nil {
+		return nil, ErrNoUndoData
+	}
+	parent := func(block *Block) (*Block, error) {
+		if len(block.PrevBlockHash) == 0 {
+			return nil, errors.New("blocks are not on the same chain")
+		}
+		return bc.db.GetBlock(block.PrevBlockHash)
+	}
+	fromBlock, err := bc.db.GetBlock(from)
+	if err != nil {
+		return nil, err
+	}
+
+	var connect, disconnect []*Block
+	newBranch, oldBranch := to, fromBlock
+	for !bytes.Equal(newBranch.Hash, oldBranch.Hash) {
+		if newBranch.Height >= oldBranch.Height {
+			connect = append(connect, newBranch)
+			newBranch, err = parent(newBranch)
+		} else {
+			disconnect = append(disconnect, oldBranch)
+			oldBranch, err = parent(oldBranch)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	batch := &WriteBatch{}
+	for _, block := range disconnect {
+		if err := bc.disconnectChainstate(batch, block); err != nil {
+			return nil, err
+		}
+	}
+	for i := len(connect) - 1; i >= 0; i-- {
+		bc.connectChainstate(batch, connect[i])
+	}
+	batch.Put(metaBucket, []byte(chainstateKey), to.Hash)
+
+	return batch, nil
+}
+
+// 保存区块，setTip时设为链尾并在同一批量写中更新链状态
+// 无法增量更新链状态时（如缺少撤销数据）返回false，调用者须重建链状态
+func (bc *Blockchain) writeBlock(block *Block, setTip bool) bool {
+	batch := &WriteBatch{}
+	batch.PutBlock(block)
+	connected := false
+	if setTip {
+		batch.SetTip(block)
+		if cs, err := bc.chainstateBatch(bc.chainstateTip(), block); err == nil {
+			batch.Append(cs)
+			connected = true
+		}
+	}
+
+	err := bc.db.Write(batch)
+	if err != nil {
+		log.Panic(err)
+	}
+	if setTip {
+		bc.tip = block.Hash
+	}
+
+	return connected
+}
+
+// 重建链状态：utxo集合、数据索引、高度索引和链尾元数据、地址索引，以及启用时的交易索引
+// 开始时清除链状态对应的区块，全部重建之后再记录；中途退出时下次启动会再次重建
+func (bc *Blockchain) Reindex() {
+	batch := &WriteBatch{}
+	batch.Delete(metaBucket, []byte(chainstateKey))
+	err := bc.db.Write(batch)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	UTXOSet{bc}.Reindex()
+	DataIndex{bc}.Reindex()
+	bc.reindexHeights()
+	TxIndex{bc}.Reindex()
+	AddressIndex{bc}.Reindex()
+
+	batch = &WriteBatch{}
+	batch.Put(metaBucket, []byte(chainstateKey), bc.tip)
+	err = bc.db.Write(batch)
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// 启动时检查链状态是否与链尾一致，不一致时修复：能增量更新时连接缺少的区块，否则重建
+func (bc *Blockchain) checkChainstate() {
+	if bc.tip == nil {
+		return
+	}
+	//旧版本的数据库没有地址索引；须在修复链状态之前检查，修复时只会加入新连接的区块
+	noAddressIndex := bucketEmpty(bc.db, addrHistoryBucket)
+	chainstate := bc.chainstateTip()
+	if !bytes.Equal(chainstate, bc.tip) {
+		tip, err := bc.db.GetBlock(bc.tip)
+		if err != nil {
+			log.Panic(err)
+		}
+		if batch, err := bc.chainstateBatch(chainstate, tip); err == nil {
+			batch.SetTip(tip)
+			if err := bc.db.Write(batch); err != nil {
+				log.Panic(err)
+			}
+			fmt.Printf("Chainstate was behind the tip, connected up to block %x\n", bc.tip)
+		} else {
+			bc.Reindex()
+			fmt.Printf("Chainstate did not match the tip, rebuilt it at block %x\n", bc.tip)
+			return
+		}
+	}
+
+	//旧版本的数据库没有高度索引和链尾元数据
+	if bc.db.Get(metaBucket, []byte(tipInfoKey)) == nil || bc.db.Get(heightIndexBucket, heightKey(0)) == nil {
+		bc.reindexHeights()
+		fmt.Printf("Built the height index up to block %x\n", bc.tip)
+	}
+	if noAddressIndex {
+		AddressIndex{bc}.Reindex()
+		fmt.Printf("Built the address index up to block %x\n", bc.tip)
+	}
+}
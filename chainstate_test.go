@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// utxo集合的内容
+func utxoSnapshot(t *testing.T, bc *Blockchain) map[string]TXOutputs {
+	utxos := make(map[string]TXOutputs)
+	assert.Nil(t, bc.db.ForEachUTXO(func(txID []byte, outs TXOutputs) error {
+		utxos[hex.EncodeToString(txID)] = outs
+		return nil
+	}))
+
+	return utxos
+}
+
+// 桶的内容
+func bucketSnapshot(t *testing.T, bc *Blockchain, bucket string) map[string]string {
+	values := make(map[string]string)
+	assert.Nil(t, bc.db.ForEach(bucket, func(key, value []byte) error {
+		values[hex.EncodeToString(key)] = hex.EncodeToString(value)
+		return nil
+	}))
+
+	return values
+}
+
+// 增量更新的utxo集合和地址索引须与重建的一致
+func assertChainstate(t *testing.T, bc *Blockchain) {
+	assert.Equal(t, bc.tip, bc.chainstateTip())
+	updated := utxoSnapshot(t, bc)
+	UTXOSet{bc}.Reindex()
+	assert.Equal(t, utxoSnapshot(t, bc), updated)
+
+	addrUTXOs, addrHistory := bucketSnapshot(t, bc, addrUTXOBucket), bucketSnapshot(t, bc, addrHistoryBucket)
+	AddressIndex{bc}.Reindex()
+	assert.Equal(t, bucketSnapshot(t, bc, addrUTXOBucket), addrUTXOs)
+	assert.Equal(t, bucketSnapshot(t, bc, addrHistoryBucket), addrHistory)
+}
+
+func TestChainstateRepair(t *testing.T) {
+	wallet := NewWallet()
+	bc := newTestBlockchain(t, wallet)
+	genesis := bc.Iterator().Next()
+
+	spend := newTestSpend(wallet, genesis.Transactions[0], 0, []int{4, 6}, SequenceFinal)
+	block := bc.MineBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), ""), spend})
+	assertChainstate(t, bc)
+
+	//模拟旧版本写入区块和链尾后、更新utxo集合前退出
+	next := NewBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), ""), newTestSpend(wallet, spend, 1, []int{6}, SequenceFinal)}, block.Hash, block.Height+1)
+	batch := &WriteBatch{}
+	batch.PutBlock(next)
+	batch.SetTip(next)
+	assert.Nil(t, bc.db.Write(batch))
+	assert.Equal(t, block.Hash, bc.chainstateTip())
+
+	reopened := NewBlockchainWithStore(bc.db)
+	assert.Equal(t, next.Hash, reopened.tip)
+	_, ok := UTXOSet{reopened}.FindUnspentOutput(spend.ID, 1)
+	assert.False(t, ok, "the repaired chainstate includes the spend in the new tip")
+	assertChainstate(t, reopened)
+
+	//没有撤销数据时重建
+	batch = &WriteBatch{}
+	batch.Put(metaBucket, []byte(chainstateKey), genesis.Hash)
+	batch.DeleteBucket(undoBucket)
+	assert.Nil(t, bc.db.Write(batch))
+	assertChainstate(t, NewBlockchainWithStore(bc.db))
+
+	//只重建utxo集合不记录链状态；重建到一半退出时下次启动全部重建
+	batch = &WriteBatch{}
+	batch.Delete(metaBucket, []byte(chainstateKey))
+	batch.DeleteBucket(addrHistoryBucket)
+	assert.Nil(t, bc.db.Write(batch))
+	UTXOSet{bc}.Reindex()
+	assert.Nil(t, bc.chainstateTip())
+	reopened = NewBlockchainWithStore(bc.db)
+	assert.NotEmpty(t, bucketSnapshot(t, reopened, addrHistoryBucket))
+	assertChainstate(t, reopened)
+}
+
+func TestChainstateReorg(t *testing.T) {
+	wallet := NewWallet()
+	bc := newTestBlockchain(t, wallet)
+	genesis := bc.Iterator().Next()
+
+	//旧分支上的区块花费了创世块的输出
+	spend := newTestSpend(wallet, genesis.Transactions[0], 0, []int{10}, SequenceFinal)
+	old := bc.MineBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), ""), spend})
+	_, ok := UTXOSet{bc}.FindUnspentOutput(genesis.Transactions[0].ID, 0)
+	assert.False(t, ok)
+
+	//同样高度的分叉区块不改变链尾，更高的分支成为链尾
+	fork := NewBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), "fork")}, genesis.Hash, 1)
+	bc.AddBlock(fork)
+	assert.Equal(t, old.Hash, bc.tip)
+	next := NewBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), "next")}, fork.Hash, 2)
+	bc.AddBlock(next)
+	assert.Equal(t, next.Hash, bc.tip)
+
+	_, ok = UTXOSet{bc}.FindUnspentOutput(genesis.Transactions[0].ID, 0)
+	assert.True(t, ok, "the output spent on the old branch is restored")
+	_, ok = UTXOSet{bc}.FindUnspentOutput(spend.ID, 0)
+	assert.False(t, ok)
+	assert.Nil(t, bc.db.Get(undoBucket, old.Hash))
+	assertChainstate(t, bc)
+}
+
+func TestAddOrphanBlocks(t *testing.T) {
+	wallet := NewWallet()
+	bc := newTestBlockchain(t, wallet)
+	genesis := bc.Iterator().Next()
+
+	//同步时区块从链尾开始逆序收到，父区块连接前链尾和链状态不变
+	var blocks []*Block
+	prev := genesis
+	for i := 1; i <= 3; i++ {
+		prev = NewBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), fmt.Sprint(i))}, prev.Hash, i)
+		blocks = append(blocks, prev)
+	}
+	connected, _ := bc.AddBlock(blocks[2])
+	assert.Empty(t, connected)
+	connected, _ = bc.AddBlock(blocks[1])
+	assert.Empty(t, connected)
+	assert.Equal(t, genesis.Hash, bc.tip)
+	assert.Equal(t, genesis.Hash, bc.chainstateTip())
+	assert.False(t, bc.isConnected(blocks[1].Hash))
+
+	var hashes [][]byte
+	connected, disconnected := bc.AddBlock(blocks[0])
+	for _, block := range connected {
+		hashes = append(hashes, block.Hash)
+	}
+	assert.Equal(t, [][]byte{blocks[2].Hash, blocks[1].Hash, blocks[0].Hash}, hashes)
+	assert.Empty(t, disconnected)
+	assert.Equal(t, blocks[2].Hash, bc.tip)
+	assert.True(t, bc.isConnected(blocks[2].Hash))
+	assert.Empty(t, bucketSnapshot(t, bc, orphanBucket))
+	assertChainstate(t, bc)
+
+	//时间锁不满足的孤块在连接时被删除，其后的孤块不成为链尾
+	locked := newTestSpend(wallet, genesis.Transactions[0], 0, []int{10}, 10)
+	invalid := NewBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), "invalid"), locked}, blocks[2].Hash, 4)
+	next := NewBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), "next")}, invalid.Hash, 5)
+	bc.AddBlock(next)
+	connected, _ = bc.AddBlock(invalid)
+	assert.Empty(t, connected)
+	_, err := bc.GetBlock(invalid.Hash)
+	assert.NotNil(t, err)
+	assert.Equal(t, blocks[2].Hash, bc.tip)
+	assertChainstate(t, bc)
+}
@@ -9,19 +9,64 @@ import (
 )
 
 // CLI responsible for processing command line arguments
-type CLI struct{}
+type CLI struct {
+	walletName string //-wallet指定的钱包，为空时为节点的默认钱包
+}
+
+// 当前命令使用的钱包文件的ID
+func (cli *CLI) walletID(nodeID string) string {
+	return WalletID(nodeID, cli.walletName)
+}
 
-//客户端使用说明
+// 客户端使用说明
 func (cli *CLI) printUsage() {
 	fmt.Println("Usage:")
+	fmt.Println("  broadcastpst -file FILE -mine - Send a finalized transaction. Mine on the same node, when -mine is set.")
+	fmt.Println("  bumpfee -txid TXID -fee FEE -rpcport PORT - Replace an unconfirmed replaceable transaction with one paying FEE (default: the lowest fee that also pays for its unconfirmed descendants, which are replaced with it), taken from its change. Needs a node running with -rpcport PORT")
+	fmt.Println("  combinepst -files FILE1,FILE2,... -file FILE - Merge the signatures of several copies of a partially signed transaction")
 	fmt.Println("  createblockchain -address ADDRESS - Create a blockchain and send genesis block reward to ADDRESS")
-	fmt.Println("  createwallet - Generates a new key-pair and saves it into the wallet file")
-	fmt.Println("  getbalance -address ADDRESS - Get balance of ADDRESS")
+	fmt.Println("  createmultisig -m M -pubkeys PUBKEY1,PUBKEY2,... - Create an M-of-N multisig address from public keys or wallet addresses")
+	fmt.Println("  createmultisigtx -from MULTISIG -to TO -amount AMOUNT -file FILE - Create an unsigned transaction spending from a multisig address; same as createpst")
+	fmt.Println("  createpst -from FROM -to TO -amount AMOUNT -locktime LOCKTIME -file FILE - Create an unsigned partially signed transaction; no private keys are needed")
+	fmt.Println("  createrawtransaction -inputs TXID:VOUT[:SEQUENCE],... -outputs ADDRESS:AMOUNT|data:HEX,... -locktime LOCKTIME - Create an unsigned transaction from explicit inputs and outputs, no change is added")
+	fmt.Println("  createscriptaddress -type hashlock|timelock|relativelock -address ADDRESS -hash HASH -locktime LOCKTIME -sequence SEQUENCE | -script HEX - Create a pay-to-script-hash address from a template or a raw redeem script")
+	fmt.Println("  createwallet -name NAME -mnemonic -passphrase PASSPHRASE -keytype p256|secp256k1 - Derives a new address from the HD seed and saves it into the wallet file. With -mnemonic, a new wallet is seeded from a generated mnemonic phrase, optionally protected by PASSPHRASE. With -keytype secp256k1, a random Bitcoin-style key is generated instead, which is not restored from the seed: it is only created in a wallet without an HD seed (use -wallet NAME), whose change goes back to the sending address; back it up with dumpprivkey")
+	fmt.Println("  decoderawtransaction -hex HEX - Print a hex encoded transaction as JSON")
+	fmt.Println("  dumpprivkey -address ADDRESS - Print the private key of ADDRESS in Base58Check encoding")
+	fmt.Println("  encryptwallet -passphrase PASSPHRASE - Encrypt the private keys and HD seed of the wallet. Signing then needs WALLET_PASSPHRASE set, or walletpassphrase on a running node")
+	fmt.Println("  finalizepst -file FILE - Build the unlocking scripts of a fully signed transaction")
+	fmt.Println("  finddata -hex DATA - Find the transaction and block that embedded DATA")
+	fmt.Println("  getaddresshistory -address ADDRESS - List every main chain transaction that paid to or spent from ADDRESS, oldest first, with confirmations")
+	fmt.Println("  getbalance -address ADDRESS - Get balance of ADDRESS, or of every address in the wallet when ADDRESS is omitted")
+	fmt.Println("  getblockhash -height HEIGHT - Print the hash of the main chain block at HEIGHT")
+	fmt.Println("  getpubkey -address ADDRESS - Print the public key of a wallet ADDRESS")
+	fmt.Println("  gettransaction -txid TXID - Print a transaction of the main chain or the mempool with its block and confirmations")
+	fmt.Println("  getxpub -path PATH - Print the extended public key of the HD wallet at PATH (default m/0', the account holding all addresses)")
+	fmt.Println("  importaddress -address ADDRESS - Watch ADDRESS without its private key. Watch-only addresses are listed and can fund unsigned transactions built with createpst")
+	fmt.Println("  importprivkey -key KEY -rescan - Add a private key exported by dumpprivkey to the wallet. -rescan scans the blockchain for the transactions of the key and reports its funds")
+	fmt.Println("  importxpub -xpub XPUB - Watch the addresses derived from an extended public key, e.g. one printed by getxpub on a cold wallet. Run again to pick up newly used addresses")
 	fmt.Println("  listaddresses - Lists all addresses from the wallet file")
-	fmt.Println("  printchain - Print all the blocks of the blockchain")
-	fmt.Println("  reindexutxo - Rebuilds the UTXO set")
-	fmt.Println("  send -from FROM -to TO -amount AMOUNT -mine - Send AMOUNT of coins from FROM address to TO. Mine on the same node, when -mine is set.")
-	fmt.Println("  startnode -miner ADDRESS - Start a node with ID specified in NODE_ID env. var. -miner enables mining")
+	fmt.Println("  listtransactions -address ADDRESS -count N -rescan - List the latest N wallet transactions, or those of ADDRESS, with confirmations, fees, counterparties and running balances. -rescan scans the whole chain again")
+	fmt.Println("  listunspent -address ADDRESS - List the unspent outputs of ADDRESS, or of every address in the wallet")
+	fmt.Println("  loadwallet -name NAME -rpcport PORT - Load the wallet NAME into the node running with -rpcport PORT, so RPC calls can use it")
+	fmt.Println("  printchain [-from HEIGHT] [-to HEIGHT] - Print the main chain blocks from height TO down to FROM, the whole chain by default")
+	fmt.Println("  reindexutxo -txindex on|off - Rebuilds the UTXO set and the block indexes. -txindex on also builds and maintains the transaction index, which makes transaction lookups fast; -txindex off drops it")
+	fmt.Println("  restorewallet -words \"WORD1 WORD2 ...\" -passphrase PASSPHRASE - Restore an HD wallet from its mnemonic phrase and rediscover the used addresses on the blockchain")
+	fmt.Println("  send -from FROM -to TO -amount AMOUNT -locktime LOCKTIME -fee FEE -rbf -strategy bnb|largest|smallest|random -inputs TXID:VOUT,... -signer SIGNER -mine -bare - Send AMOUNT of coins from FROM address to TO, paying FEE. Set -rbf to allow replacing the transaction with one paying a higher fee. The transaction cannot be mined before LOCKTIME (block height or timestamp). Inputs are chosen by -strategy, or are exactly the outputs given by -inputs. SIGNER is local (default), external:COMMAND or pkcs11:MODULE[:SLOT]; an external signer receives {\"pubkey\",\"hash\"} as JSON on stdin and writes {\"signature\"} to stdout, so FROM may be a locked or watch-only address. Mine on the same node, when -mine is set. Pay to a bare multisig script of TO, when -bare is set.")
+	fmt.Println("  senddata -from FROM -hex DATA -mine - Embed DATA in an unspendable output, e.g. to timestamp a document hash. Mine on the same node, when -mine is set.")
+	fmt.Println("  sendmany -from FROM -to ADDRESS:AMOUNT,... | -file FILE -fee FEE -rbf -strategy STRATEGY -signer SIGNER -mine - Pay several recipients in one transaction with a single change output. FILE is CSV (address,amount) or JSON. Mine on the same node, when -mine is set.")
+	fmt.Println("  sendmultisigtx -file FILE -mine - Send a fully signed multisig transaction; same as finalizepst followed by broadcastpst. Mine on the same node, when -mine is set.")
+	fmt.Println("  sendrawtransaction -hex HEX -mine - Verify and send a hex encoded signed transaction. Mine on the same node, when -mine is set.")
+	fmt.Println("  setlabel -address ADDRESS | -txid TXID -label LABEL - Label an address or a transaction. An empty LABEL removes it")
+	fmt.Println("  signmultisigtx -file FILE -address ADDRESS - Add the signature of ADDRESS to a multisig transaction; same as signpst")
+	fmt.Println("  signpst -file FILE -address ADDRESS - Sign a partially signed transaction with the key of ADDRESS, or with every key in the wallet; works offline")
+	fmt.Println("  signrawtransaction -hex HEX -address ADDRESS - Sign a hex encoded transaction with the key of ADDRESS, or with every key in the wallet")
+	fmt.Println("  spendscript -from SCRIPTADDRESS -to TO -amount AMOUNT -preimage HEX -mine - Spend from a pay-to-script-hash address by revealing its redeem script")
+	fmt.Println("  startnode -miner ADDRESS -rpcport PORT - Start a node with ID specified in NODE_ID env. var. -miner enables mining. -rpcport enables the JSON-RPC server on PORT")
+	fmt.Println("  unloadwallet -name NAME -rpcport PORT - Unload the wallet NAME from the node running with -rpcport PORT")
+	fmt.Println("  walletlock -rpcport PORT - Lock the wallet of the node running with -rpcport PORT")
+	fmt.Println("  walletpassphrase -passphrase PASSPHRASE -timeout SECONDS -rpcport PORT - Unlock the wallet of the node running with -rpcport PORT for SECONDS")
+	fmt.Println("Commands that use the wallet accept -wallet NAME to use the named wallet NAME of the node instead of its default wallet")
 }
 
 // 参数校验，命令格式: ./blockchain_go 命令参数
@@ -43,21 +88,166 @@ func (cli *CLI) Run() {
 	}
 
 	getBalanceCmd := flag.NewFlagSet("getbalance", flag.ExitOnError)
+	getPubKeyCmd := flag.NewFlagSet("getpubkey", flag.ExitOnError)
 	createBlockchainCmd := flag.NewFlagSet("createblockchain", flag.ExitOnError)
+	createMultiSigCmd := flag.NewFlagSet("createmultisig", flag.ExitOnError)
 	createWalletCmd := flag.NewFlagSet("createwallet", flag.ExitOnError)
 	listAddressesCmd := flag.NewFlagSet("listaddresses", flag.ExitOnError)
 	printChainCmd := flag.NewFlagSet("printchain", flag.ExitOnError)
 	reindexUTXOCmd := flag.NewFlagSet("reindexutxo", flag.ExitOnError)
 	sendCmd := flag.NewFlagSet("send", flag.ExitOnError)
 	startNodeCmd := flag.NewFlagSet("startnode", flag.ExitOnError)
+	createScriptAddressCmd := flag.NewFlagSet("createscriptaddress", flag.ExitOnError)
+	spendScriptCmd := flag.NewFlagSet("spendscript", flag.ExitOnError)
+	sendDataCmd := flag.NewFlagSet("senddata", flag.ExitOnError)
+	findDataCmd := flag.NewFlagSet("finddata", flag.ExitOnError)
+	createPSTCmd := flag.NewFlagSet("createpst", flag.ExitOnError)
+	signPSTCmd := flag.NewFlagSet("signpst", flag.ExitOnError)
+	combinePSTCmd := flag.NewFlagSet("combinepst", flag.ExitOnError)
+	finalizePSTCmd := flag.NewFlagSet("finalizepst", flag.ExitOnError)
+	broadcastPSTCmd := flag.NewFlagSet("broadcastpst", flag.ExitOnError)
+	createRawTxCmd := flag.NewFlagSet("createrawtransaction", flag.ExitOnError)
+	decodeRawTxCmd := flag.NewFlagSet("decoderawtransaction", flag.ExitOnError)
+	signRawTxCmd := flag.NewFlagSet("signrawtransaction", flag.ExitOnError)
+	sendRawTxCmd := flag.NewFlagSet("sendrawtransaction", flag.ExitOnError)
+	sendManyCmd := flag.NewFlagSet("sendmany", flag.ExitOnError)
+	listUnspentCmd := flag.NewFlagSet("listunspent", flag.ExitOnError)
+	bumpFeeCmd := flag.NewFlagSet("bumpfee", flag.ExitOnError)
+	getXpubCmd := flag.NewFlagSet("getxpub", flag.ExitOnError)
+	restoreWalletCmd := flag.NewFlagSet("restorewallet", flag.ExitOnError)
+	encryptWalletCmd := flag.NewFlagSet("encryptwallet", flag.ExitOnError)
+	walletPassphraseCmd := flag.NewFlagSet("walletpassphrase", flag.ExitOnError)
+	walletLockCmd := flag.NewFlagSet("walletlock", flag.ExitOnError)
+	dumpPrivKeyCmd := flag.NewFlagSet("dumpprivkey", flag.ExitOnError)
+	importPrivKeyCmd := flag.NewFlagSet("importprivkey", flag.ExitOnError)
+	importAddressCmd := flag.NewFlagSet("importaddress", flag.ExitOnError)
+	importXpubCmd := flag.NewFlagSet("importxpub", flag.ExitOnError)
+	listTransactionsCmd := flag.NewFlagSet("listtransactions", flag.ExitOnError)
+	setLabelCmd := flag.NewFlagSet("setlabel", flag.ExitOnError)
+	loadWalletCmd := flag.NewFlagSet("loadwallet", flag.ExitOnError)
+	unloadWalletCmd := flag.NewFlagSet("unloadwallet", flag.ExitOnError)
+	getBlockHashCmd := flag.NewFlagSet("getblockhash", flag.ExitOnError)
+	getTransactionCmd := flag.NewFlagSet("gettransaction", flag.ExitOnError)
+	getAddressHistoryCmd := flag.NewFlagSet("getaddresshistory", flag.ExitOnError)
+	createMultiSigTxCmd := flag.NewFlagSet("createmultisigtx", flag.ExitOnError)
+	sendMultiSigTxCmd := flag.NewFlagSet("sendmultisigtx", flag.ExitOnError)
+	signMultiSigTxCmd := flag.NewFlagSet("signmultisigtx", flag.ExitOnError)
 
+	createWalletMnemonic := createWalletCmd.Bool("mnemonic", false, "Seed a new wallet from a generated mnemonic phrase")
+	createWalletPassphrase := createWalletCmd.String("passphrase", "", "Optional passphrase extending the mnemonic")
+	createWalletKeyType := createWalletCmd.String("keytype", "", "Key type of the new address: p256 (default, HD) or secp256k1")
 	getBalanceAddress := getBalanceCmd.String("address", "", "The address to get balance for")
+	getPubKeyAddress := getPubKeyCmd.String("address", "", "The wallet address to get the public key of")
 	createBlockchainAddress := createBlockchainCmd.String("address", "", "The address to send genesis block reward to")
+	createMultiSigM := createMultiSigCmd.Int("m", 0, "Number of signatures required")
+	createMultiSigPubKeys := createMultiSigCmd.String("pubkeys", "", "Comma separated public keys (hex) or wallet addresses")
 	sendFrom := sendCmd.String("from", "", "Source wallet address")
 	sendTo := sendCmd.String("to", "", "Destination wallet address")
 	sendAmount := sendCmd.Int("amount", 0, "Amount to send")
+	sendLockTime := sendCmd.Int64("locktime", 0, "Block height or timestamp before which the transaction cannot be mined")
 	sendMine := sendCmd.Bool("mine", false, "Mine immediately on the same node")
+	sendBare := sendCmd.Bool("bare", false, "Lock the output with the bare multisig script of the destination")
+	sendFee := sendCmd.Int("fee", 0, "Transaction fee")
+	sendRBF := sendCmd.Bool("rbf", false, "Allow the transaction to be replaced by one paying a higher fee")
+	sendStrategy := sendCmd.String("strategy", "", "Coin selection strategy: bnb, largest, smallest or random (default bnb)")
+	sendInputs := sendCmd.String("inputs", "", "Comma separated outputs to spend, txid:vout")
+	sendSigner := sendCmd.String("signer", "", "Signer: local, external:COMMAND or pkcs11:MODULE[:SLOT]")
 	startNodeMiner := startNodeCmd.String("miner", "", "Enable mining mode and send reward to ADDRESS")
+	startNodeRPCPort := startNodeCmd.String("rpcport", "", "Enable the JSON-RPC server on PORT")
+	createScriptAddressType := createScriptAddressCmd.String("type", "", "Redeem script template: hashlock, timelock or relativelock")
+	createScriptAddressAddress := createScriptAddressCmd.String("address", "", "The address whose key can spend the funds")
+	createScriptAddressHash := createScriptAddressCmd.String("hash", "", "SHA-256 hash (hex) of the preimage for a hash lock")
+	createScriptAddressLockTime := createScriptAddressCmd.Int64("locktime", 0, "Block height or timestamp for a time lock")
+	createScriptAddressSequence := createScriptAddressCmd.Int64("sequence", 0, "Relative lock in blocks, or in 512 second units with bit 22 set")
+	createScriptAddressScript := createScriptAddressCmd.String("script", "", "Raw redeem script (hex)")
+	spendScriptFrom := spendScriptCmd.String("from", "", "Source script address")
+	spendScriptTo := spendScriptCmd.String("to", "", "Destination wallet address")
+	spendScriptAmount := spendScriptCmd.Int("amount", 0, "Amount to send")
+	spendScriptPreimage := spendScriptCmd.String("preimage", "", "Preimage (hex) of a hash lock")
+	spendScriptMine := spendScriptCmd.Bool("mine", false, "Mine immediately on the same node")
+	sendDataFrom := sendDataCmd.String("from", "", "Source wallet address")
+	sendDataHex := sendDataCmd.String("hex", "", "Data (hex) to embed")
+	sendDataMine := sendDataCmd.Bool("mine", false, "Mine immediately on the same node")
+	findDataHex := findDataCmd.String("hex", "", "Embedded data (hex) to look up")
+	createPSTFrom := createPSTCmd.String("from", "", "Source address, a wallet or script address")
+	createPSTTo := createPSTCmd.String("to", "", "Destination wallet address")
+	createPSTAmount := createPSTCmd.Int("amount", 0, "Amount to send")
+	createPSTLockTime := createPSTCmd.Int64("locktime", 0, "Block height or timestamp before which the transaction cannot be mined")
+	createPSTFile := createPSTCmd.String("file", "", "File to save the partially signed transaction to")
+	signPSTFile := signPSTCmd.String("file", "", "Partially signed transaction file")
+	signPSTAddress := signPSTCmd.String("address", "", "Wallet address whose key signs the transaction")
+	combinePSTFiles := combinePSTCmd.String("files", "", "Comma separated partially signed transaction files")
+	combinePSTFile := combinePSTCmd.String("file", "", "File to save the combined transaction to")
+	finalizePSTFile := finalizePSTCmd.String("file", "", "Partially signed transaction file")
+	broadcastPSTFile := broadcastPSTCmd.String("file", "", "Finalized transaction file")
+	broadcastPSTMine := broadcastPSTCmd.Bool("mine", false, "Mine immediately on the same node")
+	createRawTxInputs := createRawTxCmd.String("inputs", "", "Comma separated inputs, txid:vout[:sequence]")
+	createRawTxOutputs := createRawTxCmd.String("outputs", "", "Comma separated outputs, address:amount or data:hex")
+	createRawTxLockTime := createRawTxCmd.Int64("locktime", 0, "Block height or timestamp before which the transaction cannot be mined")
+	decodeRawTxHex := decodeRawTxCmd.String("hex", "", "Hex encoded transaction")
+	signRawTxHex := signRawTxCmd.String("hex", "", "Hex encoded transaction")
+	signRawTxAddress := signRawTxCmd.String("address", "", "Wallet address whose key signs the transaction")
+	sendRawTxHex := sendRawTxCmd.String("hex", "", "Hex encoded signed transaction")
+	sendRawTxMine := sendRawTxCmd.Bool("mine", false, "Mine immediately on the same node")
+	sendManyFrom := sendManyCmd.String("from", "", "Source wallet address")
+	sendManyTo := sendManyCmd.String("to", "", "Comma separated recipients, address:amount")
+	sendManyFile := sendManyCmd.String("file", "", "CSV or JSON file with the recipients")
+	sendManyMine := sendManyCmd.Bool("mine", false, "Mine immediately on the same node")
+	sendManyFee := sendManyCmd.Int("fee", 0, "Transaction fee")
+	sendManyRBF := sendManyCmd.Bool("rbf", false, "Allow the transaction to be replaced by one paying a higher fee")
+	sendManyStrategy := sendManyCmd.String("strategy", "", "Coin selection strategy: bnb, largest, smallest or random (default bnb)")
+	sendManySigner := sendManyCmd.String("signer", "", "Signer: local, external:COMMAND or pkcs11:MODULE[:SLOT]")
+	listUnspentAddress := listUnspentCmd.String("address", "", "Wallet or script address")
+	bumpFeeTxid := bumpFeeCmd.String("txid", "", "Transaction to replace")
+	bumpFeeFee := bumpFeeCmd.Int("fee", 0, "New transaction fee")
+	bumpFeeRPCPort := bumpFeeCmd.String("rpcport", "", "JSON-RPC port of the running node")
+	getXpubPath := getXpubCmd.String("path", hdAccountPath, "HD derivation path, e.g. m/0'/0")
+	restoreWalletWords := restoreWalletCmd.String("words", "", "Mnemonic phrase")
+	restoreWalletPassphrase := restoreWalletCmd.String("passphrase", "", "Passphrase given when the mnemonic was created")
+	encryptWalletPassphrase := encryptWalletCmd.String("passphrase", "", "Passphrase to encrypt the wallet with")
+	walletPassphrasePassphrase := walletPassphraseCmd.String("passphrase", "", "Wallet passphrase")
+	walletPassphraseTimeout := walletPassphraseCmd.Int("timeout", 0, "Seconds to keep the wallet unlocked")
+	walletPassphraseRPCPort := walletPassphraseCmd.String("rpcport", "", "JSON-RPC port of the running node")
+	walletLockRPCPort := walletLockCmd.String("rpcport", "", "JSON-RPC port of the running node")
+	dumpPrivKeyAddress := dumpPrivKeyCmd.String("address", "", "Wallet address")
+	importPrivKeyKey := importPrivKeyCmd.String("key", "", "Private key in Base58Check encoding")
+	importPrivKeyRescan := importPrivKeyCmd.Bool("rescan", false, "Rescan the blockchain for outputs of the key")
+	importAddressAddress := importAddressCmd.String("address", "", "Address to watch")
+	importXpubXpub := importXpubCmd.String("xpub", "", "Extended public key")
+	listTransactionsAddress := listTransactionsCmd.String("address", "", "Only list transactions of this wallet address")
+	listTransactionsCount := listTransactionsCmd.Int("count", 10, "Number of transactions to list, 0 for all")
+	listTransactionsRescan := listTransactionsCmd.Bool("rescan", false, "Scan the whole blockchain again")
+	setLabelAddress := setLabelCmd.String("address", "", "Address to label")
+	setLabelTxid := setLabelCmd.String("txid", "", "Transaction to label")
+	setLabelLabel := setLabelCmd.String("label", "", "Label, empty to remove")
+	loadWalletName := loadWalletCmd.String("name", "", "Wallet name")
+	loadWalletRPCPort := loadWalletCmd.String("rpcport", "", "JSON-RPC port of the running node")
+	unloadWalletName := unloadWalletCmd.String("name", "", "Wallet name")
+	unloadWalletRPCPort := unloadWalletCmd.String("rpcport", "", "JSON-RPC port of the running node")
+	printChainFrom := printChainCmd.Int("from", 0, "Lowest block height to print")
+	printChainTo := printChainCmd.Int("to", -1, "Highest block height to print, the tip if negative")
+	getBlockHashHeight := getBlockHashCmd.Int("height", -1, "Block height")
+	reindexUTXOTxIndex := reindexUTXOCmd.String("txindex", "", "on to enable the transaction index, off to drop it")
+	getTransactionTxid := getTransactionCmd.String("txid", "", "Transaction ID")
+	getAddressHistoryAddress := getAddressHistoryCmd.String("address", "", "Address to look up")
+	createMultiSigTxFrom := createMultiSigTxCmd.String("from", "", "Source multisig address")
+	createMultiSigTxTo := createMultiSigTxCmd.String("to", "", "Destination wallet address")
+	createMultiSigTxAmount := createMultiSigTxCmd.Int("amount", 0, "Amount to send")
+	createMultiSigTxFile := createMultiSigTxCmd.String("file", "", "File to save the unsigned transaction to")
+	sendMultiSigTxFile := sendMultiSigTxCmd.String("file", "", "Signed transaction file")
+	sendMultiSigTxMine := sendMultiSigTxCmd.Bool("mine", false, "Mine immediately on the same node")
+	signMultiSigTxFile := signMultiSigTxCmd.String("file", "", "Unsigned or partially signed transaction file")
+	signMultiSigTxAddress := signMultiSigTxCmd.String("address", "", "Wallet address whose key signs the transaction")
+
+	//操作钱包的命令都可以用 -wallet NAME 指定节点上的命名钱包
+	walletCmds := []*flag.FlagSet{getBalanceCmd, getPubKeyCmd, createMultiSigCmd, createWalletCmd, listAddressesCmd, sendCmd,
+		createScriptAddressCmd, spendScriptCmd, sendDataCmd, createPSTCmd, signPSTCmd, signRawTxCmd, sendManyCmd, listUnspentCmd,
+		bumpFeeCmd, getXpubCmd, restoreWalletCmd, encryptWalletCmd, walletPassphraseCmd, walletLockCmd, dumpPrivKeyCmd,
+		importPrivKeyCmd, importAddressCmd, importXpubCmd, listTransactionsCmd, setLabelCmd, createMultiSigTxCmd, signMultiSigTxCmd}
+	for _, cmd := range walletCmds {
+		cmd.StringVar(&cli.walletName, "wallet", "", "Wallet name, the default wallet of the node if empty")
+	}
+	createWalletCmd.StringVar(&cli.walletName, "name", "", "Name of the wallet to create, same as -wallet")
 
 	switch os.Args[1] {
 	case "getbalance":
@@ -65,11 +255,21 @@ func (cli *CLI) Run() {
 		if err != nil {
 			log.Panic(err)
 		}
+	case "getpubkey":
+		err := getPubKeyCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	case "createblockchain":
 		err := createBlockchainCmd.Parse(os.Args[2:])
 		if err != nil {
 			log.Panic(err)
 		}
+	case "createmultisig":
+		err := createMultiSigCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	case "createwallet":
 		err := createWalletCmd.Parse(os.Args[2:])
 		if err != nil {
@@ -100,17 +300,199 @@ func (cli *CLI) Run() {
 		if err != nil {
 			log.Panic(err)
 		}
+	case "createscriptaddress":
+		err := createScriptAddressCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "spendscript":
+		err := spendScriptCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "senddata":
+		err := sendDataCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "finddata":
+		err := findDataCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "createpst":
+		err := createPSTCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "signpst":
+		err := signPSTCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "combinepst":
+		err := combinePSTCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "finalizepst":
+		err := finalizePSTCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "broadcastpst":
+		err := broadcastPSTCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "createrawtransaction":
+		err := createRawTxCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "decoderawtransaction":
+		err := decodeRawTxCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "signrawtransaction":
+		err := signRawTxCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "sendrawtransaction":
+		err := sendRawTxCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "sendmany":
+		err := sendManyCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "listunspent":
+		err := listUnspentCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "bumpfee":
+		err := bumpFeeCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "getxpub":
+		err := getXpubCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "restorewallet":
+		err := restoreWalletCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "encryptwallet":
+		err := encryptWalletCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "walletpassphrase":
+		err := walletPassphraseCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "walletlock":
+		err := walletLockCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "dumpprivkey":
+		err := dumpPrivKeyCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "importprivkey":
+		err := importPrivKeyCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "importaddress":
+		err := importAddressCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "importxpub":
+		err := importXpubCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "listtransactions":
+		err := listTransactionsCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "setlabel":
+		err := setLabelCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "loadwallet":
+		err := loadWalletCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "unloadwallet":
+		err := unloadWalletCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "getblockhash":
+		err := getBlockHashCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "gettransaction":
+		err := getTransactionCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "getaddresshistory":
+		err := getAddressHistoryCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "createmultisigtx":
+		err := createMultiSigTxCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "sendmultisigtx":
+		err := sendMultiSigTxCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "signmultisigtx":
+		err := signMultiSigTxCmd.Parse(os.Args[2:])
+		if err != nil {
+			log.Panic(err)
+		}
 	default:
 		cli.printUsage()
 		os.Exit(1)
 	}
+	if !ValidateWalletName(cli.walletName) {
+		log.Panic("ERROR: Wallet name may only contain letters, digits, - and _")
+	}
 
 	if getBalanceCmd.Parsed() {
-		if *getBalanceAddress == "" {
-			getBalanceCmd.Usage()
+		cli.getBalance(*getBalanceAddress, nodeID)
+	}
+
+	if getPubKeyCmd.Parsed() {
+		if *getPubKeyAddress == "" {
+			getPubKeyCmd.Usage()
 			os.Exit(1)
 		}
-		cli.getBalance(*getBalanceAddress, nodeID)
+		cli.getPubKey(*getPubKeyAddress, nodeID)
 	}
 
 	if createBlockchainCmd.Parsed() {
@@ -121,8 +503,16 @@ func (cli *CLI) Run() {
 		cli.createBlockchain(*createBlockchainAddress, nodeID)
 	}
 
+	if createMultiSigCmd.Parsed() {
+		if *createMultiSigM <= 0 || *createMultiSigPubKeys == "" {
+			createMultiSigCmd.Usage()
+			os.Exit(1)
+		}
+		cli.createMultiSig(*createMultiSigM, *createMultiSigPubKeys, nodeID)
+	}
+
 	if createWalletCmd.Parsed() {
-		cli.createWallet(nodeID)
+		cli.createWallet(*createWalletMnemonic, *createWalletPassphrase, *createWalletKeyType, nodeID)
 	}
 
 	if listAddressesCmd.Parsed() {
@@ -130,20 +520,26 @@ func (cli *CLI) Run() {
 	}
 
 	if printChainCmd.Parsed() {
-		cli.printChain(nodeID)
+		cli.printChain(*printChainFrom, *printChainTo, nodeID)
 	}
 
 	if reindexUTXOCmd.Parsed() {
-		cli.reindexUTXO(nodeID)
+		cli.reindexUTXO(*reindexUTXOTxIndex, nodeID)
 	}
 
 	if sendCmd.Parsed() {
-		if *sendFrom == "" || *sendTo == "" || *sendAmount <= 0 {
+		if *sendFrom == "" || *sendTo == "" || *sendAmount <= 0 || *sendLockTime < 0 || *sendFee < 0 {
 			sendCmd.Usage()
 			os.Exit(1)
 		}
 
-		cli.send(*sendFrom, *sendTo, *sendAmount, nodeID, *sendMine)
+		opts := PaymentOptions{LockTime: *sendLockTime, Fee: *sendFee, Replaceable: *sendRBF}
+		signer, err := ParseSigner(*sendSigner)
+		if err != nil {
+			log.Panic("ERROR: ", err)
+		}
+		opts.Signer = signer
+		cli.send(*sendFrom, *sendTo, *sendAmount, opts, *sendStrategy, *sendInputs, nodeID, *sendMine, *sendBare)
 	}
 
 	if startNodeCmd.Parsed() {
@@ -152,6 +548,287 @@ func (cli *CLI) Run() {
 			startNodeCmd.Usage()
 			os.Exit(1)
 		}
-		cli.startNode(nodeID, *startNodeMiner)
+		cli.startNode(nodeID, *startNodeMiner, *startNodeRPCPort)
+	}
+
+	if createScriptAddressCmd.Parsed() {
+		if *createScriptAddressType == "" && *createScriptAddressScript == "" {
+			createScriptAddressCmd.Usage()
+			os.Exit(1)
+		}
+		cli.createScriptAddress(*createScriptAddressType, *createScriptAddressAddress, *createScriptAddressHash, *createScriptAddressLockTime, *createScriptAddressSequence, *createScriptAddressScript, nodeID)
+	}
+
+	if spendScriptCmd.Parsed() {
+		if *spendScriptFrom == "" || *spendScriptTo == "" || *spendScriptAmount <= 0 {
+			spendScriptCmd.Usage()
+			os.Exit(1)
+		}
+		cli.spendScript(*spendScriptFrom, *spendScriptTo, *spendScriptAmount, *spendScriptPreimage, nodeID, *spendScriptMine)
+	}
+
+	if sendDataCmd.Parsed() {
+		if *sendDataFrom == "" || *sendDataHex == "" {
+			sendDataCmd.Usage()
+			os.Exit(1)
+		}
+		cli.sendData(*sendDataFrom, *sendDataHex, nodeID, *sendDataMine)
+	}
+
+	if findDataCmd.Parsed() {
+		if *findDataHex == "" {
+			findDataCmd.Usage()
+			os.Exit(1)
+		}
+		cli.findData(*findDataHex, nodeID)
+	}
+
+	if createPSTCmd.Parsed() {
+		if *createPSTFrom == "" || *createPSTTo == "" || *createPSTAmount <= 0 || *createPSTLockTime < 0 || *createPSTFile == "" {
+			createPSTCmd.Usage()
+			os.Exit(1)
+		}
+		cli.createPST(*createPSTFrom, *createPSTTo, *createPSTAmount, *createPSTLockTime, *createPSTFile, nodeID)
+	}
+
+	if signPSTCmd.Parsed() {
+		if *signPSTFile == "" {
+			signPSTCmd.Usage()
+			os.Exit(1)
+		}
+		cli.signPST(*signPSTFile, *signPSTAddress, nodeID)
+	}
+
+	if combinePSTCmd.Parsed() {
+		if *combinePSTFiles == "" || *combinePSTFile == "" {
+			combinePSTCmd.Usage()
+			os.Exit(1)
+		}
+		cli.combinePST(*combinePSTFiles, *combinePSTFile)
+	}
+
+	if finalizePSTCmd.Parsed() {
+		if *finalizePSTFile == "" {
+			finalizePSTCmd.Usage()
+			os.Exit(1)
+		}
+		cli.finalizePST(*finalizePSTFile)
+	}
+
+	if broadcastPSTCmd.Parsed() {
+		if *broadcastPSTFile == "" {
+			broadcastPSTCmd.Usage()
+			os.Exit(1)
+		}
+		cli.broadcastPST(*broadcastPSTFile, nodeID, *broadcastPSTMine)
+	}
+
+	if createRawTxCmd.Parsed() {
+		if *createRawTxInputs == "" || *createRawTxOutputs == "" || *createRawTxLockTime < 0 {
+			createRawTxCmd.Usage()
+			os.Exit(1)
+		}
+		cli.createRawTransaction(*createRawTxInputs, *createRawTxOutputs, *createRawTxLockTime)
+	}
+
+	if decodeRawTxCmd.Parsed() {
+		if *decodeRawTxHex == "" {
+			decodeRawTxCmd.Usage()
+			os.Exit(1)
+		}
+		cli.decodeRawTransaction(*decodeRawTxHex)
+	}
+
+	if signRawTxCmd.Parsed() {
+		if *signRawTxHex == "" {
+			signRawTxCmd.Usage()
+			os.Exit(1)
+		}
+		cli.signRawTransaction(*signRawTxHex, *signRawTxAddress, nodeID)
+	}
+
+	if sendRawTxCmd.Parsed() {
+		if *sendRawTxHex == "" {
+			sendRawTxCmd.Usage()
+			os.Exit(1)
+		}
+		cli.sendRawTransaction(*sendRawTxHex, nodeID, *sendRawTxMine)
+	}
+
+	if sendManyCmd.Parsed() {
+		if *sendManyFrom == "" || (*sendManyTo == "") == (*sendManyFile == "") || *sendManyFee < 0 {
+			sendManyCmd.Usage()
+			os.Exit(1)
+		}
+		opts := PaymentOptions{Fee: *sendManyFee, Replaceable: *sendManyRBF}
+		signer, err := ParseSigner(*sendManySigner)
+		if err != nil {
+			log.Panic("ERROR: ", err)
+		}
+		opts.Signer = signer
+		cli.sendMany(*sendManyFrom, *sendManyTo, *sendManyFile, opts, *sendManyStrategy, nodeID, *sendManyMine)
+	}
+
+	if listUnspentCmd.Parsed() {
+		cli.listUnspent(*listUnspentAddress, nodeID)
+	}
+
+	if bumpFeeCmd.Parsed() {
+		if *bumpFeeTxid == "" || *bumpFeeRPCPort == "" || *bumpFeeFee < 0 {
+			bumpFeeCmd.Usage()
+			os.Exit(1)
+		}
+		cli.bumpFee(*bumpFeeTxid, *bumpFeeFee, *bumpFeeRPCPort)
+	}
+
+	if getXpubCmd.Parsed() {
+		cli.getXpub(*getXpubPath, nodeID)
+	}
+
+	if restoreWalletCmd.Parsed() {
+		if *restoreWalletWords == "" {
+			restoreWalletCmd.Usage()
+			os.Exit(1)
+		}
+		cli.restoreWallet(*restoreWalletWords, *restoreWalletPassphrase, nodeID)
+	}
+
+	if encryptWalletCmd.Parsed() {
+		if *encryptWalletPassphrase == "" {
+			encryptWalletCmd.Usage()
+			os.Exit(1)
+		}
+		cli.encryptWallet(*encryptWalletPassphrase, nodeID)
+	}
+
+	if walletPassphraseCmd.Parsed() {
+		if *walletPassphrasePassphrase == "" || *walletPassphraseTimeout <= 0 || *walletPassphraseRPCPort == "" {
+			walletPassphraseCmd.Usage()
+			os.Exit(1)
+		}
+		cli.walletPassphrase(*walletPassphrasePassphrase, *walletPassphraseTimeout, *walletPassphraseRPCPort)
+	}
+
+	if walletLockCmd.Parsed() {
+		if *walletLockRPCPort == "" {
+			walletLockCmd.Usage()
+			os.Exit(1)
+		}
+		cli.walletLock(*walletLockRPCPort)
+	}
+
+	if dumpPrivKeyCmd.Parsed() {
+		if *dumpPrivKeyAddress == "" {
+			dumpPrivKeyCmd.Usage()
+			os.Exit(1)
+		}
+		cli.dumpPrivKey(*dumpPrivKeyAddress, nodeID)
+	}
+
+	if importPrivKeyCmd.Parsed() {
+		if *importPrivKeyKey == "" {
+			importPrivKeyCmd.Usage()
+			os.Exit(1)
+		}
+		cli.importPrivKey(*importPrivKeyKey, *importPrivKeyRescan, nodeID)
+	}
+
+	if importAddressCmd.Parsed() {
+		if *importAddressAddress == "" {
+			importAddressCmd.Usage()
+			os.Exit(1)
+		}
+		cli.importAddress(*importAddressAddress, nodeID)
+	}
+
+	if importXpubCmd.Parsed() {
+		if *importXpubXpub == "" {
+			importXpubCmd.Usage()
+			os.Exit(1)
+		}
+		cli.importXpub(*importXpubXpub, nodeID)
+	}
+
+	if listTransactionsCmd.Parsed() {
+		if *listTransactionsCount < 0 {
+			listTransactionsCmd.Usage()
+			os.Exit(1)
+		}
+		cli.listTransactions(*listTransactionsAddress, *listTransactionsCount, *listTransactionsRescan, nodeID)
+	}
+
+	if setLabelCmd.Parsed() {
+		if *setLabelAddress == "" && *setLabelTxid == "" {
+			setLabelCmd.Usage()
+			os.Exit(1)
+		}
+		cli.setLabel(*setLabelAddress, *setLabelTxid, *setLabelLabel, nodeID)
+	}
+
+	if loadWalletCmd.Parsed() {
+		if *loadWalletName == "" || *loadWalletRPCPort == "" {
+			loadWalletCmd.Usage()
+			os.Exit(1)
+		}
+		cli.loadWallet(*loadWalletName, *loadWalletRPCPort)
+	}
+
+	if unloadWalletCmd.Parsed() {
+		if *unloadWalletName == "" || *unloadWalletRPCPort == "" {
+			unloadWalletCmd.Usage()
+			os.Exit(1)
+		}
+		cli.unloadWallet(*unloadWalletName, *unloadWalletRPCPort)
+	}
+
+	if getBlockHashCmd.Parsed() {
+		if *getBlockHashHeight < 0 {
+			getBlockHashCmd.Usage()
+			os.Exit(1)
+		}
+		cli.getBlockHash(*getBlockHashHeight, nodeID)
+	}
+
+	if getTransactionCmd.Parsed() {
+		if *getTransactionTxid == "" {
+			getTransactionCmd.Usage()
+			os.Exit(1)
+		}
+		cli.getTransaction(*getTransactionTxid, nodeID)
+	}
+
+	if getAddressHistoryCmd.Parsed() {
+		if *getAddressHistoryAddress == "" {
+			getAddressHistoryCmd.Usage()
+			os.Exit(1)
+		}
+		cli.getAddressHistory(*getAddressHistoryAddress, nodeID)
+	}
+
+	if createMultiSigTxCmd.Parsed() {
+		if *createMultiSigTxFrom == "" || *createMultiSigTxTo == "" || *createMultiSigTxAmount <= 0 || *createMultiSigTxFile == "" {
+			createMultiSigTxCmd.Usage()
+			os.Exit(1)
+		}
+
+		cli.createMultiSigTx(*createMultiSigTxFrom, *createMultiSigTxTo, *createMultiSigTxAmount, *createMultiSigTxFile, nodeID)
+	}
+
+	if sendMultiSigTxCmd.Parsed() {
+		if *sendMultiSigTxFile == "" {
+			sendMultiSigTxCmd.Usage()
+			os.Exit(1)
+		}
+
+		cli.sendMultiSigTx(*sendMultiSigTxFile, nodeID, *sendMultiSigTxMine)
+	}
+
+	if signMultiSigTxCmd.Parsed() {
+		if *signMultiSigTxFile == "" || *signMultiSigTxAddress == "" {
+			signMultiSigTxCmd.Usage()
+			os.Exit(1)
+		}
+
+		cli.signMultiSigTx(*signMultiSigTxFile, *signMultiSigTxAddress, nodeID)
 	}
 }
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/rpc/jsonrpc"
+)
+
+// 提高交易池中交易的手续费：节点运行时独占数据库，通过节点的JSON-RPC服务替换交易
+func (cli *CLI) bumpFee(txid string, fee int, rpcPort string) {
+	client, err := jsonrpc.Dial(protocol, fmt.Sprintf("localhost:%s", rpcPort))
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	defer client.Close()
+
+	var reply BumpFeeReply
+	err = client.Call("Node.BumpFee", BumpFeeArgs{txid, fee, cli.walletName}, &reply)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+
+	fmt.Printf("Fee raised from %d to %d\n", reply.OldFee, reply.Fee)
+	fmt.Printf("Replacement transaction: %s\n", reply.Txid)
+}
@@ -10,11 +10,9 @@ func (cli *CLI) createBlockchain(address, nodeID string) {
 	if !ValidateAddress(address) {
 		log.Panic("ERROR: Address is not valid")
 	}
+	//utxo集合和数据索引随创世块一起写入
 	bc := CreateBlockchain(address, nodeID)
 	defer bc.db.Close()
 
-	UTXOSet := UTXOSet{bc}
-	UTXOSet.Reindex()
-
 	fmt.Println("Done!")
 }
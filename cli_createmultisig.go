@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// 创建M-of-N多签地址，公钥可以是hex编码的公钥，也可以是本地钱包中的地址
+func (cli *CLI) createMultiSig(m int, pubKeyList, nodeID string) {
+	wallets, _ := NewWallets(cli.walletID(nodeID))
+
+	var pubKeys [][]byte
+	for _, item := range strings.Split(pubKeyList, ",") {
+		item = strings.TrimSpace(item)
+		if wallet, ok := wallets.Wallets[item]; ok {
+			pubKeys = append(pubKeys, wallet.PublicKey)
+			continue
+		}
+
+		pubKey, err := hex.DecodeString(item)
+		if err != nil {
+			log.Panicf("ERROR: %s is neither a public key nor a wallet address", item)
+		}
+		if _, ok := decodePubKey(pubKey); !ok {
+			log.Panicf("ERROR: Invalid public key %s", item)
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+
+	if m < 1 || m > len(pubKeys) {
+		log.Panicf("ERROR: Cannot require %d of %d signatures", m, len(pubKeys))
+	}
+	redeemScript := NewMultiSigScript(m, pubKeys)
+	//P2SH花费时赎回脚本作为一个数据压入解锁脚本，受单个元素大小限制
+	if len(redeemScript) > maxScriptElementSize {
+		log.Panicf("ERROR: Redeem script is too big (%d > %d bytes), use fewer public keys", len(redeemScript), maxScriptElementSize)
+	}
+
+	address := wallets.AddScript(redeemScript)
+	wallets.SaveToFile(cli.walletID(nodeID))
+
+	fmt.Printf("Multisig address: %s\n", address)
+	fmt.Printf("Redeem script: %x\n", redeemScript)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+)
+
+// 创建P2SH地址：由赎回脚本的哈希生成，可以是哈希锁、时间锁、相对时间锁或任意脚本
+func (cli *CLI) createScriptAddress(kind, address, hashHex string, lockTime, sequence int64, scriptHex, nodeID string) {
+	var redeemScript []byte
+
+	switch kind {
+	case ScriptHashLock, ScriptTimeLock, ScriptRelLock:
+		if !ValidateAddress(address) {
+			log.Panic("ERROR: Address is not valid")
+		}
+		addressVersion, pubKeyHash := decodeAddress(address)
+		if addressVersion != version {
+			log.Panic("ERROR: Address must be a public key hash address")
+		}
+
+		switch kind {
+		case ScriptHashLock:
+			hash, err := hex.DecodeString(hashHex)
+			if err != nil || len(hash) != sha256Size {
+				log.Panic("ERROR: Hash must be a hex encoded SHA-256 digest")
+			}
+			redeemScript = NewHashLockScript(hash, pubKeyHash)
+		case ScriptTimeLock:
+			if lockTime <= 0 {
+				log.Panic("ERROR: Lock time must be a block height or a timestamp")
+			}
+			redeemScript = NewTimeLockScript(lockTime, pubKeyHash)
+		case ScriptRelLock:
+			if sequence <= 0 || sequence&^(SequenceLockTimeTypeFlag|SequenceLockTimeMask) != 0 {
+				log.Panic("ERROR: Sequence must be a BIP68 relative lock")
+			}
+			redeemScript = NewRelativeLockScript(sequence, pubKeyHash)
+		}
+	case "":
+		script, err := hex.DecodeString(scriptHex)
+		if err != nil || len(script) == 0 {
+			log.Panic("ERROR: Script must be hex encoded")
+		}
+		if _, err := parseScript(script); err != nil {
+			log.Panic(err)
+		}
+		redeemScript = script
+	default:
+		log.Panicf("ERROR: Unknown script type %s", kind)
+	}
+
+	if len(redeemScript) > maxScriptElementSize {
+		log.Panicf("ERROR: Redeem script is too big (%d > %d bytes)", len(redeemScript), maxScriptElementSize)
+	}
+
+	wallets, _ := NewWallets(cli.walletID(nodeID))
+	p2shAddress := wallets.AddScript(redeemScript)
+	wallets.SaveToFile(cli.walletID(nodeID))
+
+	fmt.Printf("Script address: %s\n", p2shAddress)
+	fmt.Printf("Redeem script: %s\n", DisasmScript(redeemScript))
+}
@@ -1,12 +1,42 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"log"
+)
 
 //创建钱包
-func (cli *CLI) createWallet(nodeID string) {
-	wallets, _ := NewWallets(nodeID)
-	address := wallets.CreateWallet()
-	wallets.SaveToFile(nodeID)
+//mnemonic：由新生成的助记词（及可选的密码）生成HD种子，只能用于还没有种子的钱包
+//keyTypeName：secp256k1时生成随机私钥，不由HD种子派生，只能放在没有种子的钱包中
+func (cli *CLI) createWallet(mnemonic bool, passphrase, keyTypeName, nodeID string) {
+	keyType, err := ParseKeyType(keyTypeName)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	if mnemonic && keyType != KeyTypeP256 {
+		log.Panic("ERROR: Only p256 addresses are derived from the mnemonic")
+	}
+	wallets, _ := NewWallets(cli.walletID(nodeID))
+	if mnemonic {
+		words, err := NewMnemonic(128)
+		if err != nil {
+			log.Panic(err)
+		}
+		seed, err := MnemonicToSeed(words, passphrase)
+		if err != nil {
+			log.Panic(err)
+		}
+		if err := wallets.SetSeed(seed); err != nil {
+			log.Panic("ERROR: ", err)
+		}
+		fmt.Println("Write down your mnemonic phrase, it restores every address of this wallet:")
+		fmt.Println(words)
+	}
+	address, err := wallets.CreateWalletOfType(keyType)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	wallets.SaveToFile(cli.walletID(nodeID))
 
 	fmt.Printf("Your new address: %s\n", address)
 }
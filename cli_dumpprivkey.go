@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// 导出钱包地址的私钥，可用importprivkey导入其他钱包
+func (cli *CLI) dumpPrivKey(address, nodeID string) {
+	wallets, err := NewWallets(cli.walletID(nodeID))
+	if err != nil {
+		log.Panic(err)
+	}
+	wallet, ok := wallets.Wallets[address]
+	if !ok {
+		log.Panic("ERROR: Address is not in the wallet")
+	}
+	if wallet.IsLocked() {
+		log.Panic("ERROR: ", ErrWalletLocked)
+	}
+
+	fmt.Println(EncodePrivateKey(wallet.PrivateKey))
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/rpc/jsonrpc"
+	"time"
+)
+
+// 用密码加密钱包：私钥和HD种子加密保存，之后签名前须先解锁
+func (cli *CLI) encryptWallet(passphrase, nodeID string) {
+	wallets, err := NewWallets(cli.walletID(nodeID))
+	if err != nil {
+		log.Panic(err)
+	}
+	err = wallets.Encrypt(passphrase)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	wallets.SaveToFile(cli.walletID(nodeID))
+
+	fmt.Println("Wallet encrypted. Set WALLET_PASSPHRASE or use walletpassphrase to unlock it before signing")
+}
+
+// 解锁运行中节点的钱包，timeout秒后自动锁定
+func (cli *CLI) walletPassphrase(passphrase string, timeout int, rpcPort string) {
+	client, err := jsonrpc.Dial(protocol, fmt.Sprintf("localhost:%s", rpcPort))
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	defer client.Close()
+
+	var reply WalletPassphraseReply
+	err = client.Call("Node.WalletPassphrase", WalletPassphraseArgs{passphrase, timeout, cli.walletName}, &reply)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+
+	fmt.Printf("Wallet unlocked until %s\n", time.Unix(reply.Expires, 0).Format(time.RFC3339))
+}
+
+// 立即锁定运行中节点的钱包
+func (cli *CLI) walletLock(rpcPort string) {
+	client, err := jsonrpc.Dial(protocol, fmt.Sprintf("localhost:%s", rpcPort))
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	defer client.Close()
+
+	err = client.Call("Node.WalletLock", WalletLockArgs{cli.walletName}, &WalletLockReply{})
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+
+	fmt.Println("Wallet locked")
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+)
+
+// 查询数据（如文档哈希）所在的交易和区块
+func (cli *CLI) findData(dataHex, nodeID string) {
+	data, err := hex.DecodeString(dataHex)
+	if err != nil || len(data) == 0 {
+		log.Panic("ERROR: Data must be hex encoded")
+	}
+
+	bc := NewBlockchain(nodeID)
+	defer bc.db.Close()
+
+	location, err := DataIndex{bc}.Find(data)
+	if err != nil {
+		log.Panic(err)
+	}
+	block, err := bc.GetBlock(location.BlockHash)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Transaction: %x\n", location.TxID)
+	fmt.Printf("Block:       %x\n", block.Hash)
+	fmt.Printf("Height:      %d\n", block.Height)
+	fmt.Printf("Timestamp:   %s\n", time.Unix(block.Timestamp, 0).UTC().Format(time.RFC3339))
+}
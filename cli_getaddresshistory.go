@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/hex"
+	"log"
+)
+
+// 地址交易记录的JSON表示
+type addressTxJSON struct {
+	Txid          string `json:"txid"`
+	Height        int    `json:"height"`
+	BlockHash     string `json:"blockhash"`
+	Confirmations int    `json:"confirmations"`
+}
+
+// 列出涉及地址的所有主链交易（收到或花费），由地址索引查询，从早到晚排列
+func (cli *CLI) getAddressHistory(address, nodeID string) {
+	if !ValidateAddress(address) {
+		log.Panic("ERROR: Address is not valid")
+	}
+
+	bc := NewBlockchain(nodeID)
+	defer bc.db.Close()
+
+	bestHeight := bc.GetBestHeight()
+	_, hash := decodeAddress(address)
+	history := []addressTxJSON{}
+	for _, tx := range (AddressIndex{bc}).History(hash) {
+		blockHash, err := bc.GetBlockHash(tx.Height)
+		if err != nil {
+			log.Panic(err)
+		}
+		history = append(history, addressTxJSON{hex.EncodeToString(tx.Txid), tx.Height, hex.EncodeToString(blockHash), bestHeight - tx.Height + 1})
+	}
+
+	printJSON(history)
+}
@@ -5,8 +5,12 @@ import (
 	"log"
 )
 
-// 查询余额
+// 查询余额；未指定地址时查询钱包（-wallet）中所有地址的余额之和
 func (cli *CLI) getBalance(address, nodeID string) {
+	if address == "" {
+		cli.getWalletBalance(nodeID)
+		return
+	}
 	// 校验地址是否合法
 	if !ValidateAddress(address) {
 		log.Panic("ERROR: Address is not valid")
@@ -19,7 +23,7 @@ func (cli *CLI) getBalance(address, nodeID string) {
 	balance := 0
 	pubKeyHash := Base58Decode([]byte(address))
 	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
-	// by公钥hash查找未花费utxo集合：公钥hash与ouput中的公钥hash相等
+	// by公钥hash在地址索引中查找未花费utxo集合
 	UTXOs := UTXOSet.FindUTXO(pubKeyHash)
 	// 统计未花费utxo集合的总额
 	for _, out := range UTXOs {
@@ -28,3 +32,26 @@ func (cli *CLI) getBalance(address, nodeID string) {
 
 	fmt.Printf("Balance of '%s': %d\n", address, balance)
 }
+
+// 钱包中所有地址（含P2SH地址和只读地址）的余额之和
+func (cli *CLI) getWalletBalance(nodeID string) {
+	wallets, err := NewWallets(cli.walletID(nodeID))
+	if err != nil {
+		log.Panic(err)
+	}
+	bc := NewBlockchain(nodeID)
+	UTXOSet := UTXOSet{bc}
+	defer bc.db.Close()
+
+	balance := 0
+	for address := range wallets.walletAddresses() {
+		_, hash := decodeAddress(address)
+		balance += sumUnspentOutputs(UTXOSet.FindUnspentOutputs(hash))
+	}
+
+	name := cli.walletName
+	if name == "" {
+		name = "default"
+	}
+	fmt.Printf("Balance of wallet '%s': %d\n", name, balance)
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// 查询主链上指定高度的区块hash
+func (cli *CLI) getBlockHash(height int, nodeID string) {
+	bc := NewBlockchain(nodeID)
+	defer bc.db.Close()
+
+	hash, err := bc.GetBlockHash(height)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+
+	fmt.Printf("%x\n", hash)
+}
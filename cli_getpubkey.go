@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// 查询钱包地址的公钥，供他人创建多签地址
+func (cli *CLI) getPubKey(address, nodeID string) {
+	wallets, err := NewWallets(cli.walletID(nodeID))
+	if err != nil {
+		log.Panic(err)
+	}
+	if _, ok := wallets.Wallets[address]; !ok {
+		log.Panic("ERROR: Address is not in the wallet")
+	}
+	wallet := wallets.GetWallet(address)
+
+	fmt.Printf("%x\n", wallet.PublicKey)
+}
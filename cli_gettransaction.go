@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+)
+
+// 查询交易及其确认数：主链上的交易显示所在区块，交易池中的交易确认数为0
+func (cli *CLI) getTransaction(txid, nodeID string) {
+	txID, err := hex.DecodeString(txid)
+	if err != nil {
+		log.Panic("ERROR: Transaction ID must be hex encoded")
+	}
+
+	bc := NewBlockchain(nodeID)
+	defer bc.db.Close()
+
+	tx, block, err := bc.findTransactionBlock(txID)
+	if err == nil {
+		fmt.Printf("Block:         %x\n", block.Hash)
+		fmt.Printf("Height:        %d\n", block.Height)
+		fmt.Printf("Confirmations: %d\n", bc.GetBestHeight()-block.Height+1)
+	} else {
+		var ok bool
+		tx, ok = LoadMempool(bc).Get(txID)
+		if !ok {
+			log.Panic("ERROR: ", err)
+		}
+		fmt.Printf("Confirmations: 0 (in the mempool)\n")
+	}
+	fmt.Println(tx)
+}
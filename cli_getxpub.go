@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// 查询HD钱包指定路径的扩展公钥：可在其他节点派生收款地址、跟踪余额，而不暴露私钥
+func (cli *CLI) getXpub(path, nodeID string) {
+	wallets, err := NewWallets(cli.walletID(nodeID))
+	if err != nil {
+		log.Panic(err)
+	}
+	key, err := wallets.DeriveKey(path)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+
+	fmt.Println(key.Neuter())
+}
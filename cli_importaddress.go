@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+// 导入只读地址：没有私钥，可以查询余额、列出未花费输出和创建未签名的交易
+func (cli *CLI) importAddress(address, nodeID string) {
+	wallets, err := NewWallets(cli.walletID(nodeID))
+	if err != nil && !os.IsNotExist(err) {
+		log.Panic(err)
+	}
+	err = wallets.ImportAddress(address)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	wallets.SaveToFile(cli.walletID(nodeID))
+
+	fmt.Printf("Watching %s\n", address)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+// 导入私钥；rescan时重新扫描区块链，找回该地址的交易记录，并统计其未花费输出
+func (cli *CLI) importPrivKey(key string, rescan bool, nodeID string) {
+	privKey, err := DecodePrivateKey(key)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	//没有钱包文件时新建
+	wallets, err := NewWallets(cli.walletID(nodeID))
+	if err != nil && !os.IsNotExist(err) {
+		log.Panic(err)
+	}
+	address, err := wallets.ImportPrivateKey(privKey)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	wallets.SaveToFile(cli.walletID(nodeID))
+
+	fmt.Printf("Imported %s\n", address)
+
+	if !rescan {
+		return
+	}
+	if !dbExists(fmt.Sprintf(dbFile, nodeID)) {
+		log.Panic("ERROR: No existing blockchain found. Create one first.")
+	}
+	bc := NewBlockchain(nodeID)
+	defer bc.db.Close()
+
+	//从创世块开始扫描钱包的交易记录，utxo集合不受影响
+	wallets.ScanTip = nil
+	wallets.Rescan(bc, LoadMempool(bc))
+	wallets.SaveToFile(cli.walletID(nodeID))
+	_, pubKeyHash := decodeAddress(address)
+	utxos := UTXOSet{bc}.FindUnspentOutputs(pubKeyHash)
+
+	fmt.Printf("Rescan found %d transactions and %d unspent outputs, balance %d\n", len(wallets.History(address)), len(utxos), sumUnspentOutputs(utxos))
+}
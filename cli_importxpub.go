@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+// 导入扩展公钥：扫描区块链找回其派生的已使用地址，作为只读地址跟踪
+func (cli *CLI) importXpub(xpub, nodeID string) {
+	wallets, err := NewWallets(cli.walletID(nodeID))
+	if err != nil && !os.IsNotExist(err) {
+		log.Panic(err)
+	}
+
+	//没有区块链时只加入第一个收款地址，之后再次导入可继续查找
+	used := make(map[string]bool)
+	if dbExists(fmt.Sprintf(dbFile, nodeID)) {
+		bc := NewBlockchain(nodeID)
+		used = usedAddresses(bc)
+		bc.db.Close()
+	}
+
+	found, err := wallets.ImportXpub(xpub, func(address string) bool { return used[address] }, hdGapLimit)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	wallets.SaveToFile(cli.walletID(nodeID))
+
+	fmt.Printf("Watching extended public key, %d used addresses found\n", found)
+}
@@ -7,13 +7,40 @@ import (
 
 //查询当前节点的所有钱包地址
 func (cli *CLI) listAddresses(nodeID string) {
-	wallets, err := NewWallets(nodeID)
+	wallets, err := NewWallets(cli.walletID(nodeID))
 	if err != nil {
 		log.Panic(err)
 	}
 	addresses := wallets.GetAddresses()
 
 	for _, address := range addresses {
+		//HD地址附带派生路径，非P-256的地址附带密钥类型
+		wallet := wallets.Wallets[address]
+		if wallet.Path != "" {
+			fmt.Printf("%s (%s)\n", address, wallet.Path)
+			continue
+		}
+		if keyType := walletKeyType(wallet.PublicKey); keyType != KeyTypeP256 {
+			fmt.Printf("%s (%s)\n", address, keyType)
+			continue
+		}
 		fmt.Println(address)
 	}
+
+	for address, script := range wallets.Scripts {
+		if m, pubKeys, ok := ExtractMultiSig(script); ok {
+			fmt.Printf("%s (multisig %d of %d)\n", address, m, len(pubKeys))
+			continue
+		}
+		fmt.Printf("%s (%s)\n", address, ScriptClass(script))
+	}
+
+	//只读地址：由扩展公钥派生的附带其路径
+	for address, watched := range wallets.WatchOnly {
+		if watched.Xpub != "" {
+			fmt.Printf("%s (watch-only %s)\n", address, watched.Path)
+			continue
+		}
+		fmt.Printf("%s (watch-only)\n", address)
+	}
 }
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"log"
+	"sort"
+)
+
+// 交易记录的JSON表示
+type walletTransactionJSON struct {
+	Txid           string            `json:"txid"`
+	Category       string            `json:"category"`
+	Amount         int               `json:"amount"` //余额的变化，含手续费
+	Fee            int               `json:"fee,omitempty"`
+	Balance        int               `json:"balance"` //该交易之后的余额
+	Confirmations  int               `json:"confirmations"`
+	BlockHash      string            `json:"blockhash,omitempty"`
+	Time           int64             `json:"time,omitempty"`
+	Addresses      []string          `json:"addresses"` //涉及的钱包地址
+	Counterparties []string          `json:"counterparties,omitempty"`
+	Label          string            `json:"label,omitempty"`
+	AddressLabels  map[string]string `json:"addresslabels,omitempty"`
+}
+
+// 列出钱包（或其中某个地址）最近的count笔交易及每笔交易后的余额
+// 先扫描上次之后的新区块和交易池，rescan时重新扫描全部区块
+func (cli *CLI) listTransactions(address string, count int, rescan bool, nodeID string) {
+	if address != "" && !ValidateAddress(address) {
+		log.Panic("ERROR: Address is not valid")
+	}
+	wallets, err := NewWallets(cli.walletID(nodeID))
+	if err != nil {
+		log.Panic(err)
+	}
+	if address != "" && !wallets.walletAddresses()[address] {
+		log.Panic("ERROR: Address is not in the wallet")
+	}
+
+	bc := NewBlockchain(nodeID)
+	defer bc.db.Close()
+	mempool := LoadMempool(bc)
+
+	if rescan {
+		wallets.ScanTip = nil
+	}
+	wallets.Rescan(bc, mempool)
+	wallets.SaveToFile(cli.walletID(nodeID))
+
+	bestHeight := bc.GetBestHeight()
+	history := wallets.History(address)
+	result := []walletTransactionJSON{}
+	balance := 0
+	for _, record := range history {
+		amount := record.Amount(address)
+		balance += amount
+
+		var addresses []string
+		for walletAddress := range record.Deltas {
+			addresses = append(addresses, walletAddress)
+		}
+		sort.Strings(addresses)
+		labels := make(map[string]string)
+		for _, a := range append(addresses, record.Counterparties...) {
+			if label, ok := wallets.Labels[a]; ok {
+				labels[a] = label
+			}
+		}
+
+		result = append(result, walletTransactionJSON{
+			Txid:           record.Txid,
+			Category:       record.Category(address),
+			Amount:         amount,
+			Fee:            record.Fee,
+			Balance:        balance,
+			Confirmations:  record.Confirmations(bestHeight),
+			BlockHash:      record.BlockHash,
+			Time:           record.Time,
+			Addresses:      addresses,
+			Counterparties: record.Counterparties,
+			Label:          wallets.Labels[record.Txid],
+			AddressLabels:  labels,
+		})
+	}
+	if count > 0 && len(result) > count {
+		result = result[len(result)-count:]
+	}
+
+	printJSON(result)
+}
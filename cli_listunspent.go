@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/hex"
+	"log"
+)
+
+// 未花费输出的JSON表示
+type unspentJSON struct {
+	Txid    string `json:"txid"`
+	Vout    int    `json:"vout"`
+	Address string `json:"address"`
+	Amount  int    `json:"amount"`
+	Type    string `json:"type"`
+}
+
+// 列出地址的未花费输出，供手动选币使用；未指定地址时列出钱包中所有地址的
+func (cli *CLI) listUnspent(address, nodeID string) {
+	var addresses []string
+	if address != "" {
+		if !ValidateAddress(address) {
+			log.Panic("ERROR: Address is not valid")
+		}
+		addresses = []string{address}
+	} else {
+		wallets, err := NewWallets(cli.walletID(nodeID))
+		if err != nil {
+			log.Panic(err)
+		}
+		addresses = wallets.GetAddresses()
+		for scriptAddress := range wallets.Scripts {
+			addresses = append(addresses, scriptAddress)
+		}
+		addresses = append(addresses, wallets.GetWatchOnlyAddresses()...)
+	}
+
+	bc := NewBlockchain(nodeID)
+	UTXOSet := UTXOSet{bc}
+	defer bc.db.Close()
+
+	unspent := []unspentJSON{}
+	for _, address := range addresses {
+		_, hash := decodeAddress(address)
+		for _, utxo := range sortedUnspentOutputs(UTXOSet.FindUnspentOutputs(hash)) {
+			script := utxo.Output.ScriptPubKey
+			unspent = append(unspent, unspentJSON{hex.EncodeToString(utxo.Txid), utxo.Vout, address, utxo.Output.Value, ScriptClass(script)})
+		}
+	}
+
+	printJSON(unspent)
+}
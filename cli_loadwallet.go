@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/rpc/jsonrpc"
+	"strings"
+)
+
+// 在运行中的节点上加载命名钱包，之后RPC调用可以指定该钱包
+func (cli *CLI) loadWallet(name, rpcPort string) {
+	cli.callWalletRPC("Node.LoadWallet", name, rpcPort)
+}
+
+// 在运行中的节点上卸载钱包
+func (cli *CLI) unloadWallet(name, rpcPort string) {
+	cli.callWalletRPC("Node.UnloadWallet", name, rpcPort)
+}
+
+func (cli *CLI) callWalletRPC(method, name, rpcPort string) {
+	client, err := jsonrpc.Dial(protocol, fmt.Sprintf("localhost:%s", rpcPort))
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	defer client.Close()
+
+	var reply LoadWalletReply
+	err = client.Call(method, LoadWalletArgs{name}, &reply)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+
+	//默认钱包显示为default
+	names := make([]string, len(reply.Wallets))
+	for i, wallet := range reply.Wallets {
+		names[i] = wallet
+		if wallet == "" {
+			names[i] = "default"
+		}
+	}
+	fmt.Printf("Loaded wallets: %s\n", strings.Join(names, ", "))
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"log"
+)
+
+// createmultisigtx/signmultisigtx/sendmultisigtx：多签地址的旧命令，保留为部分签名交易命令的简单封装
+
+// 创建花费多签地址资金的交易，保存为部分签名交易文件
+func (cli *CLI) createMultiSigTx(from, to string, amount int, file, nodeID string) {
+	if !ValidateAddress(from) {
+		log.Panic("ERROR: Sender address is not valid")
+	}
+	wallets, _ := NewWallets(cli.walletID(nodeID))
+	redeemScript, ok := wallets.GetScript(from)
+	if !ok {
+		log.Panic("ERROR: Multisig address is not in the wallet, run createmultisig first")
+	}
+	if _, _, ok := ExtractMultiSig(redeemScript); !ok {
+		log.Panic("ERROR: Address is not a multisig address, use createpst")
+	}
+
+	cli.createPST(from, to, amount, 0, file, nodeID)
+}
+
+// 私钥持有者为部分签名交易添加签名
+func (cli *CLI) signMultiSigTx(file, address, nodeID string) {
+	cli.signPST(file, address, nodeID)
+}
+
+// 组装并发送签名完成的多签交易
+func (cli *CLI) sendMultiSigTx(file, nodeID string, mineNow bool) {
+	cli.finalizePST(file)
+	cli.broadcastPST(file, nodeID, mineNow)
+}
@@ -2,18 +2,28 @@ package main
 
 import (
 	"fmt"
+	"log"
 	"strconv"
 )
 
-//打印区块链信息
-func (cli *CLI) printChain(nodeID string) {
+// 打印区块链信息：从高度to到高度from的主链区块，to小于0时从链尾开始
+func (cli *CLI) printChain(from, to int, nodeID string) {
 	bc := NewBlockchain(nodeID)
 	defer bc.db.Close()
 
-	bci := bc.Iterator()
+	bestHeight := bc.GetBestHeight()
+	if to < 0 || to > bestHeight {
+		to = bestHeight
+	}
+	if from < 0 || from > to {
+		log.Panic("ERROR: Invalid height range")
+	}
 
-	for {
-		block := bci.Next()
+	for height := to; height >= from; height-- {
+		block, err := bc.GetBlockByHeight(height)
+		if err != nil {
+			log.Panic(err)
+		}
 
 		fmt.Printf("============ Block %x ============\n", block.Hash)
 		fmt.Printf("Height: %d\n", block.Height)
@@ -24,9 +34,5 @@ func (cli *CLI) printChain(nodeID string) {
 			fmt.Println(tx)
 		}
 		fmt.Printf("\n\n")
-
-		if len(block.PrevBlockHash) == 0 {
-			break
-		}
 	}
 }
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// 创建部分签名交易：只需转出地址，不需要私钥；P2SH地址须已在钱包中登记赎回脚本
+func (cli *CLI) createPST(from, to string, amount int, lockTime int64, file, nodeID string) {
+	if !ValidateAddress(from) {
+		log.Panic("ERROR: Sender address is not valid")
+	}
+	if !ValidateAddress(to) {
+		log.Panic("ERROR: Recipient address is not valid")
+	}
+
+	var redeemScript []byte
+	if addressVersion, _ := decodeAddress(from); addressVersion == scriptHashVersion {
+		wallets, _ := NewWallets(cli.walletID(nodeID))
+		script, ok := wallets.GetScript(from)
+		if !ok {
+			log.Panic("ERROR: Script address is not in the wallet")
+		}
+		if need, _, _ := (PartialInput{RedeemScript: script}).signers(); need == 0 {
+			log.Panic("ERROR: Redeem script cannot be signed with keys alone, use spendscript")
+		}
+		redeemScript = script
+	}
+
+	bc := NewBlockchain(nodeID)
+	UTXOSet := UTXOSet{bc}
+	defer bc.db.Close()
+
+	ptx := NewPartialTransaction(from, redeemScript, []TXOutput{*NewTXOutput(amount, to)}, lockTime, &UTXOSet)
+	ptx.SaveToFile(file)
+
+	fmt.Println(ptx)
+	fmt.Printf("Unsigned transaction saved to %s\n", file)
+}
+
+// 签名部分签名交易：无需区块链数据库，可在离线机器上完成
+// 指定地址时只用该地址的私钥，否则用钱包中所有能签名的私钥
+func (cli *CLI) signPST(file, address, nodeID string) {
+	ptx, err := LoadPartialTransaction(file)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets, err := NewWallets(cli.walletID(nodeID))
+	if err != nil {
+		log.Panic(err)
+	}
+	if wallets.IsLocked() {
+		log.Panic("ERROR: ", ErrWalletLocked)
+	}
+
+	addresses := wallets.GetAddresses()
+	if address != "" {
+		if _, ok := wallets.Wallets[address]; !ok {
+			log.Panic("ERROR: Address is not in the wallet")
+		}
+		addresses = []string{address}
+	}
+	//签名前核对输入金额、输出和手续费
+	fmt.Println(ptx)
+
+	signed := 0
+	for _, address := range addresses {
+		wallet := wallets.GetWallet(address)
+		signed += ptx.Sign(wallet.PrivateKey)
+	}
+	if signed == 0 {
+		log.Panic("ERROR: No key in the wallet can sign any input")
+	}
+	ptx.SaveToFile(file)
+
+	fmt.Printf("Added %d signatures\n", signed)
+	if ptx.IsComplete() {
+		fmt.Println("Transaction is fully signed, run finalizepst.")
+	}
+}
+
+// 合并多个签名者分别签名的部分签名交易
+func (cli *CLI) combinePST(files, file string) {
+	var combined *PartialTransaction
+
+	for _, name := range strings.Split(files, ",") {
+		ptx, err := LoadPartialTransaction(strings.TrimSpace(name))
+		if err != nil {
+			log.Panic(err)
+		}
+		if combined == nil {
+			combined = ptx
+			continue
+		}
+		if err := combined.Combine(ptx); err != nil {
+			log.Panic(err)
+		}
+	}
+	combined.SaveToFile(file)
+
+	fmt.Println(combined)
+	fmt.Printf("Combined transaction saved to %s\n", file)
+}
+
+// 组装解锁脚本：签名数达到要求后，用携带的被花费输出校验每个输入
+func (cli *CLI) finalizePST(file string) {
+	ptx, err := LoadPartialTransaction(file)
+	if err != nil {
+		log.Panic(err)
+	}
+	tx, err := ptx.Finalize()
+	if err != nil {
+		log.Panic(err)
+	}
+	ptx.SaveToFile(file)
+
+	fmt.Printf("Transaction %x is finalized, run broadcastpst.\n", tx.ID)
+}
+
+// 广播组装完成的交易
+func (cli *CLI) broadcastPST(file, nodeID string, mineNow bool) {
+	ptx, err := LoadPartialTransaction(file)
+	if err != nil {
+		log.Panic(err)
+	}
+	if !ptx.IsFinalized() {
+		log.Panic("ERROR: Transaction is not finalized, run finalizepst")
+	}
+	tx := &ptx.Tx
+
+	bc := NewBlockchain(nodeID)
+	defer bc.db.Close()
+	//与send一致经过交易池：以链上和交易池中的数据校验签名、被花费的输出是否未花费和时间锁；挖矿奖励给转出地址
+	submitTransaction(bc, LoadMempool(bc), tx, payoutAddress(ptx.Inputs[0].PrevOut), mineNow)
+
+	fmt.Println("Success!")
+}
+
+// 被花费输出所属的地址，裸多签输出返回对应的P2SH地址
+func payoutAddress(prevOut TXOutput) string {
+	if address := ExtractAddress(prevOut.ScriptPubKey); address != "" {
+		return address
+	}
+
+	return scriptAddress(prevOut.ScriptPubKey)
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// 原始交易的hex及交易id
+type rawTransactionResult struct {
+	Txid string `json:"txid"`
+	Hex  string `json:"hex,omitempty"`
+}
+
+// 签名结果：complete表示所有输入均已通过校验
+type signRawTransactionResult struct {
+	Hex      string                    `json:"hex"`
+	Complete bool                      `json:"complete"`
+	Errors   []signRawTransactionError `json:"errors,omitempty"`
+}
+
+// 未能签名或未通过校验的输入
+type signRawTransactionError struct {
+	Txid  string `json:"txid"`
+	Vout  int    `json:"vout"`
+	Error string `json:"error"`
+}
+
+// 按指定的输入和输出创建未签名的交易，不自动选择输入，也不找零
+// 输入格式 txid:vout[:sequence]，输出格式 address:amount 或 data:hex
+func (cli *CLI) createRawTransaction(inputsArg, outputsArg string, lockTime int64) {
+	var inputs []TXInput
+	var outputs []TXOutput
+
+	//锁定时间不为0时，输入默认不使用最终序列号，否则锁定时间不生效
+	var defaultSequence uint32 = SequenceFinal
+	if lockTime != 0 {
+		defaultSequence = SequenceFinal - 1
+	}
+
+	for _, arg := range strings.Split(inputsArg, ",") {
+		parts := strings.Split(strings.TrimSpace(arg), ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			log.Panicf("ERROR: Invalid input %q, expected txid:vout[:sequence]", arg)
+		}
+		txID, err := hex.DecodeString(parts[0])
+		if err != nil || len(txID) == 0 {
+			log.Panicf("ERROR: Invalid input txid %q", parts[0])
+		}
+		vout, err := strconv.Atoi(parts[1])
+		if err != nil || vout < 0 {
+			log.Panicf("ERROR: Invalid input vout %q", parts[1])
+		}
+		sequence := defaultSequence
+		if len(parts) == 3 {
+			seq, err := strconv.ParseUint(parts[2], 10, 32)
+			if err != nil {
+				log.Panicf("ERROR: Invalid input sequence %q", parts[2])
+			}
+			sequence = uint32(seq)
+		}
+		inputs = append(inputs, TXInput{txID, vout, nil, sequence})
+	}
+
+	for _, arg := range strings.Split(outputsArg, ",") {
+		parts := strings.Split(strings.TrimSpace(arg), ":")
+		if len(parts) != 2 {
+			log.Panicf("ERROR: Invalid output %q, expected address:amount or data:hex", arg)
+		}
+		if parts[0] == "data" {
+			data, err := hex.DecodeString(parts[1])
+			if err != nil || len(data) > maxDataCarrierSize {
+				log.Panicf("ERROR: Invalid data output %q", parts[1])
+			}
+			outputs = append(outputs, TXOutput{0, NewNullDataScript(data)})
+			continue
+		}
+		if !ValidateAddress(parts[0]) {
+			log.Panicf("ERROR: Invalid output address %q", parts[0])
+		}
+		amount, err := strconv.Atoi(parts[1])
+		if err != nil || amount <= 0 {
+			log.Panicf("ERROR: Invalid output amount %q", parts[1])
+		}
+		outputs = append(outputs, *NewTXOutput(amount, parts[0]))
+	}
+
+	tx := Transaction{nil, inputs, outputs, lockTime}
+	tx.ID = tx.Hash()
+
+	printJSON(rawTransactionResult{hex.EncodeToString(tx.ID), EncodeRawTransaction(&tx)})
+}
+
+// 解码原始交易
+func (cli *CLI) decodeRawTransaction(rawHex string) {
+	tx, err := DecodeRawTransaction(rawHex)
+	if err != nil {
+		log.Panic("ERROR: Invalid raw transaction: ", err)
+	}
+
+	printJSON(NewTransactionJSON(tx))
+}
+
+// 用钱包私钥为原始交易签名：被花费的输出从交易池和区块链中查找，可以花费未确认的交易
+// 指定地址时只用该地址的私钥，否则用钱包中所有私钥；已能通过校验的输入保持不变
+func (cli *CLI) signRawTransaction(rawHex, address, nodeID string) {
+	tx, err := DecodeRawTransaction(rawHex)
+	if err != nil {
+		log.Panic("ERROR: Invalid raw transaction: ", err)
+	}
+	if tx.IsCoinbase() {
+		log.Panic("ERROR: Coinbase transactions cannot be signed")
+	}
+	wallets, err := NewWallets(cli.walletID(nodeID))
+	if err != nil {
+		log.Panic(err)
+	}
+	if wallets.IsLocked() {
+		log.Panic("ERROR: ", ErrWalletLocked)
+	}
+
+	bc := NewBlockchain(nodeID)
+	defer bc.db.Close()
+	view := MempoolUTXOView{UTXOSet{bc}, LoadMempool(bc)}
+
+	ptx := PartialTransaction{*tx, nil}
+	ptx.Tx.Vin = make([]TXInput, len(tx.Vin))
+	copy(ptx.Tx.Vin, tx.Vin)
+	for _, vin := range tx.Vin {
+		prevTx, err := view.FindTransaction(vin.Txid)
+		if err != nil {
+			log.Panicf("ERROR: Previous transaction %x is not found", vin.Txid)
+		}
+		if vin.Vout < 0 || vin.Vout >= len(prevTx.Vout) {
+			log.Panicf("ERROR: Previous transaction %x has no output %d", vin.Txid, vin.Vout)
+		}
+
+		in := NewPartialInput(&prevTx, vin.Vout, nil)
+		if ExtractScriptHash(in.PrevOut.ScriptPubKey) != nil {
+			in.RedeemScript, _ = wallets.GetScript(ExtractAddress(in.PrevOut.ScriptPubKey))
+		}
+		ptx.Inputs = append(ptx.Inputs, in)
+	}
+
+	addresses := wallets.GetAddresses()
+	if address != "" {
+		if _, ok := wallets.Wallets[address]; !ok {
+			log.Panic("ERROR: Address is not in the wallet")
+		}
+		addresses = []string{address}
+	}
+	for _, address := range addresses {
+		wallet := wallets.GetWallet(address)
+		ptx.Sign(wallet.PrivateKey)
+	}
+
+	result := signRawTransactionResult{Complete: true}
+	for inID, vin := range tx.Vin {
+		if tx.VerifyInput(inID, ptx.Inputs[inID].PrevOut.ScriptPubKey) == nil {
+			continue
+		}
+		err := ptx.FinalizeInput(inID)
+		if err != nil {
+			//保留原有的解锁脚本，便于排查签名问题
+			ptx.Tx.Vin[inID].ScriptSig = vin.ScriptSig
+			result.Complete = false
+			result.Errors = append(result.Errors, signRawTransactionError{hex.EncodeToString(vin.Txid), vin.Vout, err.Error()})
+		}
+	}
+	result.Hex = EncodeRawTransaction(&ptx.Tx)
+
+	printJSON(result)
+}
+
+// 校验并广播原始交易
+func (cli *CLI) sendRawTransaction(rawHex, nodeID string, mineNow bool) {
+	tx, err := DecodeRawTransaction(rawHex)
+	if err != nil {
+		log.Panic("ERROR: Invalid raw transaction: ", err)
+	}
+	if tx.IsCoinbase() {
+		log.Panic("ERROR: Coinbase transactions cannot be sent")
+	}
+
+	bc := NewBlockchain(nodeID)
+	defer bc.db.Close()
+	mempool := LoadMempool(bc)
+
+	//与send一致，挖矿奖励给转出地址；被花费的交易可以在链上或交易池中
+	vin := tx.Vin[0]
+	prevTx, err := MempoolUTXOView{UTXOSet{bc}, mempool}.FindTransaction(vin.Txid)
+	if err != nil || vin.Vout < 0 || vin.Vout >= len(prevTx.Vout) {
+		log.Panic("ERROR: ", ErrMissingInputs)
+	}
+	//经过交易池校验签名、输出是否未花费和时间锁后再挖矿或广播
+	submitTransaction(bc, mempool, tx, payoutAddress(prevTx.Vout[vin.Vout]), mineNow)
+
+	printJSON(rawTransactionResult{Txid: hex.EncodeToString(tx.ID)})
+}
+
+// 以缩进的JSON格式打印
+func printJSON(v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println(string(data))
+}
@@ -1,13 +1,27 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"log"
+)
 
-//重新调整utxo
-func (cli *CLI) reindexUTXO(nodeID string) {
+//重新调整utxo，txindex为on或off时同时启用或停用交易索引
+func (cli *CLI) reindexUTXO(txIndex string, nodeID string) {
 	bc := NewBlockchain(nodeID)
+	defer bc.db.Close()
 	UTXOSet := UTXOSet{bc}
-	UTXOSet.Reindex()
+	switch txIndex {
+	case "":
+	case "on", "off":
+		TxIndex{bc}.SetEnabled(txIndex == "on")
+	default:
+		log.Panic("ERROR: -txindex must be on or off")
+	}
+	bc.Reindex()
 
 	count := UTXOSet.CountTransactions()
 	fmt.Printf("Done! There are %d transactions in the UTXO set.\n", count)
+	if (TxIndex{bc}).Enabled() {
+		fmt.Println("The transaction index is enabled.")
+	}
 }
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// 由助记词恢复HD钱包，扫描区块链找回已使用的地址
+func (cli *CLI) restoreWallet(words, passphrase, nodeID string) {
+	seed, err := MnemonicToSeed(words, passphrase)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	wallets, _ := NewWallets(cli.walletID(nodeID))
+	if err := wallets.SetSeed(seed); err != nil {
+		log.Panic("ERROR: ", err)
+	}
+
+	//没有区块链时无法找回地址，之后用createwallet依次派生
+	used := make(map[string]bool)
+	if dbExists(fmt.Sprintf(dbFile, nodeID)) {
+		bc := NewBlockchain(nodeID)
+		used = usedAddresses(bc)
+		bc.db.Close()
+	}
+
+	found := wallets.DiscoverAddresses(func(address string) bool { return used[address] }, hdGapLimit)
+	wallets.SaveToFile(cli.walletID(nodeID))
+
+	fmt.Printf("Wallet restored, %d used addresses found\n", found)
+}
+
+// 区块链上所有收到过资金的地址
+func usedAddresses(bc *Blockchain) map[string]bool {
+	used := make(map[string]bool)
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+
+		for _, tx := range block.Transactions {
+			for _, out := range tx.Vout {
+				if address := ExtractAddress(out.ScriptPubKey); address != "" {
+					used[address] = true
+				}
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return used
+}
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"encoding/hex"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
 )
 
 // 转账
-func (cli *CLI) send(from, to string, amount int, nodeID string, mineNow bool) {
+// 指定inputs时只花费这些输出（手动选币），否则按strategy选币
+func (cli *CLI) send(from, to string, amount int, opts PaymentOptions, strategy, inputsArg, nodeID string, mineNow, bare bool) {
 	//校验转出和转入地址合法性
 	if !ValidateAddress(from) {
 		log.Panic("ERROR: Sender address is not valid")
@@ -14,31 +18,124 @@ func (cli *CLI) send(from, to string, amount int, nodeID string, mineNow bool) {
 	if !ValidateAddress(to) {
 		log.Panic("ERROR: Recipient address is not valid")
 	}
-	//加载区块链和utxo集合
+	//加载区块链、utxo集合和本地交易池：可以花费尚未确认的交易的找零
 	bc := NewBlockchain(nodeID)
-	UTXOSet := UTXOSet{bc}
 	defer bc.db.Close()
+	mempool := LoadMempool(bc)
+	view := MempoolUTXOView{UTXOSet{bc}, mempool}
 	//加载钱包
-	wallets, err := NewWallets(nodeID)
+	wallets, err := NewWallets(cli.walletID(nodeID))
 	if err != nil {
 		log.Panic(err)
 	}
-	wallet := wallets.GetWallet(from)
+	//使用外部签名器时只需要地址的公钥
+	wallet, err := wallets.SigningWallet(from, opts.Signer)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
 	//创建转账交易
-	tx := NewUTXOTransaction(&wallet, to, amount, &UTXOSet)
+	output := *NewTXOutput(amount, to)
+	if bare {
+		//裸多签：直接以多签脚本作为锁定脚本
+		redeemScript, ok := wallets.GetScript(to)
+		if !ok {
+			log.Panic("ERROR: Multisig address is not in the wallet")
+		}
+		output = TXOutput{amount, redeemScript}
+	}
+	//找零给新派生的地址
+	opts.Change = wallets.NewChangeAddress()
+	var tx *Transaction
+	if inputsArg != "" {
+		utxos := findWalletOutputs(view, &wallet, inputsArg)
+		tx = NewPaymentTransactionFromInputs(&wallet, utxos, []TXOutput{output}, opts, view)
+	} else {
+		opts.Selector, err = GetCoinSelector(strategy)
+		if err != nil {
+			log.Panic("ERROR: ", err)
+		}
+		tx = NewPaymentTransaction(&wallet, []TXOutput{output}, opts, view)
+	}
+	saveChangeAddress(wallets, tx, opts.Change, cli.walletID(nodeID))
+	submitTransaction(bc, mempool, tx, from, mineNow)
+
+	fmt.Println("Success!")
+}
+
+// 交易找零给了新派生的地址时保存钱包；未使用时不保存，下次仍派生同一个地址
+func saveChangeAddress(wallets *Wallets, tx *Transaction, change, walletID string) {
+	if change == "" {
+		return
+	}
+	pubKeyHash := HashPubKey(wallets.GetWallet(change).PublicKey)
+	for _, out := range tx.Vout {
+		if out.IsLockedWithKey(pubKeyHash) {
+			wallets.SaveToFile(walletID)
+			fmt.Printf("Change sent to %s\n", change)
+			return
+		}
+	}
+}
+
+// 交易先进入本地交易池，再挖矿打包或发送给节点；打包时一并打包其未确认的祖先交易
+func submitTransaction(bc *Blockchain, mempool *Mempool, tx *Transaction, minerAddress string, mineNow bool) {
+	//锁定时间未到、未确认交易链过长等交易被拒绝
+	if _, err := mempool.Accept(bc, tx); err != nil {
+		log.Panic("ERROR: ", err)
+	}
 	//判断是否挖矿
 	if mineNow {
-		//创建coinbase交易：pubkey为随机值，签名为空
-		cbTx := NewCoinbaseTX(from, "")
-		txs := []*Transaction{cbTx, tx}
-		//挖矿，产生新区块
+		txs := mempool.Package(tx.ID)
+		fees := 0
+		for _, ptx := range txs {
+			fee, _ := mempool.Fee(ptx.ID)
+			fees += fee
+		}
+		//创建coinbase交易：pubkey为随机值，签名为空；奖励加上打包的交易的手续费
+		txs = append([]*Transaction{NewFeeCoinbaseTX(minerAddress, fees)}, txs...)
+		//挖矿，产生新区块，utxo集合随区块一起更新
 		newBlock := bc.MineBlock(txs)
-		//更新utxo集合
-		UTXOSet.Update(newBlock)
+		mempool.RemoveForBlock(newBlock)
 	} else {
 		//发送交易给其中一个节点
 		sendTx(knownNodes[0], tx)
 	}
+	mempool.Save(bc)
+}
+
+// 解析手动选择的输出 txid:vout,...，须为钱包可花费的未花费输出
+func findWalletOutputs(view UTXOView, wallet *Wallet, inputsArg string) []UnspentOutput {
+	var utxos []UnspentOutput
+	pubKeyHash := HashPubKey(wallet.PublicKey)
+
+	for _, item := range strings.Split(inputsArg, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ":")
+		if len(parts) != 2 {
+			log.Panicf("ERROR: Invalid input %q, expected txid:vout", item)
+		}
+		txID, err := hex.DecodeString(parts[0])
+		if err != nil {
+			log.Panicf("ERROR: Invalid input txid %q", parts[0])
+		}
+		vout, err := strconv.Atoi(parts[1])
+		if err != nil {
+			log.Panicf("ERROR: Invalid input vout %q", parts[1])
+		}
+
+		utxo, ok := view.FindUnspentOutput(txID, vout)
+		if !ok {
+			log.Panicf("ERROR: Output %s is spent or does not exist", item)
+		}
+		if !utxo.Output.IsLockedWithKey(pubKeyHash) {
+			log.Panicf("ERROR: Output %s does not belong to the sender", item)
+		}
+		for _, selected := range utxos {
+			if selected.Vout == vout && hex.EncodeToString(selected.Txid) == parts[0] {
+				log.Panicf("ERROR: Output %s is selected twice", item)
+			}
+		}
+		utxos = append(utxos, utxo)
+	}
 
-	fmt.Println("Success!")
+	return utxos
 }
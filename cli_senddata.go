@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+)
+
+// 发送数据交易：数据写入不可花费的OP_RETURN输出，例如为文档哈希打上时间戳
+func (cli *CLI) sendData(from, dataHex, nodeID string, mineNow bool) {
+	if !ValidateAddress(from) {
+		log.Panic("ERROR: Sender address is not valid")
+	}
+	data, err := hex.DecodeString(dataHex)
+	if err != nil || len(data) == 0 {
+		log.Panic("ERROR: Data must be hex encoded")
+	}
+	if len(data) > maxDataCarrierSize {
+		log.Panicf("ERROR: Data is too big (%d > %d bytes)", len(data), maxDataCarrierSize)
+	}
+
+	bc := NewBlockchain(nodeID)
+	defer bc.db.Close()
+	mempool := LoadMempool(bc)
+
+	wallets, err := NewWallets(cli.walletID(nodeID))
+	if err != nil {
+		log.Panic(err)
+	}
+	if err := wallets.CheckSpendable(from); err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	wallet := wallets.GetWallet(from)
+	//数据输出价值为0，输入全部找零
+	opts := PaymentOptions{Change: wallets.NewChangeAddress()}
+	tx := NewPaymentTransaction(&wallet, []TXOutput{{0, NewNullDataScript(data)}}, opts, MempoolUTXOView{UTXOSet{bc}, mempool})
+	saveChangeAddress(wallets, tx, opts.Change, cli.walletID(nodeID))
+	submitTransaction(bc, mempool, tx, from, mineNow)
+
+	fmt.Printf("Data is sent in transaction %x\n", tx.ID)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// 批量转账：一笔交易支付多个收款人，只产生一个找零输出
+func (cli *CLI) sendMany(from, list, file string, opts PaymentOptions, strategy, nodeID string, mineNow bool) {
+	if !ValidateAddress(from) {
+		log.Panic("ERROR: Sender address is not valid")
+	}
+	var recipients []Recipient
+	var err error
+	if file != "" {
+		recipients, err = LoadRecipients(file)
+	} else {
+		recipients, err = ParseRecipients(list)
+	}
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	outputs, err := RecipientOutputs(recipients)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	opts.Selector, err = GetCoinSelector(strategy)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+
+	bc := NewBlockchain(nodeID)
+	defer bc.db.Close()
+	mempool := LoadMempool(bc)
+
+	wallets, err := NewWallets(cli.walletID(nodeID))
+	if err != nil {
+		log.Panic(err)
+	}
+	//使用外部签名器时只需要地址的公钥
+	wallet, err := wallets.SigningWallet(from, opts.Signer)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	opts.Change = wallets.NewChangeAddress()
+	tx := NewPaymentTransaction(&wallet, outputs, opts, MempoolUTXOView{UTXOSet{bc}, mempool})
+	saveChangeAddress(wallets, tx, opts.Change, cli.walletID(nodeID))
+	submitTransaction(bc, mempool, tx, from, mineNow)
+
+	fmt.Printf("Sent to %d recipients in transaction %x\n", len(recipients), tx.ID)
+}
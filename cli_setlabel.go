@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+)
+
+// 设置地址或交易的标签，在listtransactions中显示；标签为空时删除
+func (cli *CLI) setLabel(address, txid, label, nodeID string) {
+	if (address == "") == (txid == "") {
+		log.Panic("ERROR: Specify either -address or -txid")
+	}
+	key := address
+	if address != "" && !ValidateAddress(address) {
+		log.Panic("ERROR: Address is not valid")
+	}
+	if txid != "" {
+		txID, err := hex.DecodeString(txid)
+		if err != nil || len(txID) != 32 {
+			log.Panic("ERROR: Invalid txid")
+		}
+		key = hex.EncodeToString(txID)
+	}
+
+	wallets, err := NewWallets(cli.walletID(nodeID))
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets.SetLabel(key, label)
+	wallets.SaveToFile(cli.walletID(nodeID))
+
+	if label == "" {
+		fmt.Printf("Label of %s removed\n", key)
+		return
+	}
+	fmt.Printf("Labeled %s as %q\n", key, label)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+)
+
+// 花费P2SH地址的资金：提供赎回脚本并满足它
+func (cli *CLI) spendScript(from, to string, amount int, preimageHex, nodeID string, mineNow bool) {
+	if !ValidateAddress(from) {
+		log.Panic("ERROR: Sender address is not valid")
+	}
+	if !ValidateAddress(to) {
+		log.Panic("ERROR: Recipient address is not valid")
+	}
+	wallets, err := NewWallets(cli.walletID(nodeID))
+	if err != nil {
+		log.Panic(err)
+	}
+	redeemScript, ok := wallets.GetScript(from)
+	if !ok {
+		log.Panic("ERROR: Script address is not in the wallet")
+	}
+	//赎回脚本中的公钥哈希决定由哪个钱包签名
+	var pubKeyHash, preimage []byte
+	switch ScriptClass(redeemScript) {
+	case ScriptHashLock:
+		_, pubKeyHash, _ = ExtractHashLock(redeemScript)
+		preimage, err = hex.DecodeString(preimageHex)
+		if err != nil || len(preimage) == 0 {
+			log.Panic("ERROR: Hash lock requires the hex encoded -preimage")
+		}
+	case ScriptTimeLock:
+		_, pubKeyHash, _ = ExtractTimeLock(redeemScript)
+	case ScriptRelLock:
+		_, pubKeyHash, _ = ExtractRelativeLock(redeemScript)
+	case ScriptPubKeyHash:
+		pubKeyHash = ExtractPubKeyHash(redeemScript)
+	case ScriptMultiSig:
+		log.Panic("ERROR: Use createpst to spend from a multisig address")
+	default:
+		log.Panic("ERROR: Cannot sign for a non-standard redeem script")
+	}
+	signer := string(encodeAddress(version, pubKeyHash))
+	if _, ok := wallets.Wallets[signer]; !ok {
+		log.Panicf("ERROR: Key for %s is not in the wallet", signer)
+	}
+	wallet := wallets.GetWallet(signer)
+
+	bc := NewBlockchain(nodeID)
+	UTXOSet := UTXOSet{bc}
+	defer bc.db.Close()
+
+	tx := NewScriptHashTransaction(&wallet, redeemScript, preimage, to, amount, &UTXOSet)
+	if !bc.VerifyTransaction(tx) {
+		log.Panic("ERROR: Redeem script is not satisfied")
+	}
+
+	if mineNow {
+		cbTx := NewCoinbaseTX(signer, "")
+		txs := []*Transaction{cbTx, tx}
+
+		bc.MineBlock(txs)
+	} else {
+		sendTx(knownNodes[0], tx)
+	}
+
+	fmt.Println("Success!")
+}
@@ -6,7 +6,7 @@ import (
 )
 
 //启动节点
-func (cli *CLI) startNode(nodeID, minerAddress string) {
+func (cli *CLI) startNode(nodeID, minerAddress, rpcPort string) {
 	fmt.Printf("Starting node %s\n", nodeID)
 	if len(minerAddress) > 0 {
 		if ValidateAddress(minerAddress) {
@@ -15,5 +15,5 @@ func (cli *CLI) startNode(nodeID, minerAddress string) {
 			log.Panic("Wrong miner address!")
 		}
 	}
-	StartServer(nodeID, minerAddress)
+	StartServer(nodeID, minerAddress, rpcPort)
 }
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	crand "crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// 选币错误
+var ErrInsufficientFunds = errors.New("not enough funds")
+
+// 分支限界搜索的最大尝试次数
+const maxBranchAndBoundTries = 100000
+
+// 未花费的输出及其位置
+type UnspentOutput struct {
+	Txid   []byte
+	Vout   int
+	Output TXOutput
+}
+
+// 选币策略：从可花费的输出中选出总额不小于amount的一组输出
+type CoinSelector interface {
+	Select(utxos []UnspentOutput, amount int) ([]UnspentOutput, error)
+}
+
+// 可用的选币策略
+var coinSelectors = map[string]CoinSelector{
+	"largest":  LargestFirstSelector{},
+	"smallest": SmallestFirstSelector{},
+	"bnb":      BranchAndBoundSelector{},
+	"random":   RandomSelector{},
+}
+
+// 默认选币策略：优先寻找无需找零的组合
+var DefaultCoinSelector CoinSelector = BranchAndBoundSelector{}
+
+// 按名称获取选币策略，空名称返回默认策略
+func GetCoinSelector(name string) (CoinSelector, error) {
+	if name == "" {
+		return DefaultCoinSelector, nil
+	}
+	selector, ok := coinSelectors[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown coin selection strategy %q", name)
+	}
+
+	return selector, nil
+}
+
+// 优先选择金额大的输出，输入数最少
+type LargestFirstSelector struct{}
+
+// Select picks the largest outputs first
+func (LargestFirstSelector) Select(utxos []UnspentOutput, amount int) ([]UnspentOutput, error) {
+	sorted := sortedUnspentOutputs(utxos)
+	for i, j := 0, len(sorted)-1; i < j; i, j = i+1, j-1 {
+		sorted[i], sorted[j] = sorted[j], sorted[i]
+	}
+
+	return accumulate(sorted, amount)
+}
+
+// 优先选择金额小的输出，合并零散的输出
+type SmallestFirstSelector struct{}
+
+// Select picks the smallest outputs first
+func (SmallestFirstSelector) Select(utxos []UnspentOutput, amount int) ([]UnspentOutput, error) {
+	return accumulate(sortedUnspentOutputs(utxos), amount)
+}
+
+// 随机选择输出，避免暴露钱包中输出的规律
+type RandomSelector struct{}
+
+// Select picks outputs in random order
+func (RandomSelector) Select(utxos []UnspentOutput, amount int) ([]UnspentOutput, error) {
+	var seed int64
+	err := binary.Read(crand.Reader, binary.LittleEndian, &seed)
+	if err != nil {
+		return nil, err
+	}
+
+	shuffled := make([]UnspentOutput, len(utxos))
+	copy(shuffled, utxos)
+	rand.New(rand.NewSource(seed)).Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+
+	return accumulate(shuffled, amount)
+}
+
+// 分支限界：搜索总额恰好等于amount的组合，交易无需找零；找不到时退回金额优先
+type BranchAndBoundSelector struct{}
+
+// Select searches for an exact match and falls back to largest first
+func (BranchAndBoundSelector) Select(utxos []UnspentOutput, amount int) ([]UnspentOutput, error) {
+	sorted := sortedUnspentOutputs(utxos)
+	for i, j := 0, len(sorted)-1; i < j; i, j = i+1, j-1 {
+		sorted[i], sorted[j] = sorted[j], sorted[i]
+	}
+	//remaining[i]为第i个及之后所有输出的总额，用于剪枝
+	remaining := make([]int, len(sorted)+1)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + sorted[i].Output.Value
+	}
+	if remaining[0] < amount {
+		return nil, ErrInsufficientFunds
+	}
+
+	var selected []int
+	tries := 0
+	var search func(i, sum int) bool
+	search = func(i, sum int) bool {
+		tries++
+		if sum == amount {
+			return true
+		}
+		if i == len(sorted) || sum > amount || sum+remaining[i] < amount || tries > maxBranchAndBoundTries {
+			return false
+		}
+		//先包含第i个输出，再排除它
+		selected = append(selected, i)
+		if search(i+1, sum+sorted[i].Output.Value) {
+			return true
+		}
+		selected = selected[:len(selected)-1]
+
+		return search(i+1, sum)
+	}
+
+	if amount > 0 && search(0, 0) {
+		var result []UnspentOutput
+		for _, i := range selected {
+			result = append(result, sorted[i])
+		}
+		return result, nil
+	}
+
+	return LargestFirstSelector{}.Select(utxos, amount)
+}
+
+// 按顺序累加输出，直到总额不小于amount
+func accumulate(utxos []UnspentOutput, amount int) ([]UnspentOutput, error) {
+	var selected []UnspentOutput
+	total := 0
+
+	for _, utxo := range utxos {
+		if total >= amount && len(selected) > 0 {
+			break
+		}
+		selected = append(selected, utxo)
+		total += utxo.Output.Value
+	}
+	if total < amount || len(selected) == 0 {
+		return nil, ErrInsufficientFunds
+	}
+
+	return selected, nil
+}
+
+// 按金额升序排列，金额相同时按位置排列，保证结果确定
+func sortedUnspentOutputs(utxos []UnspentOutput) []UnspentOutput {
+	sorted := make([]UnspentOutput, len(utxos))
+	copy(sorted, utxos)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Output.Value != sorted[j].Output.Value {
+			return sorted[i].Output.Value < sorted[j].Output.Value
+		}
+		if c := bytes.Compare(sorted[i].Txid, sorted[j].Txid); c != 0 {
+			return c < 0
+		}
+		return sorted[i].Vout < sorted[j].Vout
+	})
+
+	return sorted
+}
+
+// 选中输出的总额
+func sumUnspentOutputs(utxos []UnspentOutput) int {
+	total := 0
+	for _, utxo := range utxos {
+		total += utxo.Output.Value
+	}
+
+	return total
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newUnspentOutputs(values ...int) []UnspentOutput {
+	var utxos []UnspentOutput
+	for i, value := range values {
+		utxos = append(utxos, UnspentOutput{[]byte{byte(i)}, 0, TXOutput{value, nil}})
+	}
+
+	return utxos
+}
+
+func selectedValues(utxos []UnspentOutput) []int {
+	var values []int
+	for _, utxo := range utxos {
+		values = append(values, utxo.Output.Value)
+	}
+
+	return values
+}
+
+func TestCoinSelectors(t *testing.T) {
+	utxos := newUnspentOutputs(5, 1, 8, 3, 2)
+
+	selected, err := LargestFirstSelector{}.Select(utxos, 9)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{8, 5}, selectedValues(selected))
+
+	selected, err = SmallestFirstSelector{}.Select(utxos, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1, 2, 3}, selectedValues(selected))
+
+	selected, err = BranchAndBoundSelector{}.Select(utxos, 9)
+	assert.Nil(t, err)
+	assert.Equal(t, 9, sumUnspentOutputs(selected), "exact match needs no change")
+
+	selected, err = BranchAndBoundSelector{}.Select(newUnspentOutputs(4, 6), 5)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{6}, selectedValues(selected), "falls back to largest first")
+
+	selected, err = RandomSelector{}.Select(utxos, 12)
+	assert.Nil(t, err)
+	assert.True(t, sumUnspentOutputs(selected) >= 12)
+
+	for name, selector := range coinSelectors {
+		_, err = selector.Select(utxos, 20)
+		assert.Equal(t, ErrInsufficientFunds, err, name)
+	}
+
+	_, err = GetCoinSelector("unknown")
+	assert.NotNil(t, err)
+}
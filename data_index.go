@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"log"
+)
+
+const dataIndexBucket = "dataindex"
+
+// DataIndex maps data carried by OP_RETURN outputs to the transaction
+// and block that first timestamped it
+type DataIndex struct {
+	Blockchain *Blockchain
+}
+
+// 数据所在的交易和区块
+type DataLocation struct {
+	TxID      []byte
+	BlockHash []byte
+}
+
+// Serialize serializes the DataLocation
+func (l DataLocation) Serialize() []byte {
+	var buff bytes.Buffer
+
+	enc := gob.NewEncoder(&buff)
+	err := enc.Encode(l)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return buff.Bytes()
+}
+
+// DeserializeDataLocation deserializes a DataLocation
+func DeserializeDataLocation(data []byte) DataLocation {
+	var location DataLocation
+
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	err := dec.Decode(&location)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return location
+}
+
+// 查询数据（如文档哈希）所在的交易和区块
+func (d DataIndex) Find(data []byte) (DataLocation, error) {
+	db := d.Blockchain.db
+
+	encoded := db.Get(dataIndexBucket, data)
+	if encoded == nil {
+		if bucketEmpty(db, dataIndexBucket) {
+			return DataLocation{}, errors.New("Data index is not built, run reindexutxo")
+		}
+		return DataLocation{}, errors.New("Data is not found")
+	}
+
+	return DeserializeDataLocation(encoded), nil
+}
+
+// 重建数据索引：从链尾向前遍历，较早的交易覆盖较晚的交易
+func (d DataIndex) Reindex() {
+	batch := &WriteBatch{}
+	batch.DeleteBucket(dataIndexBucket)
+
+	bci := d.Blockchain.Iterator()
+	for {
+		block := bci.Next()
+
+		d.index(batch, block, nil)
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	err := d.Blockchain.db.Write(batch)
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// 更新数据索引：新区块中的数据若已存在则保留最早的记录
+func (d DataIndex) Update(block *Block) {
+	batch := &WriteBatch{}
+	d.connect(batch, block)
+	err := d.Blockchain.db.Write(batch)
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// 把新区块对数据索引的修改加入批量写
+func (d DataIndex) connect(batch *WriteBatch, block *Block) {
+	d.index(batch, block, func(data []byte) bool {
+		return batch.Get(d.Blockchain.db, dataIndexBucket, data) != nil
+	})
+}
+
+// 撤销区块对数据索引的修改：删除指向该区块的记录
+func (d DataIndex) disconnect(batch *WriteBatch, block *Block) {
+	for _, transaction := range block.Transactions {
+		for _, out := range transaction.Vout {
+			data, ok := ExtractNullData(out.ScriptPubKey)
+			if !ok || len(data) == 0 {
+				continue
+			}
+			encoded := batch.Get(d.Blockchain.db, dataIndexBucket, data)
+			if encoded != nil && bytes.Equal(DeserializeDataLocation(encoded).BlockHash, block.Hash) {
+				batch.Delete(dataIndexBucket, data)
+			}
+		}
+	}
+}
+
+// 把区块中的数据加入批量写；exists不为nil时跳过已有记录的数据
+func (d DataIndex) index(batch *WriteBatch, block *Block, exists func(data []byte) bool) {
+	for _, transaction := range block.Transactions {
+		for _, out := range transaction.Vout {
+			data, ok := ExtractNullData(out.ScriptPubKey)
+			if !ok || len(data) == 0 {
+				continue
+			}
+			if exists != nil && exists(data) {
+				continue
+			}
+
+			location := DataLocation{transaction.ID, block.Hash}
+			batch.Put(dataIndexBucket, data, location.Serialize())
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// 分层确定性（HD）密钥：BIP32的派生方式，按SLIP-10用于P-256曲线
+// 从一个种子派生出所有私钥，备份种子即可恢复钱包中的所有地址
+
+// 序号不小于该值的子密钥为硬化派生：只能由父私钥派生
+const HardenedKeyStart = 0x80000000
+
+// 种子生成主密钥时HMAC使用的密钥（SLIP-10）
+var hdMasterKeySeed = []byte("Nist256p1 seed")
+
+// 扩展密钥序列化的版本号
+var (
+	hdPrivateKeyVersion = []byte{0x04, 0x88, 0xad, 0xe4} //xprv
+	hdPublicKeyVersion  = []byte{0x04, 0x88, 0xb2, 0x1e} //xpub
+)
+
+// 扩展密钥错误
+var (
+	ErrDeriveHardenedFromPublic = errors.New("cannot derive a hardened key from a public key")
+	ErrInvalidExtendedKey       = errors.New("invalid extended key")
+)
+
+// 扩展密钥：私钥或公钥，加上链码
+type ExtendedKey struct {
+	Key         []byte //私钥32字节，或压缩公钥33字节
+	ChainCode   []byte
+	Depth       byte
+	Fingerprint []byte //父密钥指纹：父公钥哈希的前4个字节
+	ChildNumber uint32
+	Private     bool
+}
+
+// 由种子生成主密钥
+func NewMasterKey(seed []byte) (*ExtendedKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, errors.New("seed must be 16 to 64 bytes")
+	}
+	curve := elliptic.P256()
+
+	data := seed
+	for {
+		mac := hmac.New(sha512.New, hdMasterKeySeed)
+		mac.Write(data)
+		I := mac.Sum(nil)
+		//IL为0或不小于曲线的阶时无效，用I重新计算
+		key := new(big.Int).SetBytes(I[:32])
+		if key.Sign() != 0 && key.Cmp(curve.Params().N) < 0 {
+			return &ExtendedKey{I[:32], I[32:], 0, []byte{0, 0, 0, 0}, 0, true}, nil
+		}
+		data = I
+	}
+}
+
+// 派生第i个子密钥，i不小于HardenedKeyStart时为硬化派生
+func (k *ExtendedKey) Child(i uint32) (*ExtendedKey, error) {
+	hardened := i >= HardenedKeyStart
+	if hardened && !k.Private {
+		return nil, ErrDeriveHardenedFromPublic
+	}
+	curve := elliptic.P256()
+	N := curve.Params().N
+
+	//硬化派生使用父私钥，否则使用父公钥
+	var data []byte
+	if hardened {
+		data = append([]byte{0x00}, k.Key...)
+	} else {
+		data = append([]byte{}, k.PublicKey()...)
+	}
+	data = appendUint32(data, i)
+
+	for {
+		mac := hmac.New(sha512.New, k.ChainCode)
+		mac.Write(data)
+		I := mac.Sum(nil)
+		IL := new(big.Int).SetBytes(I[:32])
+
+		child := &ExtendedKey{nil, I[32:], k.Depth + 1, k.fingerprint(), i, k.Private}
+		if IL.Cmp(N) < 0 {
+			if k.Private {
+				key := new(big.Int).Add(IL, new(big.Int).SetBytes(k.Key))
+				key.Mod(key, N)
+				if key.Sign() != 0 {
+					child.Key = paddedBytes(key, 32)
+					return child, nil
+				}
+			} else {
+				x, y := p256UnmarshalCompressed(k.Key)
+				ilx, ily := curve.ScalarBaseMult(I[:32])
+				cx, cy := curve.Add(x, y, ilx, ily)
+				if cx.Sign() != 0 || cy.Sign() != 0 {
+					child.Key = p256MarshalCompressed(cx, cy)
+					return child, nil
+				}
+			}
+		}
+		//派生结果无效（概率可忽略），按SLIP-10用IR重新计算
+		data = appendUint32(append([]byte{0x01}, I[32:]...), i)
+	}
+}
+
+// 按路径派生，如 m/0'/1/2，硬化派生用'或h标记
+func (k *ExtendedKey) Derive(path string) (*ExtendedKey, error) {
+	indexes, err := ParseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key := k
+	for _, i := range indexes {
+		key, err = key.Child(i)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+// 解析派生路径
+func ParseDerivationPath(path string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimSpace(path), "/")
+	if parts[0] != "m" {
+		return nil, fmt.Errorf("derivation path %q must start with m", path)
+	}
+
+	var indexes []uint32
+	for _, part := range parts[1:] {
+		var offset uint32
+		if strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h") {
+			offset = HardenedKeyStart
+			part = part[:len(part)-1]
+		}
+		i, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || i >= HardenedKeyStart {
+			return nil, fmt.Errorf("invalid index %q in derivation path %q", part, path)
+		}
+		indexes = append(indexes, uint32(i)+offset)
+	}
+
+	return indexes, nil
+}
+
+// 派生路径的字符串表示
+func FormatDerivationPath(indexes []uint32) string {
+	path := "m"
+	for _, i := range indexes {
+		if i >= HardenedKeyStart {
+			path += fmt.Sprintf("/%d'", i-HardenedKeyStart)
+		} else {
+			path += fmt.Sprintf("/%d", i)
+		}
+	}
+
+	return path
+}
+
+// 扩展公钥：只能派生非硬化的子公钥，可用于只读钱包
+func (k *ExtendedKey) Neuter() *ExtendedKey {
+	if !k.Private {
+		return k
+	}
+
+	return &ExtendedKey{k.PublicKey(), k.ChainCode, k.Depth, k.Fingerprint, k.ChildNumber, false}
+}
+
+// 压缩公钥
+func (k *ExtendedKey) PublicKey() []byte {
+	if !k.Private {
+		return k.Key
+	}
+	x, y := elliptic.P256().ScalarBaseMult(k.Key)
+
+	return p256MarshalCompressed(x, y)
+}
+
+// 钱包使用的私钥
+func (k *ExtendedKey) ECPrivateKey() (ecdsa.PrivateKey, error) {
+	if !k.Private {
+		return ecdsa.PrivateKey{}, errors.New("extended key is not private")
+	}
+
+	return privateKeyFromBytes(k.Key), nil
+}
+
+// 钱包使用的公钥编码（X坐标和Y坐标各补齐到32字节后拼接），地址由其生成
+func (k *ExtendedKey) WalletPublicKey() []byte {
+	x, y := p256UnmarshalCompressed(k.PublicKey())
+
+	return encodePubKey(ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y})
+}
+
+// 序列化：Base58Check(版本号 + 深度 + 父密钥指纹 + 序号 + 链码 + 密钥)
+func (k *ExtendedKey) String() string {
+	var payload []byte
+	if k.Private {
+		payload = append(payload, hdPrivateKeyVersion...)
+	} else {
+		payload = append(payload, hdPublicKeyVersion...)
+	}
+	payload = append(payload, k.Depth)
+	payload = append(payload, k.Fingerprint...)
+	payload = appendUint32(payload, k.ChildNumber)
+	payload = append(payload, k.ChainCode...)
+	if k.Private {
+		payload = append(payload, 0x00)
+	}
+	payload = append(payload, k.Key...)
+	payload = append(payload, checksum(payload)...)
+
+	return string(Base58Encode(payload))
+}
+
+// 解析序列化的扩展密钥
+func ParseExtendedKey(s string) (*ExtendedKey, error) {
+	payload := Base58Decode([]byte(s))
+	if len(payload) != 82 {
+		return nil, ErrInvalidExtendedKey
+	}
+	data, sum := payload[:78], payload[78:]
+	if !bytes.Equal(checksum(data), sum) {
+		return nil, ErrInvalidExtendedKey
+	}
+
+	key := &ExtendedKey{
+		Depth:       data[4],
+		Fingerprint: append([]byte{}, data[5:9]...),
+		ChildNumber: binary.BigEndian.Uint32(data[9:13]),
+		ChainCode:   append([]byte{}, data[13:45]...),
+	}
+	switch {
+	case bytes.Equal(data[:4], hdPrivateKeyVersion) && data[45] == 0x00:
+		key.Private = true
+		key.Key = append([]byte{}, data[46:]...)
+		d := new(big.Int).SetBytes(key.Key)
+		if d.Sign() == 0 || d.Cmp(elliptic.P256().Params().N) >= 0 {
+			return nil, ErrInvalidExtendedKey
+		}
+	case bytes.Equal(data[:4], hdPublicKeyVersion):
+		key.Key = append([]byte{}, data[45:]...)
+		if x, _ := p256UnmarshalCompressed(key.Key); x == nil {
+			return nil, ErrInvalidExtendedKey
+		}
+	default:
+		return nil, ErrInvalidExtendedKey
+	}
+
+	return key, nil
+}
+
+// 密钥指纹：公钥哈希的前4个字节
+func (k *ExtendedKey) fingerprint() []byte {
+	return HashPubKey(k.PublicKey())[:4]
+}
+
+// 由32字节私钥恢复P-256私钥
+func privateKeyFromBytes(d []byte) ecdsa.PrivateKey {
+	return KeyTypeP256.PrivateKey(d)
+}
+
+// 大整数编码为定长字节
+func paddedBytes(n *big.Int, size int) []byte {
+	b := n.Bytes()
+	padded := make([]byte, size)
+	copy(padded[size-len(b):], b)
+
+	return padded
+}
+
+// P-256压缩公钥：前缀0x02（y为偶数）或0x03（y为奇数）加32字节x坐标
+func p256MarshalCompressed(x, y *big.Int) []byte {
+	return append([]byte{0x02 | byte(y.Bit(0))}, paddedBytes(x, 32)...)
+}
+
+// 解压P-256公钥：y² = x³ - 3x + b，按前缀选择y的奇偶；格式错误或不在曲线上时返回nil
+func p256UnmarshalCompressed(data []byte) (x, y *big.Int) {
+	if len(data) != 33 || (data[0] != 0x02 && data[0] != 0x03) {
+		return nil, nil
+	}
+	params := elliptic.P256().Params()
+	x = new(big.Int).SetBytes(data[1:])
+	if x.Cmp(params.P) >= 0 {
+		return nil, nil
+	}
+
+	y2 := new(big.Int).Exp(x, big.NewInt(3), params.P)
+	threeX := new(big.Int).Lsh(x, 1)
+	threeX.Add(threeX, x)
+	y2.Sub(y2, threeX)
+	y2.Add(y2, params.B)
+	y2.Mod(y2, params.P)
+	y = new(big.Int).ModSqrt(y2, params.P)
+	if y == nil {
+		return nil, nil
+	}
+	if byte(y.Bit(0)) != data[0]&1 {
+		y.Sub(params.P, y)
+	}
+	if !elliptic.P256().IsOnCurve(x, y) {
+		return nil, nil
+	}
+
+	return x, y
+}
+
+func appendUint32(b []byte, i uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], i)
+
+	return append(b, buf[:]...)
+}
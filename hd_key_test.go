@@ -0,0 +1,132 @@
+package main
+
+import (
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHDKeyDerivation(t *testing.T) {
+	//SLIP-10 nist256p1 test vector 1
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master, err := NewMasterKey(seed)
+	assert.Nil(t, err)
+	assert.Equal(t, "beeb672fe4621673f722f38529c07392fecaa61015c80c34f29ce8b41b3cb6ea", hex.EncodeToString(master.ChainCode))
+	assert.Equal(t, "612091aaa12e22dd2abef664f8a01a82cae99ad7441b7ef8110424915c268bc2", hex.EncodeToString(master.Key))
+	assert.Equal(t, "0266874dc6ade47b3ecd096745ca09bcd29638dd52c2c12117b11ed3e458cfa9e8", hex.EncodeToString(master.PublicKey()))
+
+	child, err := master.Derive("m/0'")
+	assert.Nil(t, err)
+	assert.Equal(t, "3460cea53e6a6bb5fb391eeef3237ffd8724bf0a40e94943c98b83825342ee11", hex.EncodeToString(child.ChainCode))
+	assert.Equal(t, "6939694369114c67917a182c59ddb8cafc3004e63ca5d3b84403ba8613debc0c", hex.EncodeToString(child.Key))
+	assert.Equal(t, "0384610f5ecffe8fda089363a41f56a5c7ffc1d81b59a612d0d649b2d22355590c", hex.EncodeToString(child.PublicKey()))
+}
+
+func TestHDPublicDerivation(t *testing.T) {
+	seed, _ := hex.DecodeString("fffcf9f6f3f0edeae7e4e1dedbd8d5d2cfccc9c6c3c0bdbab7b4b1aeaba8a5a29f9c999693908d8a8784817e7b7875726f6c696663605d5a5754514e4b484542")
+	master, err := NewMasterKey(seed)
+	assert.Nil(t, err)
+	account, err := master.Derive("m/0'")
+	assert.Nil(t, err)
+
+	//非硬化派生：由扩展公钥派生的子公钥与由私钥派生的一致
+	private, err := account.Derive("m/1/7")
+	assert.Nil(t, err)
+	xpub, err := ParseExtendedKey(account.Neuter().String())
+	assert.Nil(t, err)
+	public, err := xpub.Derive("m/1/7")
+	assert.Nil(t, err)
+	assert.Equal(t, private.PublicKey(), public.Key)
+	assert.Equal(t, private.Neuter().String(), public.String())
+	x, y := elliptic.P256().ScalarBaseMult(private.Key)
+	px, py := p256UnmarshalCompressed(public.Key)
+	assert.Equal(t, x, px)
+	assert.Equal(t, y, py)
+	px, _ = p256UnmarshalCompressed(append([]byte{0x04}, public.Key[1:]...))
+	assert.Nil(t, px)
+
+	_, err = xpub.Child(HardenedKeyStart)
+	assert.Equal(t, ErrDeriveHardenedFromPublic, err)
+
+	xprv, err := ParseExtendedKey(private.String())
+	assert.Nil(t, err)
+	assert.Equal(t, private, xprv)
+
+	indexes, err := ParseDerivationPath("m/0'/1h/2")
+	assert.Nil(t, err)
+	assert.Equal(t, "m/0'/1'/2", FormatDerivationPath(indexes))
+	_, err = ParseDerivationPath("0/1")
+	assert.NotNil(t, err)
+}
+
+func TestHDWalletPublicKeys(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master, err := NewMasterKey(seed)
+	assert.Nil(t, err)
+	account, err := master.Derive(hdAccountPath)
+	assert.Nil(t, err)
+	xpub := account.Neuter()
+	hash := sha256.Sum256([]byte("hash"))
+
+	//约每128个密钥中有一个坐标不足32字节，须补齐后才能验证签名
+	padded := 0
+	for i := 0; i < 512; i++ {
+		key, err := account.Derive(fmt.Sprintf("m/0/%d", i))
+		assert.Nil(t, err)
+		wallet, err := newHDWallet(key, "")
+		assert.Nil(t, err)
+		assert.Equal(t, 64, len(wallet.PublicKey))
+		assert.True(t, verifySignature(wallet.PublicKey, signHash(wallet.PrivateKey, hash[:]), hash[:]), "index %d", i)
+
+		public, err := xpub.Derive(fmt.Sprintf("m/0/%d", i))
+		assert.Nil(t, err)
+		assert.Equal(t, wallet.PublicKey, public.WalletPublicKey())
+		if len(wallet.PrivateKey.X.Bytes()) < 32 || len(wallet.PrivateKey.Y.Bytes()) < 32 {
+			padded++
+		}
+	}
+	assert.NotZero(t, padded)
+}
+
+func TestHDWallets(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "hdwallet")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmp)
+	dir, err := os.Getwd()
+	assert.Nil(t, err)
+	assert.Nil(t, os.Chdir(tmp))
+	defer os.Chdir(dir)
+
+	wallets, _ := NewWallets("test")
+	first := wallets.CreateWallet()
+	change := wallets.NewChangeAddress()
+	assert.False(t, wallets.Wallets[first].IsChange())
+	assert.True(t, wallets.Wallets[change].IsChange())
+	wallets.SaveToFile("test")
+
+	//同一个种子派生出相同的地址和私钥
+	restored, err := NewWallets("test")
+	assert.Nil(t, err)
+	assert.Equal(t, wallets.Wallets[first].PrivateKey.D, restored.Wallets[first].PrivateKey.D)
+	assert.Equal(t, "m/0'/1/0", restored.Wallets[change].Path)
+	copied := Wallets{Wallets: make(map[string]*Wallet), Seed: restored.Seed}
+	assert.Equal(t, first, copied.CreateWallet())
+	assert.Equal(t, change, copied.NewChangeAddress())
+	assert.NotEqual(t, first, restored.CreateWallet())
+
+	//恢复：找回已使用的地址，之后的地址从其后继续派生
+	discovered := Wallets{Wallets: make(map[string]*Wallet)}
+	assert.Nil(t, discovered.SetSeed(restored.Seed))
+	assert.NotNil(t, discovered.SetSeed(restored.Seed))
+	used := map[string]bool{first: true, change: true}
+	assert.Equal(t, 2, discovered.DiscoverAddresses(func(address string) bool { return used[address] }, hdGapLimit))
+	assert.Equal(t, uint32(1), discovered.NextExternal)
+	assert.Equal(t, uint32(1), discovered.NextInternal)
+	assert.Contains(t, discovered.Wallets, first)
+}
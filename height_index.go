@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"log"
+	"math/big"
+)
+
+// 高度索引：主链上每个高度的区块hash，随链状态一起更新，链重组时断开的高度被删除
+// 链尾元数据：链尾的hash、高度和累计工作量，查询最新高度不需要读取区块
+
+const heightIndexBucket = "heights"
+
+// 链尾元数据在meta桶中的键
+const tipInfoKey = "tip"
+
+// 高度超出主链
+var ErrHeightNotFound = errors.New("no block at this height in the main chain")
+
+// 链尾元数据
+type TipInfo struct {
+	Hash   []byte
+	Height int
+	Work   []byte //累计工作量（大整数的字节）
+}
+
+// 累计工作量
+func (t TipInfo) TotalWork() *big.Int {
+	return new(big.Int).SetBytes(t.Work)
+}
+
+// 高度索引的键：8字节大端序，按高度排序
+func heightKey(height int) []byte {
+	return IntToHex(int64(height))
+}
+
+// 区块的链尾元数据：难度固定，主链到该区块的累计工作量为每个区块的工作量乘以区块数
+func newTipInfo(block *Block) TipInfo {
+	work := NewProofOfWork(block).Work()
+	work.Mul(work, big.NewInt(int64(block.Height+1)))
+
+	return TipInfo{block.Hash, block.Height, work.Bytes()}
+}
+
+// Serialize serializes the TipInfo
+func (t TipInfo) Serialize() []byte {
+	var buff bytes.Buffer
+
+	err := gob.NewEncoder(&buff).Encode(t)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return buff.Bytes()
+}
+
+// 读取链尾元数据；旧版本的数据库没有元数据时由链尾区块计算
+func (bc *Blockchain) TipInfo() TipInfo {
+	var info TipInfo
+	if data := bc.db.Get(metaBucket, []byte(tipInfoKey)); data != nil {
+		err := gob.NewDecoder(bytes.NewReader(data)).Decode(&info)
+		if err != nil {
+			log.Panic(err)
+		}
+		return info
+	}
+
+	block, err := bc.db.GetBlock(bc.tip)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return newTipInfo(block)
+}
+
+// 查询主链上指定高度的区块hash
+func (bc *Blockchain) GetBlockHash(height int) ([]byte, error) {
+	hash := bc.db.Get(heightIndexBucket, heightKey(height))
+	if hash == nil {
+		return nil, ErrHeightNotFound
+	}
+
+	return hash, nil
+}
+
+// 区块是否在主链上
+func (bc *Blockchain) isMainChain(block *Block) bool {
+	hash, err := bc.GetBlockHash(block.Height)
+
+	return err == nil && bytes.Equal(hash, block.Hash)
+}
+
+// 查询主链上指定高度的区块
+func (bc *Blockchain) GetBlockByHeight(height int) (*Block, error) {
+	hash, err := bc.GetBlockHash(height)
+	if err != nil {
+		return nil, err
+	}
+
+	return bc.db.GetBlock(hash)
+}
+
+// 重建高度索引和链尾元数据
+func (bc *Blockchain) reindexHeights() {
+	batch := &WriteBatch{}
+	batch.DeleteBucket(heightIndexBucket)
+
+	bci := bc.Iterator()
+	for {
+		block := bci.Next()
+
+		if bytes.Equal(block.Hash, bc.tip) {
+			batch.Put(metaBucket, []byte(tipInfoKey), newTipInfo(block).Serialize())
+		}
+		batch.Put(heightIndexBucket, heightKey(block.Height), block.Hash)
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	err := bc.db.Write(batch)
+	if err != nil {
+		log.Panic(err)
+	}
+}
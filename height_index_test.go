@@ -0,0 +1,59 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeightIndexReorg(t *testing.T) {
+	wallet := NewWallet()
+	bc := newTestBlockchain(t, wallet)
+	genesis := bc.Iterator().Next()
+
+	old := bc.MineBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), "")})
+	hash, err := bc.GetBlockHash(1)
+	assert.Nil(t, err)
+	assert.Equal(t, old.Hash, hash)
+	assert.Equal(t, 1, bc.GetBestHeight())
+
+	//更高的分支成为链尾后，高度索引指向新分支上的区块
+	fork := NewBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), "fork")}, genesis.Hash, 1)
+	bc.AddBlock(fork)
+	next := NewBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), "next")}, fork.Hash, 2)
+	bc.AddBlock(next)
+
+	for height, want := range [][]byte{genesis.Hash, fork.Hash, next.Hash} {
+		block, err := bc.GetBlockByHeight(height)
+		assert.Nil(t, err)
+		assert.Equal(t, want, block.Hash)
+	}
+	_, err = bc.GetBlockHash(3)
+	assert.Equal(t, ErrHeightNotFound, err)
+
+	info := bc.TipInfo()
+	assert.Equal(t, next.Hash, info.Hash)
+	assert.Equal(t, 2, info.Height)
+	work := NewProofOfWork(next).Work()
+	assert.Equal(t, work.Mul(work, big.NewInt(3)), info.TotalWork())
+}
+
+func TestHeightIndexUpgrade(t *testing.T) {
+	wallet := NewWallet()
+	bc := newTestBlockchain(t, wallet)
+	block := bc.MineBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), "")})
+
+	//旧版本的数据库没有高度索引和链尾元数据，打开时建立
+	batch := &WriteBatch{}
+	batch.DeleteBucket(heightIndexBucket)
+	batch.Delete(metaBucket, []byte(tipInfoKey))
+	assert.Nil(t, bc.db.Write(batch))
+	assert.Equal(t, 1, bc.GetBestHeight(), "the height is read from the tip block")
+
+	reopened := NewBlockchainWithStore(bc.db)
+	hash, err := reopened.GetBlockHash(1)
+	assert.Nil(t, err)
+	assert.Equal(t, block.Hash, hash)
+	assert.NotNil(t, bc.db.Get(metaBucket, []byte(tipInfoKey)))
+}
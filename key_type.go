@@ -0,0 +1,146 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"fmt"
+	"math/big"
+)
+
+// 密钥类型：P-256和secp256k1的密钥可以在同一条链上共存
+// 类型由锁定脚本和解锁脚本中的公钥格式区分：
+// P-256公钥为X和Y坐标各32字节拼接，secp256k1公钥为33字节压缩格式（与比特币相同）
+// 两种密钥的地址格式相同，都是公钥哈希；签名都是r和s各32字节拼接
+type KeyType byte
+
+const (
+	KeyTypeP256 KeyType = iota
+	KeyTypeSecp256k1
+)
+
+// 密钥类型名称
+var keyTypeNames = map[KeyType]string{
+	KeyTypeP256:      "p256",
+	KeyTypeSecp256k1: "secp256k1",
+}
+
+// 按名称获取密钥类型，空名称为P-256
+func ParseKeyType(name string) (KeyType, error) {
+	if name == "" {
+		return KeyTypeP256, nil
+	}
+	for keyType, keyTypeName := range keyTypeNames {
+		if name == keyTypeName {
+			return keyType, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown key type %q", name)
+}
+
+func (kt KeyType) String() string {
+	return keyTypeNames[kt]
+}
+
+// 密钥类型的曲线
+func (kt KeyType) Curve() elliptic.Curve {
+	if kt == KeyTypeSecp256k1 {
+		return Secp256k1()
+	}
+
+	return elliptic.P256()
+}
+
+// 由32字节私钥恢复该类型的私钥
+func (kt KeyType) PrivateKey(d []byte) ecdsa.PrivateKey {
+	if kt == KeyTypeSecp256k1 {
+		return secp256k1PrivateKey(d)
+	}
+	curve := kt.Curve()
+	x, y := curve.ScalarBaseMult(d)
+
+	return ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: curve, X: x, Y: y}, D: new(big.Int).SetBytes(d)}
+}
+
+// 私钥所在曲线的密钥类型
+func curveKeyType(curve elliptic.Curve) KeyType {
+	if curve == Secp256k1() {
+		return KeyTypeSecp256k1
+	}
+
+	return KeyTypeP256
+}
+
+// 两种公钥编码的长度
+const (
+	p256PubKeyLen      = 64
+	secp256k1PubKeyLen = 33
+)
+
+// 由编码后的公钥判断密钥类型，两种编码的长度不同；不是其中任何一种时返回false
+// 旧版本生成的P-256公钥没有补齐，不足64字节，链上已有锁定到这些公钥的输出，仍然接受
+func pubKeyType(pubKey []byte) (KeyType, bool) {
+	switch {
+	case len(pubKey) == secp256k1PubKeyLen && (pubKey[0] == 0x02 || pubKey[0] == 0x03):
+		return KeyTypeSecp256k1, true
+	case len(pubKey) > 0 && len(pubKey) <= p256PubKeyLen:
+		return KeyTypeP256, true
+	}
+
+	return 0, false
+}
+
+// 钱包文件中公钥的密钥类型：旧版本钱包文件转换时保留原公钥，其P-256公钥可能不足64字节
+func walletKeyType(pubKey []byte) KeyType {
+	if keyType, ok := pubKeyType(pubKey); ok {
+		return keyType
+	}
+
+	return KeyTypeP256
+}
+
+// 私钥的公钥可能的编码：当前编码，以及P-256坐标不足32字节时旧版本未补齐的编码
+func pubKeyEncodings(pubKey ecdsa.PublicKey) [][]byte {
+	encoded := encodePubKey(pubKey)
+	if curveKeyType(pubKey.Curve) != KeyTypeP256 {
+		return [][]byte{encoded}
+	}
+	legacy := append(pubKey.X.Bytes(), pubKey.Y.Bytes()...)
+	if len(legacy) == len(encoded) {
+		return [][]byte{encoded}
+	}
+
+	return [][]byte{encoded, legacy}
+}
+
+// 解码公钥，格式错误或不在曲线上时返回false
+func decodePubKey(pubKey []byte) (ecdsa.PublicKey, bool) {
+	keyType, ok := pubKeyType(pubKey)
+	if !ok {
+		return ecdsa.PublicKey{}, false
+	}
+	if keyType == KeyTypeSecp256k1 {
+		return secp256k1UnmarshalCompressed(pubKey)
+	}
+
+	curve := elliptic.P256()
+	if len(pubKey) == p256PubKeyLen {
+		x := new(big.Int).SetBytes(pubKey[:32])
+		y := new(big.Int).SetBytes(pubKey[32:])
+		return ecdsa.PublicKey{Curve: curve, X: x, Y: y}, curve.IsOnCurve(x, y)
+	}
+
+	//旧版本的公钥为X和Y坐标去掉前导零后拼接，逐个尝试分界位置，各自左侧补零后须在曲线上
+	for xLen := len(pubKey) - 32; xLen <= 32 && xLen < len(pubKey); xLen++ {
+		if xLen <= 0 || pubKey[0] == 0 || pubKey[xLen] == 0 {
+			continue
+		}
+		x := new(big.Int).SetBytes(pubKey[:xLen])
+		y := new(big.Int).SetBytes(pubKey[xLen:])
+		if curve.IsOnCurve(x, y) {
+			return ecdsa.PublicKey{Curve: curve, X: x, Y: y}, true
+		}
+	}
+
+	return ecdsa.PublicKey{}, false
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/sha256"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecp256k1(t *testing.T) {
+	curve := Secp256k1()
+	params := curve.Params()
+	assert.True(t, curve.IsOnCurve(params.Gx, params.Gy))
+
+	x, y := curve.ScalarBaseMult([]byte{2})
+	dx, dy := curve.Double(params.Gx, params.Gy)
+	assert.Equal(t, dx, x)
+	assert.Equal(t, dy, y)
+	x, y = curve.Add(x, y, params.Gx, params.Gy)
+	tx, ty := curve.ScalarBaseMult([]byte{3})
+	assert.Equal(t, tx, x)
+	assert.Equal(t, ty, y)
+	assert.True(t, curve.IsOnCurve(x, y))
+
+	//n*G为无穷远点
+	x, y = curve.ScalarBaseMult(params.N.Bytes())
+	assert.Equal(t, 0, x.Sign())
+	assert.Equal(t, 0, y.Sign())
+}
+
+// 与比特币的私钥1的WIF和地址一致
+func TestSecp256k1BitcoinKey(t *testing.T) {
+	privKey, err := DecodePrivateKey("KwDiBf89QgGbjEhKnhXJuH7LrciVrZi3qYjgd9M7rFU73sVHnoWn")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), privKey.D.Int64())
+
+	wallet := NewWalletFromPrivateKey(privKey)
+	assert.Equal(t, "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH", string(wallet.GetAddress()))
+	keyType, ok := pubKeyType(wallet.PublicKey)
+	assert.True(t, ok)
+	assert.Equal(t, KeyTypeSecp256k1, keyType)
+	assert.Equal(t, "KwDiBf89QgGbjEhKnhXJuH7LrciVrZi3qYjgd9M7rFU73sVHnoWn", EncodePrivateKey(privKey))
+}
+
+func TestMixedKeyTypes(t *testing.T) {
+	hash := sha256.Sum256([]byte("hash"))
+	p256 := NewWallet()
+	k1 := NewWalletOfType(KeyTypeSecp256k1)
+	assert.Equal(t, 33, len(k1.PublicKey))
+
+	sig := signHash(k1.PrivateKey, hash[:])
+	assert.True(t, verifySignature(k1.PublicKey, sig, hash[:]))
+	//RFC 6979确定性签名，s取较小值
+	assert.Equal(t, sig, signHash(k1.PrivateKey, hash[:]))
+	halfOrder := new(big.Int).Rsh(Secp256k1().Params().N, 1)
+	assert.True(t, new(big.Int).SetBytes(sig[32:]).Cmp(halfOrder) <= 0)
+	assert.False(t, verifySignature(p256.PublicKey, sig, hash[:]))
+
+	//两种密钥的输入在同一笔交易中
+	prevTx := Transaction{nil, []TXInput{{[]byte{}, -1, []byte("prev"), SequenceFinal}}, []TXOutput{
+		{10, NewP2PKHScript(HashPubKey(p256.PublicKey))},
+		{10, NewP2PKHScript(HashPubKey(k1.PublicKey))},
+	}, 0}
+	prevTx.ID = prevTx.Hash()
+	prevTXs := map[string]Transaction{hexID(&prevTx): prevTx}
+	tx := Transaction{nil, []TXInput{{prevTx.ID, 0, nil, SequenceFinal}, {prevTx.ID, 1, nil, SequenceFinal}}, []TXOutput{*NewTXOutput(20, string(k1.GetAddress()))}, 0}
+	tx.ID = tx.Hash()
+	for inID, wallet := range []*Wallet{p256, k1} {
+		hash := tx.SignatureHash(inID, prevTx.Vout[inID].ScriptPubKey, SigHashAll)
+		tx.Vin[inID].ScriptSig = NewP2PKHScriptSig(append(signHash(wallet.PrivateKey, hash), SigHashAll), wallet.PublicKey)
+	}
+	assert.True(t, tx.Verify(prevTXs))
+
+	//钱包文件中保存私钥后恢复为同一曲线
+	data, err := k1.GobEncode()
+	assert.Nil(t, err)
+	var decoded Wallet
+	assert.Nil(t, decoded.GobDecode(data))
+	assert.Equal(t, k1.PrivateKey.D, decoded.PrivateKey.D)
+	assert.Equal(t, Secp256k1(), decoded.PrivateKey.Curve)
+
+	keyType, err := ParseKeyType("secp256k1")
+	assert.Nil(t, err)
+	assert.Equal(t, KeyTypeSecp256k1, keyType)
+	_, err = ParseKeyType("ed25519")
+	assert.NotNil(t, err)
+}
+
+func TestP256PubKeyPadding(t *testing.T) {
+	//找到X或Y坐标不足32字节的密钥，约每128个出现一次
+	var wallet *Wallet
+	for i := 0; i < 10000 && wallet == nil; i++ {
+		w := NewWallet()
+		if len(w.PrivateKey.X.Bytes()) < 32 || len(w.PrivateKey.Y.Bytes()) < 32 {
+			wallet = w
+		}
+	}
+	assert.NotNil(t, wallet)
+	assert.Equal(t, 64, len(wallet.PublicKey))
+
+	hash := sha256.Sum256([]byte("hash"))
+	sig := signHash(wallet.PrivateKey, hash[:])
+	assert.True(t, verifySignature(wallet.PublicKey, sig, hash[:]))
+
+	prevTx := newPrevTx(NewP2PKHScript(HashPubKey(wallet.PublicKey)))
+	tx := newSpendingTx(prevTx, string(wallet.GetAddress()))
+	tx.Sign(wallet.PrivateKey, map[string]Transaction{hexID(prevTx): *prevTx})
+	assert.True(t, tx.Verify(map[string]Transaction{hexID(prevTx): *prevTx}))
+
+	//旧版本未补齐的公钥仍可验证签名，链上锁定到它的输出仍可花费
+	x, y := wallet.PrivateKey.X.Bytes(), wallet.PrivateKey.Y.Bytes()
+	legacy := append(append([]byte{}, x...), y...)
+	assert.True(t, len(legacy) < 64)
+	decoded, ok := decodePubKey(legacy)
+	assert.True(t, ok)
+	assert.Equal(t, wallet.PrivateKey.X, decoded.X)
+	assert.Equal(t, wallet.PrivateKey.Y, decoded.Y)
+	assert.True(t, verifySignature(legacy, sig, hash[:]))
+	assert.Equal(t, [][]byte{wallet.PublicKey, legacy}, pubKeyEncodings(wallet.PrivateKey.PublicKey))
+
+	legacyTx := newPrevTx(NewP2PKHScript(HashPubKey(legacy)))
+	ptx := PartialTransaction{*newSpendingTx(legacyTx, string(wallet.GetAddress())), []PartialInput{NewPartialInput(legacyTx, 0, nil)}}
+	assert.Equal(t, 1, ptx.Sign(wallet.PrivateKey))
+	final, err := ptx.Finalize()
+	assert.Nil(t, err)
+	assert.True(t, final.Verify(map[string]Transaction{hexID(legacyTx): *legacyTx}))
+
+	//不在曲线上的公钥和过长的公钥不被接受
+	_, ok = decodePubKey(append([]byte{1}, legacy[1:]...))
+	assert.False(t, ok)
+	_, ok = pubKeyType(make([]byte, 65))
+	assert.False(t, ok)
+}
+
+func TestSecp256k1Wallets(t *testing.T) {
+	//HD钱包中不创建随机的secp256k1密钥
+	hd := Wallets{Wallets: make(map[string]*Wallet)}
+	_, err := hd.CreateWalletOfType(KeyTypeP256)
+	assert.Nil(t, err)
+	_, err = hd.CreateWalletOfType(KeyTypeSecp256k1)
+	assert.Equal(t, ErrMixedKeyTypes, err)
+
+	//secp256k1钱包不生成种子，找零给转出地址
+	k1 := Wallets{Wallets: make(map[string]*Wallet)}
+	address, err := k1.CreateWalletOfType(KeyTypeSecp256k1)
+	assert.Nil(t, err)
+	assert.Equal(t, KeyTypeSecp256k1, walletKeyType(k1.Wallets[address].PublicKey))
+	_, err = k1.CreateWalletOfType(KeyTypeP256)
+	assert.Equal(t, ErrMixedKeyTypes, err)
+	assert.Equal(t, "", k1.NewChangeAddress())
+	assert.Equal(t, ErrMixedKeyTypes, k1.SetSeed(hd.Seed))
+	assert.False(t, k1.HasSeed())
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// 输入序列号中的相对时间锁（BIP68）
+const (
+	SequenceLockTimeDisabled    = 1 << 31 //设置后该输入不启用相对时间锁
+	SequenceLockTimeTypeFlag    = 1 << 22 //设置后按时间计算（单位512秒），否则按区块数计算
+	SequenceLockTimeMask        = 0x0000ffff
+	SequenceLockTimeGranularity = 9 //时间单位：2^9 = 512秒
+)
+
+// 时间锁校验错误
+var (
+	ErrTxNotFinal       = errors.New("transaction lock time is not reached")
+	ErrSequenceNotFinal = errors.New("relative lock time of an input is not reached")
+)
+
+// 判断交易在指定高度和时间的区块中是否已生效
+// 锁定时间小于500000000为区块高度，否则为时间戳；所有输入均为默认序列号时锁定时间不生效
+func (tx *Transaction) IsFinal(height int, blockTime int64) bool {
+	if tx.LockTime == 0 {
+		return true
+	}
+
+	threshold := int64(height)
+	if tx.LockTime >= lockTimeThreshold {
+		threshold = blockTime
+	}
+	if tx.LockTime < threshold {
+		return true
+	}
+
+	for _, vin := range tx.Vin {
+		if vin.Sequence != SequenceFinal {
+			return false
+		}
+	}
+
+	return true
+}
+
+// 计算交易的相对时间锁：返回包含该交易的区块须超过的高度和时间，-1表示无要求
+// 交易将被打包进父区块为prevHash、高度为height、时间为blockTime的区块，被花费的交易在该区块所在的分支上查找
+// 不在分支上的被花费交易（在交易池或同一区块中）按与该交易在同一区块计算
+func (bc *Blockchain) CalculateSequenceLock(tx *Transaction, prevHash []byte, height int, blockTime int64) (int, int64, error) {
+	minHeight, minTime := -1, int64(-1)
+	if tx.IsCoinbase() {
+		return minHeight, minTime, nil
+	}
+
+	for _, vin := range tx.Vin {
+		if vin.Sequence&SequenceLockTimeDisabled != 0 {
+			continue
+		}
+		//相对时间锁从被花费输出所在的区块起算
+		prevHeight, prevTime := height, blockTime
+		block, found, err := bc.findTransactionOnBranch(vin.Txid, prevHash)
+		if err != nil {
+			return 0, 0, err
+		}
+		if found {
+			prevHeight, prevTime = block.Height, block.Timestamp
+		}
+
+		value := int64(vin.Sequence & SequenceLockTimeMask)
+		if vin.Sequence&SequenceLockTimeTypeFlag != 0 {
+			lockTime := prevTime + value<<SequenceLockTimeGranularity - 1
+			if lockTime > minTime {
+				minTime = lockTime
+			}
+		} else {
+			lockHeight := prevHeight + int(value) - 1
+			if lockHeight > minHeight {
+				minHeight = lockHeight
+			}
+		}
+	}
+
+	return minHeight, minTime, nil
+}
+
+// 在以tip结尾的分支上查找交易所在的区块：先检查分叉出主链的区块，分叉点之前在主链上查找
+func (bc *Blockchain) findTransactionOnBranch(txID, tip []byte) (*Block, bool, error) {
+	for hash := tip; len(hash) > 0; {
+		block, err := bc.db.GetBlock(hash)
+		if err != nil {
+			return nil, false, err
+		}
+		if bc.isMainChain(block) {
+			_, found, err := bc.findTransactionBlock(txID)
+			if err != nil || found.Height > block.Height {
+				return nil, false, nil
+			}
+			return found, true, nil
+		}
+
+		for _, tx := range block.Transactions {
+			if bytes.Equal(tx.ID, txID) {
+				return block, true, nil
+			}
+		}
+		hash = block.PrevBlockHash
+	}
+
+	return nil, false, nil
+}
+
+// 校验交易能否被打包进链尾之后指定高度和时间的区块：锁定时间和所有输入的相对时间锁
+func (bc *Blockchain) CheckTransactionLocks(tx *Transaction, height int, blockTime int64) error {
+	return bc.checkTransactionLocksOnBranch(tx, bc.tip, height, blockTime)
+}
+
+// 校验交易能否被打包进父区块为prevHash的区块
+func (bc *Blockchain) checkTransactionLocksOnBranch(tx *Transaction, prevHash []byte, height int, blockTime int64) error {
+	if !tx.IsFinal(height, blockTime) {
+		return fmt.Errorf("%v: %d", ErrTxNotFinal, tx.LockTime)
+	}
+
+	minHeight, minTime, err := bc.CalculateSequenceLock(tx, prevHash, height, blockTime)
+	if err != nil {
+		return err
+	}
+	if height <= minHeight || blockTime <= minTime {
+		return ErrSequenceNotFinal
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsFinal(t *testing.T) {
+	tx := Transaction{nil, []TXInput{{[]byte{1}, 0, nil, SequenceFinal - 1}}, nil, 0}
+	assert.True(t, tx.IsFinal(1, 0), "no lock time")
+
+	tx.LockTime = 10
+	assert.False(t, tx.IsFinal(10, 0))
+	assert.True(t, tx.IsFinal(11, 0))
+
+	tx.LockTime = 1600000000
+	assert.False(t, tx.IsFinal(1000, 1600000000))
+	assert.True(t, tx.IsFinal(1000, 1600000001))
+
+	tx.Vin[0].Sequence = SequenceFinal
+	assert.True(t, tx.IsFinal(1000, 0), "final inputs disable lock time")
+}
+
+func TestCheckSequence(t *testing.T) {
+	tx := Transaction{nil, []TXInput{{[]byte{1}, 0, nil, 10}}, nil, 0}
+	checker := txSigChecker{&tx, 0}
+
+	assert.True(t, checker.CheckSequence(10))
+	assert.False(t, checker.CheckSequence(11))
+	assert.False(t, checker.CheckSequence(SequenceLockTimeTypeFlag|5), "blocks and time do not compare")
+	assert.True(t, checker.CheckSequence(SequenceLockTimeDisabled|100), "disabled lock in script")
+
+	tx.Vin[0].Sequence = SequenceLockTimeDisabled | 10
+	assert.False(t, checker.CheckSequence(10), "disabled lock on input")
+}
+
+func TestSequenceLockUnconfirmedParent(t *testing.T) {
+	wallet := NewWallet()
+	bc := newTestBlockchain(t, wallet)
+	genesis := bc.Iterator().Next().Transactions[0]
+	mempool := NewMempool()
+
+	//父交易在交易池中，按与子交易在同一区块计算
+	parent := newTestSpend(wallet, genesis, 0, []int{10}, SequenceFinal)
+	_, err := mempool.Accept(bc, parent)
+	assert.Nil(t, err)
+	_, err = mempool.Accept(bc, newTestSpend(wallet, parent, 0, []int{10}, 1))
+	assert.Equal(t, ErrSequenceNotFinal, err)
+	child := newTestSpend(wallet, parent, 0, []int{10}, 0)
+	_, err = mempool.Accept(bc, child)
+	assert.Nil(t, err)
+
+	//父交易和子交易在同一区块中
+	block := bc.MineBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), ""), parent, child})
+	assert.Equal(t, block.Hash, bc.tip)
+}
+
+func TestSequenceLockSideChain(t *testing.T) {
+	wallet := NewWallet()
+	bc := newTestBlockchain(t, wallet)
+	genesis := bc.Iterator().Next()
+	bc.MineBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), "")})
+
+	//侧链区块同样校验相对时间锁：创世块之后2个区块才能花费
+	locked := newTestSpend(wallet, genesis.Transactions[0], 0, []int{10}, 2)
+	fork := NewBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), "fork"), locked}, genesis.Hash, 1)
+	bc.AddBlock(fork)
+	_, err := bc.GetBlock(fork.Hash)
+	assert.NotNil(t, err, "the side chain block is rejected")
+
+	spend := newTestSpend(wallet, genesis.Transactions[0], 0, []int{10}, 1)
+	fork = NewBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), "fork"), spend}, genesis.Hash, 1)
+	bc.AddBlock(fork)
+	_, err = bc.GetBlock(fork.Hash)
+	assert.Nil(t, err)
+}
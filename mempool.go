@@ -0,0 +1,632 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 交易池限制
+const (
+	maxReplacedTransactions = 100  //一次替换最多移除的交易数（含后代）
+	minRelayFeeIncrement    = 1    //替换交易至少需要比被替换的交易多付的手续费
+	maxBlockTransactions    = 1000 //区块模板最多包含的交易数（不含coinbase）
+	maxAncestorCount        = 25   //交易池中一笔交易连同其未确认祖先的最大交易数
+	maxDescendantCount      = 25   //交易池中一笔交易连同其后代的最大交易数
+)
+
+const mempoolBucket = "mempool"
+
+// 交易池错误
+var (
+	ErrAlreadyInMempool = errors.New("transaction is already in the mempool")
+	ErrMissingInputs    = errors.New("inputs are spent or do not exist")
+	ErrNotReplaceable   = errors.New("conflicting transaction does not signal replacement")
+	ErrTooLongChain     = errors.New("too many unconfirmed ancestors or descendants")
+)
+
+// 交易池中的交易及其手续费、大小
+type mempoolEntry struct {
+	tx   Transaction
+	fee  int
+	size int
+}
+
+// 交易池：尚未打包的交易；记录每个输出被哪笔交易花费，用于发现冲突、替换（RBF）和查找父子交易
+type Mempool struct {
+	mu      sync.Mutex
+	entries map[string]*mempoolEntry //txid -> 交易
+	spends  map[string]string        //被花费的输出 txid:vout -> 花费它的txid
+}
+
+// 创建交易池
+func NewMempool() *Mempool {
+	return &Mempool{entries: make(map[string]*mempoolEntry), spends: make(map[string]string)}
+}
+
+// 输出的位置
+func outPoint(txID []byte, vout int) string {
+	return fmt.Sprintf("%x:%d", txID, vout)
+}
+
+// 交易是否允许被替换：任一输入的序列号不大于SequenceReplaceable（BIP125）
+func (tx *Transaction) SignalsReplacement() bool {
+	for _, vin := range tx.Vin {
+		if vin.Sequence <= SequenceReplaceable {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 交易是否在交易池中
+func (m *Mempool) Has(txID []byte) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.entries[hex.EncodeToString(txID)]
+	return ok
+}
+
+// 查询交易池中的交易，返回副本，修改它不影响交易池
+func (m *Mempool) Get(txID []byte) (Transaction, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[hex.EncodeToString(txID)]
+	if !ok {
+		return Transaction{}, false
+	}
+	return DeserializeTransaction(entry.tx.Serialize()), true
+}
+
+// 查询交易池中交易的手续费
+func (m *Mempool) Fee(txID []byte) (int, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[hex.EncodeToString(txID)]
+	if !ok {
+		return 0, false
+	}
+	return entry.fee, true
+}
+
+// 替换交易池中的交易所需的最低手续费：被替换的交易及其后代的手续费之和再加minRelayFeeIncrement
+func (m *Mempool) ReplacementFee(txID []byte) (int, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := hex.EncodeToString(txID)
+	entry, ok := m.entries[id]
+	if !ok {
+		return 0, false
+	}
+	fee := entry.fee
+	for descendant := range m.descendants(id) {
+		fee += m.entries[descendant].fee
+	}
+
+	return fee + minRelayFeeIncrement, true
+}
+
+// 交易池中所有交易的副本，父交易排在子交易之前
+func (m *Mempool) Transactions() []Transaction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var ids []string
+	for id := range m.entries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var txs []Transaction
+	for _, id := range m.sortByAncestors(ids) {
+		txs = append(txs, DeserializeTransaction(m.entries[id].tx.Serialize()))
+	}
+
+	return txs
+}
+
+// 交易池中的交易数
+func (m *Mempool) Count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.entries)
+}
+
+// 校验交易并加入交易池，返回因替换而被移除的交易id
+// 与池中交易冲突时，只有被冲突的交易都允许替换、且新交易的手续费和费率都更高时才替换（RBF）
+func (m *Mempool) Accept(bc *Blockchain, tx *Transaction) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if tx.IsCoinbase() {
+		return nil, errors.New("coinbase transaction is not accepted")
+	}
+	if !bytes.Equal(tx.ID, tx.Hash()) {
+		return nil, errors.New("transaction id does not match its content")
+	}
+	txID := hex.EncodeToString(tx.ID)
+	if _, ok := m.entries[txID]; ok {
+		return nil, ErrAlreadyInMempool
+	}
+	//在查找冲突和替换之前拒绝重复花费同一输出的交易
+	if err := tx.CheckDuplicateInputs(); err != nil {
+		return nil, err
+	}
+
+	UTXOSet := UTXOSet{bc}
+	var prevOuts []TXOutput
+	conflicts := make(map[string]bool)
+	parents := make(map[string]bool)
+	for _, vin := range tx.Vin {
+		prevOut, ok := m.prevOutput(UTXOSet, vin)
+		if !ok {
+			return nil, ErrMissingInputs
+		}
+		prevOuts = append(prevOuts, prevOut)
+
+		if spender, ok := m.spends[outPoint(vin.Txid, vin.Vout)]; ok {
+			conflicts[spender] = true
+		}
+		if parentID := hex.EncodeToString(vin.Txid); m.entries[parentID] != nil {
+			parents[parentID] = true
+		}
+	}
+	if err := m.checkChainLimits(parents); err != nil {
+		return nil, err
+	}
+	if err := tx.VerifyPrevOuts(prevOuts); err != nil {
+		return nil, err
+	}
+	if err := tx.CheckDataOutputs(); err != nil {
+		return nil, err
+	}
+	//锁定时间或相对时间锁未到的交易不进入交易池
+	if err := bc.CheckTransactionLocks(tx, bc.GetBestHeight()+1, time.Now().Unix()); err != nil {
+		return nil, err
+	}
+
+	entry := &mempoolEntry{*tx, 0, len(tx.Serialize())}
+	for _, prevOut := range prevOuts {
+		entry.fee += prevOut.Value
+	}
+	for _, out := range tx.Vout {
+		entry.fee -= out.Value
+	}
+
+	var replaced []string
+	if len(conflicts) > 0 {
+		var err error
+		replaced, err = m.checkReplacement(entry, conflicts, parents)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range replaced {
+			m.remove(id)
+		}
+	}
+
+	m.entries[txID] = entry
+	for _, vin := range tx.Vin {
+		m.spends[outPoint(vin.Txid, vin.Vout)] = txID
+	}
+
+	return replaced, nil
+}
+
+// 校验未确认交易链的长度：新交易的祖先数，以及每个祖先增加一个后代后的后代数
+func (m *Mempool) checkChainLimits(parents map[string]bool) error {
+	ancestors := make(map[string]bool)
+	for parentID := range parents {
+		ancestors[parentID] = true
+		for ancestor := range m.ancestors(parentID) {
+			ancestors[ancestor] = true
+		}
+	}
+	if len(ancestors)+1 > maxAncestorCount {
+		return fmt.Errorf("%v: %d ancestors, limit is %d", ErrTooLongChain, len(ancestors), maxAncestorCount-1)
+	}
+	for ancestor := range ancestors {
+		if len(m.descendants(ancestor))+2 > maxDescendantCount {
+			return fmt.Errorf("%v: %s has too many descendants", ErrTooLongChain, ancestor)
+		}
+	}
+
+	return nil
+}
+
+// 校验替换规则（BIP125），返回将被替换的交易及其后代
+func (m *Mempool) checkReplacement(entry *mempoolEntry, conflicts, parents map[string]bool) ([]string, error) {
+	replaced := make(map[string]bool)
+	originalParents := make(map[string]bool)
+
+	for id := range conflicts {
+		conflict := m.entries[id]
+		if !conflict.tx.SignalsReplacement() {
+			return nil, fmt.Errorf("%v: %s", ErrNotReplaceable, id)
+		}
+		//费率须高于被直接替换的交易
+		if entry.fee*conflict.size <= conflict.fee*entry.size {
+			return nil, fmt.Errorf("fee rate is not higher than the fee rate of %s", id)
+		}
+		for parentID := range m.parents(id) {
+			originalParents[parentID] = true
+		}
+
+		replaced[id] = true
+		for descendant := range m.descendants(id) {
+			replaced[descendant] = true
+		}
+	}
+	if len(replaced) > maxReplacedTransactions {
+		return nil, fmt.Errorf("replacement would evict %d transactions, limit is %d", len(replaced), maxReplacedTransactions)
+	}
+	//新交易不能花费被替换交易的输出，也不能引入新的未确认输入
+	for parentID := range parents {
+		if replaced[parentID] {
+			return nil, fmt.Errorf("replacement spends an output of %s which it replaces", parentID)
+		}
+		if !originalParents[parentID] {
+			return nil, fmt.Errorf("replacement adds unconfirmed input from %s", parentID)
+		}
+	}
+	//手续费须高于被替换的所有交易的手续费之和
+	replacedFees := 0
+	for id := range replaced {
+		replacedFees += m.entries[id].fee
+	}
+	if entry.fee < replacedFees+minRelayFeeIncrement {
+		return nil, fmt.Errorf("fee %d must be at least %d", entry.fee, replacedFees+minRelayFeeIncrement)
+	}
+
+	var ids []string
+	for id := range replaced {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids, nil
+}
+
+// 移除已打包的交易，以及与区块中的交易冲突的交易及其后代
+func (m *Mempool) RemoveForBlock(block *Block) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, tx := range block.Transactions {
+		txID := hex.EncodeToString(tx.ID)
+		if _, ok := m.entries[txID]; ok {
+			m.remove(txID)
+		}
+		if tx.IsCoinbase() {
+			continue
+		}
+
+		for _, vin := range tx.Vin {
+			spender, ok := m.spends[outPoint(vin.Txid, vin.Vout)]
+			if !ok {
+				continue
+			}
+			for descendant := range m.descendants(spender) {
+				m.remove(descendant)
+			}
+			m.remove(spender)
+		}
+	}
+}
+
+// 区块从主链断开后，将其中的交易（coinbase除外）重新加入交易池
+// 链状态须已切换到新主链：已在新主链上或与之冲突的交易不再加入
+func (m *Mempool) RestoreForBlock(bc *Blockchain, block *Block) {
+	for _, tx := range block.Transactions {
+		if tx.IsCoinbase() || m.Has(tx.ID) {
+			continue
+		}
+		if _, err := m.Accept(bc, tx); err != nil {
+			fmt.Printf("Dropped transaction %x of disconnected block %x: %s\n", tx.ID, block.Hash, err)
+		}
+	}
+}
+
+// 选择打包进区块的交易：按祖先组合的费率从高到低选择，手续费高的子交易会带上其父交易（CPFP）
+// 父交易总是排在子交易之前；返回交易及手续费总额
+func (m *Mempool) SelectTransactions(bc *Blockchain, height int, blockTime int64) ([]*Transaction, int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var selected []*Transaction
+	totalFee := 0
+	done := make(map[string]bool)
+	//跳过尚未生效或输入已不存在的交易，及其后代
+	skip := make(map[string]bool)
+	UTXOSet := UTXOSet{bc}
+	for id, entry := range m.entries {
+		ok := bc.CheckTransactionLocks(&entry.tx, height, blockTime) == nil
+		for _, vin := range entry.tx.Vin {
+			if _, found := m.prevOutput(UTXOSet, vin); !found {
+				ok = false
+			}
+		}
+		if !ok {
+			skip[id] = true
+			for descendant := range m.descendants(id) {
+				skip[descendant] = true
+			}
+		}
+	}
+
+	for len(selected) < maxBlockTransactions {
+		var best []string
+		bestFee, bestSize := 0, 0
+		for id := range m.entries {
+			if done[id] || skip[id] {
+				continue
+			}
+			//交易及其尚未选中的祖先
+			pkg := []string{id}
+			for ancestor := range m.ancestors(id) {
+				if !done[ancestor] {
+					pkg = append(pkg, ancestor)
+				}
+			}
+			if len(selected)+len(pkg) > maxBlockTransactions {
+				continue
+			}
+			fee, size := 0, 0
+			for _, pkgID := range pkg {
+				fee += m.entries[pkgID].fee
+				size += m.entries[pkgID].size
+			}
+			if best == nil || fee*bestSize > bestFee*size || (fee*bestSize == bestFee*size && id < best[0]) {
+				best, bestFee, bestSize = pkg, fee, size
+			}
+		}
+		if best == nil {
+			break
+		}
+		for _, id := range m.sortByAncestors(best) {
+			tx := m.entries[id].tx
+			selected = append(selected, &tx)
+			done[id] = true
+		}
+		totalFee += bestFee
+	}
+
+	return selected, totalFee
+}
+
+// 交易及其在交易池中的所有祖先，父交易排在子交易之前，可以按顺序打包进同一区块
+func (m *Mempool) Package(txID []byte) []*Transaction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := hex.EncodeToString(txID)
+	if _, ok := m.entries[id]; !ok {
+		return nil
+	}
+	ids := []string{id}
+	for ancestor := range m.ancestors(id) {
+		ids = append(ids, ancestor)
+	}
+
+	var txs []*Transaction
+	for _, id := range m.sortByAncestors(ids) {
+		tx := m.entries[id].tx
+		txs = append(txs, &tx)
+	}
+
+	return txs
+}
+
+// 输出是否已被交易池中的交易花费
+func (m *Mempool) IsSpent(txID []byte, vout int) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.spends[outPoint(txID, vout)]
+	return ok
+}
+
+// 查询交易池中的交易产生的、尚未被花费的输出
+func (m *Mempool) FindUnspentOutput(txID []byte, vout int) (UnspentOutput, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[hex.EncodeToString(txID)]
+	if !ok || vout < 0 || vout >= len(entry.tx.Vout) || !entry.tx.Vout[vout].IsSpendable() {
+		return UnspentOutput{}, false
+	}
+	if _, spent := m.spends[outPoint(txID, vout)]; spent {
+		return UnspentOutput{}, false
+	}
+
+	return UnspentOutput{entry.tx.ID, vout, entry.tx.Vout[vout]}, true
+}
+
+// 查询交易池中公钥hash（或脚本hash）可花费的、尚未被花费的输出
+func (m *Mempool) FindUnspentOutputs(pubKeyHash []byte) []UnspentOutput {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var utxos []UnspentOutput
+	for _, entry := range m.entries {
+		for outIdx, out := range entry.tx.Vout {
+			if _, spent := m.spends[outPoint(entry.tx.ID, outIdx)]; spent {
+				continue
+			}
+			if out.IsSpendable() && out.IsLockedWithKey(pubKeyHash) {
+				utxos = append(utxos, UnspentOutput{entry.tx.ID, outIdx, out})
+			}
+		}
+	}
+
+	return utxos
+}
+
+// 从数据库加载保存的交易池；已确认、冲突或不再有效的交易被丢弃
+func LoadMempool(bc *Blockchain) *Mempool {
+	var txs []Transaction
+	err := bc.db.ForEach(mempoolBucket, func(k, v []byte) error {
+		txs = append(txs, DeserializeTransaction(v))
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	//父交易须先进入交易池：反复尝试，直到没有交易可以加入
+	m := NewMempool()
+	for accepted := true; accepted; {
+		accepted = false
+		var rejected []Transaction
+		for i := range txs {
+			if _, err := m.Accept(bc, &txs[i]); err != nil {
+				rejected = append(rejected, txs[i])
+				continue
+			}
+			accepted = true
+		}
+		txs = rejected
+	}
+
+	return m
+}
+
+// 保存交易池，节点重启或下次执行命令时恢复
+func (m *Mempool) Save(bc *Blockchain) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	batch := &WriteBatch{}
+	batch.DeleteBucket(mempoolBucket)
+	for _, entry := range m.entries {
+		batch.Put(mempoolBucket, entry.tx.ID, entry.tx.Serialize())
+	}
+	err := bc.db.Write(batch)
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// 查找交易的输入所引用的交易：先在交易池中查找，再到链上查找
+func (m *Mempool) PrevTransactions(bc *Blockchain, tx *Transaction) (map[string]Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prevTXs := make(map[string]Transaction)
+	for _, vin := range tx.Vin {
+		txID := hex.EncodeToString(vin.Txid)
+		if parent, ok := m.entries[txID]; ok {
+			prevTXs[txID] = parent.tx
+			continue
+		}
+		prevTX, err := bc.FindTransaction(vin.Txid)
+		if err != nil {
+			return nil, err
+		}
+		prevTXs[txID] = prevTX
+	}
+
+	return prevTXs, nil
+}
+
+// 查找输入所花费的输出：先在交易池中的交易中查找，再到utxo集合中查找
+func (m *Mempool) prevOutput(UTXOSet UTXOSet, vin TXInput) (TXOutput, bool) {
+	if parent, ok := m.entries[hex.EncodeToString(vin.Txid)]; ok {
+		if vin.Vout < 0 || vin.Vout >= len(parent.tx.Vout) || !parent.tx.Vout[vin.Vout].IsSpendable() {
+			return TXOutput{}, false
+		}
+		return parent.tx.Vout[vin.Vout], true
+	}
+
+	utxo, ok := UTXOSet.FindUnspentOutput(vin.Txid, vin.Vout)
+	return utxo.Output, ok
+}
+
+// 交易池中的父交易
+func (m *Mempool) parents(id string) map[string]bool {
+	parents := make(map[string]bool)
+	for _, vin := range m.entries[id].tx.Vin {
+		if parentID := hex.EncodeToString(vin.Txid); m.entries[parentID] != nil {
+			parents[parentID] = true
+		}
+	}
+
+	return parents
+}
+
+// 交易池中的所有祖先交易
+func (m *Mempool) ancestors(id string) map[string]bool {
+	ancestors := make(map[string]bool)
+	queue := []string{id}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for parentID := range m.parents(current) {
+			if !ancestors[parentID] {
+				ancestors[parentID] = true
+				queue = append(queue, parentID)
+			}
+		}
+	}
+
+	return ancestors
+}
+
+// 交易池中的所有后代交易
+func (m *Mempool) descendants(id string) map[string]bool {
+	descendants := make(map[string]bool)
+	queue := []string{id}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		tx := m.entries[current].tx
+		for outIdx := range tx.Vout {
+			child, ok := m.spends[outPoint(tx.ID, outIdx)]
+			if ok && !descendants[child] {
+				descendants[child] = true
+				queue = append(queue, child)
+			}
+		}
+	}
+
+	return descendants
+}
+
+// 按祖先数排序，祖先越少越靠前，保证父交易排在子交易之前
+func (m *Mempool) sortByAncestors(ids []string) []string {
+	counts := make(map[string]int)
+	for _, id := range ids {
+		counts[id] = len(m.ancestors(id))
+	}
+	sort.SliceStable(ids, func(i, j int) bool {
+		return counts[ids[i]] < counts[ids[j]]
+	})
+
+	return ids
+}
+
+// 移除交易（不含后代）
+func (m *Mempool) remove(id string) {
+	entry, ok := m.entries[id]
+	if !ok {
+		return
+	}
+	for _, vin := range entry.tx.Vin {
+		key := outPoint(vin.Txid, vin.Vout)
+		if m.spends[key] == id {
+			delete(m.spends, key)
+		}
+	}
+	delete(m.entries, id)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 在内存中创建区块链，创世块奖励给钱包
+func newTestBlockchain(t *testing.T, wallet *Wallet) *Blockchain {
+	bc := CreateBlockchainWithStore(NewMemoryStore(), string(wallet.GetAddress()))
+	UTXOSet{bc}.Reindex()
+
+	return bc
+}
+
+// 花费prevTx的第vout个输出，转给钱包自己
+func newTestSpend(wallet *Wallet, prevTx *Transaction, vout int, values []int, sequence uint32) *Transaction {
+	var outputs []TXOutput
+	for _, value := range values {
+		outputs = append(outputs, *NewTXOutput(value, string(wallet.GetAddress())))
+	}
+	tx := Transaction{nil, []TXInput{{prevTx.ID, vout, nil, sequence}}, outputs, 0}
+	tx.ID = tx.Hash()
+	tx.Sign(wallet.PrivateKey, map[string]Transaction{hexID(prevTx): *prevTx})
+
+	return &tx
+}
+
+func TestMempoolReplaceByFee(t *testing.T) {
+	wallet := NewWallet()
+	bc := newTestBlockchain(t, wallet)
+	genesis := bc.Iterator().Next().Transactions[0]
+	mempool := NewMempool()
+
+	original := newTestSpend(wallet, genesis, 0, []int{9}, SequenceReplaceable)
+	replaced, err := mempool.Accept(bc, original)
+	assert.Nil(t, err)
+	assert.Empty(t, replaced)
+	_, err = mempool.Accept(bc, original)
+	assert.Equal(t, ErrAlreadyInMempool, err)
+
+	//手续费没有提高，不能替换
+	_, err = mempool.Accept(bc, newTestSpend(wallet, genesis, 0, []int{4, 5}, SequenceFinal))
+	assert.NotNil(t, err)
+
+	//子交易随原交易一起被替换，新交易的手续费须高于两者之和
+	child := newTestSpend(wallet, original, 0, []int{8}, SequenceFinal)
+	_, err = mempool.Accept(bc, child)
+	assert.Nil(t, err)
+	_, err = mempool.Accept(bc, newTestSpend(wallet, genesis, 0, []int{8}, SequenceFinal))
+	assert.NotNil(t, err)
+	minFee, _ := mempool.ReplacementFee(original.ID)
+	assert.Equal(t, 3, minFee)
+
+	replacement := newTestSpend(wallet, genesis, 0, []int{7}, SequenceFinal)
+	replaced, err = mempool.Accept(bc, replacement)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{hexID(original), hexID(child)}, replaced)
+	assert.False(t, mempool.Has(original.ID))
+	assert.False(t, mempool.Has(child.ID))
+	fee, _ := mempool.Fee(replacement.ID)
+	assert.Equal(t, 3, fee)
+
+	//替换交易未声明可替换，不能再被替换
+	_, err = mempool.Accept(bc, newTestSpend(wallet, genesis, 0, []int{1}, SequenceFinal))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), ErrNotReplaceable.Error())
+}
+
+func TestMempoolDuplicateInputs(t *testing.T) {
+	wallet := NewWallet()
+	bc := newTestBlockchain(t, wallet)
+	genesis := bc.Iterator().Next().Transactions[0]
+	mempool := NewMempool()
+
+	original := newTestSpend(wallet, genesis, 0, []int{9}, SequenceReplaceable)
+	_, err := mempool.Accept(bc, original)
+	assert.Nil(t, err)
+
+	//重复花费同一输出的交易不能替换交易池中的交易
+	tx := newTestSpend(wallet, genesis, 0, []int{18}, SequenceFinal)
+	tx.Vin = append(tx.Vin, tx.Vin[0])
+	tx.ID = tx.Hash()
+	tx.Sign(wallet.PrivateKey, map[string]Transaction{hexID(genesis): *genesis})
+	_, err = mempool.Accept(bc, tx)
+	assert.Equal(t, ErrDuplicateInput, err)
+	assert.True(t, mempool.Has(original.ID))
+	assert.False(t, mempool.Has(tx.ID))
+}
+
+func TestMempoolChildPaysForParent(t *testing.T) {
+	wallet := NewWallet()
+	bc := newTestBlockchain(t, wallet)
+	genesis := bc.Iterator().Next().Transactions[0]
+	mempool := NewMempool()
+
+	//先确认一笔交易，得到两个金额相同的输出
+	split := newTestSpend(wallet, genesis, 0, []int{5, 5}, SequenceFinal)
+	block := bc.MineBlock([]*Transaction{split, NewCoinbaseTX(string(wallet.GetAddress()), "")})
+	UTXOSet{bc}.Update(block)
+
+	parent := newTestSpend(wallet, split, 0, []int{5}, SequenceFinal)
+	child := newTestSpend(wallet, parent, 0, []int{2}, SequenceFinal)
+	other := newTestSpend(wallet, split, 1, []int{4}, SequenceFinal)
+	for _, tx := range []*Transaction{child, parent, other} {
+		_, err := mempool.Accept(bc, tx)
+		if tx == child {
+			assert.Equal(t, ErrMissingInputs, err, "parent must arrive first")
+			continue
+		}
+		assert.Nil(t, err)
+	}
+	_, err := mempool.Accept(bc, child)
+	assert.Nil(t, err)
+
+	//子交易的高手续费带动父交易优先打包，父交易在子交易之前
+	txs, fees := mempool.SelectTransactions(bc, bc.GetBestHeight()+1, block.Timestamp+1)
+	assert.Equal(t, 4, fees)
+	assert.Equal(t, []string{hexID(parent), hexID(child), hexID(other)}, []string{hexID(txs[0]), hexID(txs[1]), hexID(txs[2])})
+
+	block = bc.MineBlock(append(txs, NewCoinbaseTX(string(wallet.GetAddress()), "")))
+	mempool.RemoveForBlock(block)
+	assert.Equal(t, 0, mempool.Count())
+}
+
+func TestMempoolUnconfirmedChain(t *testing.T) {
+	wallet := NewWallet()
+	bc := newTestBlockchain(t, wallet)
+	genesis := bc.Iterator().Next().Transactions[0]
+	mempool := NewMempool()
+	view := MempoolUTXOView{UTXOSet{bc}, mempool}
+
+	//花费交易池中尚未确认的找零
+	first := NewPaymentTransaction(wallet, []TXOutput{*NewTXOutput(3, string(NewWallet().GetAddress()))}, PaymentOptions{}, view)
+	_, err := mempool.Accept(bc, first)
+	assert.Nil(t, err)
+	assert.False(t, view.FindUnspentOutputs(HashPubKey(wallet.PublicKey))[0].Output.Value == 10, "spent output is hidden")
+	second := NewPaymentTransaction(wallet, []TXOutput{*NewTXOutput(4, string(NewWallet().GetAddress()))}, PaymentOptions{}, view)
+	assert.Equal(t, first.ID, second.Vin[0].Txid)
+	_, err = mempool.Accept(bc, second)
+	assert.Nil(t, err)
+
+	//未确认交易链有长度限制
+	last := second
+	for i := 2; i < maxAncestorCount; i++ {
+		last = newTestSpend(wallet, last, 1, []int{0, 3}, SequenceFinal)
+		_, err = mempool.Accept(bc, last)
+		assert.Nil(t, err)
+	}
+	_, err = mempool.Accept(bc, newTestSpend(wallet, last, 1, []int{0, 3}, SequenceFinal))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), ErrTooLongChain.Error())
+
+	//交易池保存后恢复，祖先与交易按顺序打包进同一区块
+	mempool.Save(bc)
+	restored := LoadMempool(bc)
+	assert.Equal(t, maxAncestorCount, restored.Count())
+	txs := restored.Package(last.ID)
+	assert.Equal(t, maxAncestorCount, len(txs))
+	assert.Equal(t, genesis.ID, txs[0].Vin[0].Txid)
+	block := bc.MineBlock(append([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), "")}, txs...))
+	restored.RemoveForBlock(block)
+	assert.Equal(t, 0, restored.Count())
+}
+
+func TestMempoolRestoreForBlock(t *testing.T) {
+	wallet := NewWallet()
+	bc := newTestBlockchain(t, wallet)
+	genesis := bc.Iterator().Next()
+	mempool := NewMempool()
+
+	spend := newTestSpend(wallet, genesis.Transactions[0], 0, []int{9}, SequenceFinal)
+	old := bc.MineBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), ""), spend})
+
+	//更高的分支不含该交易，切换后交易回到交易池
+	fork := NewBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), "fork")}, genesis.Hash, 1)
+	bc.AddBlock(fork)
+	next := NewBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), "next")}, fork.Hash, 2)
+	connected, disconnected := bc.AddBlock(next)
+	assert.Len(t, connected, 2)
+	assert.Len(t, disconnected, 1)
+	assert.Equal(t, old.Hash, disconnected[0].Hash)
+
+	mempool.RestoreForBlock(bc, disconnected[0])
+	assert.True(t, mempool.Has(spend.ID))
+	assert.Equal(t, 1, mempool.Count(), "coinbase is not restored")
+
+	//与新主链冲突的交易不放回交易池
+	conflict := newTestSpend(wallet, genesis.Transactions[0], 0, []int{8}, SequenceFinal)
+	other := NewMempool()
+	replaced := NewBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), "conflict"), conflict}, next.Hash, 3)
+	bc.AddBlock(replaced)
+	other.RestoreForBlock(bc, old)
+	assert.Equal(t, 0, other.Count())
+}
@@ -29,7 +29,10 @@ func NewMerkleTree(data [][]byte) *MerkleTree { //构造默克尔树
 		nodes = append(nodes, *node)
 	}
 
-	for i := 0; i < len(data)/2; i++ { //二叉树深度
+	for len(nodes) > 1 { //逐层向上，直到只剩树根
+		if len(nodes)%2 != 0 { //奇数，最后一个节点double一个
+			nodes = append(nodes, nodes[len(nodes)-1])
+		}
 		var newLevel []MerkleNode
 
 		for j := 0; j < len(nodes); j += 2 { //每层的叶子节点
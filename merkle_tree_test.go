@@ -73,3 +73,34 @@ func TestNewMerkleTree(t *testing.T) {
 
 	assert.Equal(t, rootHash, fmt.Sprintf("%x", mTree.RootNode.Data), "Merkle tree root hash is correct")
 }
+
+func TestNewMerkleTreeOddLevel(t *testing.T) {
+	data := [][]byte{
+		[]byte("node1"),
+		[]byte("node2"),
+		[]byte("node3"),
+		[]byte("node4"),
+		[]byte("node5"),
+	}
+	var leaves []*MerkleNode
+	for _, datum := range data {
+		leaves = append(leaves, NewMerkleNode(nil, nil, datum))
+	}
+	leaves = append(leaves, leaves[4])
+
+	// Level 2: 3 nodes, the last one is doubled
+	n1 := NewMerkleNode(leaves[0], leaves[1], nil)
+	n2 := NewMerkleNode(leaves[2], leaves[3], nil)
+	n3 := NewMerkleNode(leaves[4], leaves[5], nil)
+
+	// Level 3
+	n4 := NewMerkleNode(n1, n2, nil)
+	n5 := NewMerkleNode(n3, n3, nil)
+
+	// Level 4
+	n6 := NewMerkleNode(n4, n5, nil)
+
+	mTree := NewMerkleTree(data)
+
+	assert.Equal(t, fmt.Sprintf("%x", n6.Data), fmt.Sprintf("%x", mTree.RootNode.Data), "Merkle tree root hash is correct")
+}
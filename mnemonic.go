@@ -0,0 +1,112 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// 助记词（BIP39）：熵 + 校验和按11位一组映射为单词，便于抄写备份HD种子
+
+// 生成种子时PBKDF2的迭代次数
+const mnemonicIterations = 2048
+
+// 助记词错误
+var (
+	ErrInvalidMnemonic         = errors.New("invalid mnemonic")
+	ErrInvalidMnemonicChecksum = errors.New("mnemonic checksum does not match")
+)
+
+// 单词 -> 在词表中的序号
+var mnemonicWordIndex = func() map[string]int {
+	index := make(map[string]int)
+	for i, word := range mnemonicWordList {
+		index[word] = i
+	}
+	return index
+}()
+
+// 生成随机助记词，bits为熵的位数：128（12个单词）到256（24个单词），须为32的倍数
+func NewMnemonic(bits int) (string, error) {
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return "", fmt.Errorf("entropy must be 128 to 256 bits in steps of 32, got %d", bits)
+	}
+	entropy := make([]byte, bits/8)
+	_, err := rand.Read(entropy)
+	if err != nil {
+		return "", err
+	}
+
+	return EntropyToMnemonic(entropy)
+}
+
+// 熵转换为助记词：熵后附加其sha256的前len/32位作为校验和
+func EntropyToMnemonic(entropy []byte) (string, error) {
+	bits := len(entropy) * 8
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return "", fmt.Errorf("entropy must be 16 to 32 bytes in steps of 4, got %d", len(entropy))
+	}
+	checksumBits := bits / 32
+	hash := sha256.Sum256(entropy)
+
+	data := new(big.Int).SetBytes(entropy)
+	data.Lsh(data, uint(checksumBits))
+	data.Or(data, big.NewInt(int64(hash[0]>>(8-checksumBits))))
+
+	//每11位对应一个单词，从低位开始取
+	words := make([]string, (bits+checksumBits)/11)
+	mask := big.NewInt(2047)
+	for i := len(words) - 1; i >= 0; i-- {
+		index := new(big.Int).And(data, mask).Int64()
+		words[i] = mnemonicWordList[index]
+		data.Rsh(data, 11)
+	}
+
+	return strings.Join(words, " "), nil
+}
+
+// 助记词还原为熵，并校验单词和校验和
+func MnemonicToEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, fmt.Errorf("%v: expected 12, 15, 18, 21 or 24 words, got %d", ErrInvalidMnemonic, len(words))
+	}
+
+	data := new(big.Int)
+	for _, word := range words {
+		index, ok := mnemonicWordIndex[strings.ToLower(word)]
+		if !ok {
+			return nil, fmt.Errorf("%v: unknown word %q", ErrInvalidMnemonic, word)
+		}
+		data.Lsh(data, 11)
+		data.Or(data, big.NewInt(int64(index)))
+	}
+
+	checksumBits := len(words) * 11 / 33
+	checksum := new(big.Int).And(data, big.NewInt(int64(1)<<uint(checksumBits)-1)).Int64()
+	data.Rsh(data, uint(checksumBits))
+	entropy := paddedBytes(data, checksumBits*4)
+
+	hash := sha256.Sum256(entropy)
+	if int64(hash[0]>>(8-checksumBits)) != checksum {
+		return nil, ErrInvalidMnemonicChecksum
+	}
+
+	return entropy, nil
+}
+
+// 助记词加上可选的密码生成64字节的HD种子；不同的密码得到不同的钱包
+func MnemonicToSeed(mnemonic, passphrase string) ([]byte, error) {
+	if _, err := MnemonicToEntropy(mnemonic); err != nil {
+		return nil, err
+	}
+	normalized := strings.ToLower(strings.Join(strings.Fields(mnemonic), " "))
+
+	return pbkdf2.Key([]byte(normalized), []byte("mnemonic"+passphrase), mnemonicIterations, 64, sha512.New), nil
+}
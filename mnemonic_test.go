@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMnemonic(t *testing.T) {
+	//BIP39测试向量，密码为TREZOR
+	vectors := []struct {
+		entropy  string
+		mnemonic string
+		seed     string
+	}{
+		{
+			"00000000000000000000000000000000",
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		},
+		{
+			"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+			"legal winner thank year wave sausage worth useful legal winner thank yellow",
+			"2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+		},
+		{
+			"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+			strings.Repeat("zoo ", 23) + "vote",
+			"dd48c104698c30cfe2b6142103248622fb7bb0ff692eebb00089b32d22484e1613912f0a5b694407be899ffd31ed3992c456cdf60f5d4564b8ba3f05a69890ad",
+		},
+	}
+	for _, v := range vectors {
+		entropy, _ := hex.DecodeString(v.entropy)
+		mnemonic, err := EntropyToMnemonic(entropy)
+		assert.Nil(t, err)
+		assert.Equal(t, v.mnemonic, mnemonic)
+
+		decoded, err := MnemonicToEntropy(mnemonic)
+		assert.Nil(t, err)
+		assert.Equal(t, entropy, decoded)
+
+		seed, err := MnemonicToSeed(mnemonic, "TREZOR")
+		assert.Nil(t, err)
+		assert.Equal(t, v.seed, hex.EncodeToString(seed))
+	}
+
+	mnemonic, err := NewMnemonic(128)
+	assert.Nil(t, err)
+	assert.Equal(t, 12, len(strings.Fields(mnemonic)))
+
+	_, err = MnemonicToEntropy(strings.Repeat("abandon ", 12))
+	assert.Equal(t, ErrInvalidMnemonicChecksum, err)
+	_, err = MnemonicToEntropy(strings.Repeat("abandon ", 11) + "bitcoinz")
+	assert.NotNil(t, err)
+}
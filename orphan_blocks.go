@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// 孤块：祖先区块尚未全部收到的区块，只保存区块，不校验相对时间锁，也不连接到链状态
+// 同步时区块从链尾开始逆序收到；父区块连接后，依次校验并连接其后的孤块，较高的分支成为链尾
+
+const orphanBucket = "orphans"
+
+// 孤块在orphans桶中的键：父区块hash + 区块hash，由父区块查找其后的孤块
+func orphanKey(block *Block) []byte {
+	return append(append([]byte{}, block.PrevBlockHash...), block.Hash...)
+}
+
+// 区块是否已保存，且不是孤块（祖先区块都已连接）
+func (bc *Blockchain) isConnected(hash []byte) bool {
+	block, err := bc.db.GetBlock(hash)
+	if err != nil {
+		return false
+	}
+
+	return bc.db.Get(orphanBucket, orphanKey(block)) == nil
+}
+
+// 保存孤块
+func (bc *Blockchain) addOrphan(block *Block) {
+	batch := &WriteBatch{}
+	batch.PutBlock(block)
+	batch.Put(orphanBucket, orphanKey(block), []byte{1})
+	err := bc.db.Write(batch)
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// 父区块为parent的孤块
+func (bc *Blockchain) orphansOf(parent *Block) []*Block {
+	var hashes [][]byte
+	err := bc.db.ForEachPrefix(orphanBucket, parent.Hash, func(key, value []byte) error {
+		hashes = append(hashes, key[len(parent.Hash):])
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	var orphans []*Block
+	for _, hash := range hashes {
+		block, err := bc.db.GetBlock(hash)
+		if err != nil {
+			log.Panic(err)
+		}
+		orphans = append(orphans, block)
+	}
+
+	return orphans
+}
+
+// 连接parent之后的孤块：逐个校验相对时间锁，不满足的孤块被删除，其后的孤块不再连接
+// 返回parent及连接的孤块中高度最高的区块
+func (bc *Blockchain) connectOrphans(parent *Block) *Block {
+	best := parent
+	queue := []*Block{parent}
+	for len(queue) > 0 {
+		block := queue[0]
+		queue = queue[1:]
+
+		for _, orphan := range bc.orphansOf(block) {
+			batch := &WriteBatch{}
+			batch.Delete(orphanBucket, orphanKey(orphan))
+			valid := true
+			if err := bc.checkBlockLocks(orphan); err != nil {
+				fmt.Printf("Rejected block %x: %s\n", orphan.Hash, err)
+				batch.Delete(blocksBucket, orphan.Hash)
+				valid = false
+			}
+			if err := bc.db.Write(batch); err != nil {
+				log.Panic(err)
+			}
+			if !valid {
+				continue
+			}
+
+			if orphan.Height > best.Height {
+				best = orphan
+			}
+			queue = append(queue, orphan)
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,462 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// 部分签名交易（PST）：交易的创建、签名、合并、组装可以在不同机器上完成，私钥无需与节点放在一起
+// 每个输入都携带被花费的完整交易，加载时校验其hash与输入引用的txid一致，
+// 离线签名者无需区块链数据库、也无需信任创建者即可核对金额和手续费
+type PartialTransaction struct {
+	Tx     Transaction    //交易，组装前解锁脚本为空
+	Inputs []PartialInput //与Tx.Vin一一对应
+}
+
+// 部分签名交易的输入
+type PartialInput struct {
+	PrevTx       Transaction       //被花费的交易，其hash须等于输入的txid
+	PrevOut      TXOutput          //被花费的输出
+	RedeemScript []byte            //被花费的输出为P2SH时的赎回脚本
+	Signatures   map[string][]byte //公钥(hex) -> 签名
+}
+
+// 部分签名交易不支持的输入
+var ErrUnsupportedInput = errors.New("input cannot be signed with keys alone")
+
+// 创建花费prevTx第vout个输出的输入
+func NewPartialInput(prevTx *Transaction, vout int, redeemScript []byte) PartialInput {
+	return PartialInput{*prevTx, prevTx.Vout[vout], redeemScript, make(map[string][]byte)}
+}
+
+// 创建花费地址资金的部分签名交易：转出地址、P2SH地址的赎回脚本、交易输出、锁定时间、utxo集合
+// 只需要地址，不需要私钥；不足部分找零给转出地址
+func NewPartialTransaction(from string, redeemScript []byte, outputs []TXOutput, lockTime int64, UTXOSet *UTXOSet) *PartialTransaction {
+	var inputs []TXInput
+	var partialInputs []PartialInput
+
+	amount := 0
+	for _, out := range outputs {
+		amount += out.Value
+	}
+	needed := amount
+	if needed == 0 {
+		needed = 1
+	}
+	_, hash := decodeAddress(from)
+	acc, validOutputs := UTXOSet.FindSpendableOutputs(hash, needed)
+	if acc < needed {
+		log.Panic("ERROR: Not enough funds")
+	}
+
+	var sequence uint32 = SequenceFinal
+	if lockTime != 0 {
+		sequence = SequenceFinal - 1
+	}
+	//时间锁：交易的锁定时间须达到脚本要求
+	if lt, _, ok := ExtractTimeLock(redeemScript); ok {
+		if lockTime < lt {
+			lockTime = lt
+		}
+		sequence = SequenceFinal - 1
+	}
+	//相对时间锁：输入的序列号须达到脚本要求
+	if seq, _, ok := ExtractRelativeLock(redeemScript); ok {
+		sequence = uint32(seq)
+	}
+
+	for txid, outs := range validOutputs {
+		txID, err := hex.DecodeString(txid)
+		if err != nil {
+			log.Panic(err)
+		}
+		prevTx, err := UTXOSet.Blockchain.FindTransaction(txID)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		for _, out := range outs {
+			inputs = append(inputs, TXInput{txID, out, nil, sequence})
+
+			in := NewPartialInput(&prevTx, out, nil)
+			if ExtractScriptHash(in.PrevOut.ScriptPubKey) != nil {
+				in.RedeemScript = redeemScript
+			}
+			partialInputs = append(partialInputs, in)
+		}
+	}
+
+	if acc > amount {
+		outputs = append(outputs, *NewTXOutput(acc-amount, from))
+	}
+
+	tx := Transaction{nil, inputs, outputs, lockTime}
+	tx.ID = tx.Hash()
+
+	return &PartialTransaction{tx, partialInputs}
+}
+
+// 校验每个输入携带的被花费交易：hash须等于输入引用的txid，被花费的输出须与之一致
+func (ptx *PartialTransaction) CheckPrevTxs() error {
+	for inID := range ptx.Inputs {
+		in, vin := &ptx.Inputs[inID], ptx.Tx.Vin[inID]
+		if !bytes.Equal(in.PrevTx.Hash(), vin.Txid) {
+			return fmt.Errorf("input %d: previous transaction does not match %x", inID, vin.Txid)
+		}
+		if vin.Vout < 0 || vin.Vout >= len(in.PrevTx.Vout) {
+			return fmt.Errorf("input %d: previous transaction has no output %d", inID, vin.Vout)
+		}
+		prevOut := in.PrevTx.Vout[vin.Vout]
+		if prevOut.Value != in.PrevOut.Value || !bytes.Equal(prevOut.ScriptPubKey, in.PrevOut.ScriptPubKey) {
+			return fmt.Errorf("input %d: spent output does not match the previous transaction", inID)
+		}
+	}
+
+	return nil
+}
+
+// 签名所针对的脚本：P2SH为赎回脚本，其他为被花费输出的锁定脚本
+func (in PartialInput) Subscript() []byte {
+	if in.RedeemScript != nil {
+		return in.RedeemScript
+	}
+
+	return in.PrevOut.ScriptPubKey
+}
+
+// 输入所需的签名数及签名者：多签脚本返回公钥列表，其余以P2PKH结尾的脚本返回公钥哈希
+func (in PartialInput) signers() (int, [][]byte, []byte) {
+	script := in.Subscript()
+
+	if m, pubKeys, ok := ExtractMultiSig(script); ok {
+		return m, pubKeys, nil
+	}
+	if pubKeyHash := ExtractPubKeyHash(script); pubKeyHash != nil {
+		return 1, nil, pubKeyHash
+	}
+	if _, pubKeyHash, ok := ExtractTimeLock(script); ok {
+		return 1, nil, pubKeyHash
+	}
+	if _, pubKeyHash, ok := ExtractRelativeLock(script); ok {
+		return 1, nil, pubKeyHash
+	}
+
+	return 0, nil, nil
+}
+
+// 公钥能否为该输入签名
+func (in PartialInput) canSign(pubKey []byte) bool {
+	_, pubKeys, pubKeyHash := in.signers()
+	if pubKeyHash != nil {
+		return bytes.Equal(HashPubKey(pubKey), pubKeyHash)
+	}
+	for _, key := range pubKeys {
+		if bytes.Equal(key, pubKey) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 用私钥为所有可签名的输入签名，返回签名的输入数
+func (ptx *PartialTransaction) Sign(privKey ecdsa.PrivateKey) int {
+	signed := 0
+
+	for inID, in := range ptx.Inputs {
+		//旧版本钱包的输出可能锁定到未补齐的公钥
+		for _, pubKey := range pubKeyEncodings(privKey.PublicKey) {
+			if !in.canSign(pubKey) {
+				continue
+			}
+
+			hash := ptx.Tx.SignatureHash(inID, in.Subscript(), SigHashAll)
+			in.Signatures[hex.EncodeToString(pubKey)] = append(signHash(privKey, hash), SigHashAll)
+			signed++
+			break
+		}
+	}
+
+	return signed
+}
+
+// 合并另一份同一交易的部分签名交易中的签名
+func (ptx *PartialTransaction) Combine(other *PartialTransaction) error {
+	if !bytes.Equal(ptx.Tx.Hash(), other.Tx.Hash()) || len(ptx.Inputs) != len(other.Inputs) {
+		return errors.New("partially signed transactions are for different transactions")
+	}
+
+	//先校验全部签名，有无效签名时不合并任何签名
+	for inID, in := range other.Inputs {
+		for pubKey, sig := range in.Signatures {
+			key, err := hex.DecodeString(pubKey)
+			if err != nil || !ptx.checkSignature(inID, key, sig) {
+				return fmt.Errorf("input %d: invalid signature for public key %s", inID, pubKey)
+			}
+		}
+	}
+	for inID, in := range other.Inputs {
+		for pubKey, sig := range in.Signatures {
+			ptx.Inputs[inID].Signatures[pubKey] = sig
+		}
+	}
+
+	return nil
+}
+
+// 签名是否为pubKey对第inID个输入的有效签名
+func (ptx *PartialTransaction) checkSignature(inID int, pubKey, sig []byte) bool {
+	in := ptx.Inputs[inID]
+	if len(sig) == 0 || !in.canSign(pubKey) {
+		return false
+	}
+	checker := txSigChecker{&ptx.Tx, inID}
+
+	return checker.CheckSig(sig, pubKey, in.Subscript())
+}
+
+// 第inID个输入中pubKey的有效签名
+func (ptx *PartialTransaction) validSignature(inID int, pubKey []byte) ([]byte, bool) {
+	sig, ok := ptx.Inputs[inID].Signatures[hex.EncodeToString(pubKey)]
+	if !ok || !ptx.checkSignature(inID, pubKey, sig) {
+		return nil, false
+	}
+
+	return sig, true
+}
+
+// 第inID个输入中有有效签名的单个签名者（非多签脚本），按公钥排序以保证结果确定
+func (ptx *PartialTransaction) singleSigner(inID int) ([]byte, []byte, bool) {
+	var keys []string
+	for pubKey := range ptx.Inputs[inID].Signatures {
+		keys = append(keys, pubKey)
+	}
+	sort.Strings(keys)
+
+	for _, pubKey := range keys {
+		key, err := hex.DecodeString(pubKey)
+		if err != nil {
+			continue
+		}
+		if sig, ok := ptx.validSignature(inID, key); ok {
+			return key, sig, true
+		}
+	}
+
+	return nil, nil, false
+}
+
+// 第inID个输入已有的有效签名数和所需的签名数
+func (ptx *PartialTransaction) SignatureCount(inID int) (int, int) {
+	in := ptx.Inputs[inID]
+	need, pubKeys, _ := in.signers()
+
+	have := 0
+	if pubKeys != nil {
+		//多签脚本中的每个公钥各计一次
+		for _, pubKey := range pubKeys {
+			if _, ok := ptx.validSignature(inID, pubKey); ok {
+				have++
+			}
+		}
+		return have, need
+	}
+	if _, _, ok := ptx.singleSigner(inID); ok {
+		return 1, need
+	}
+
+	return have, need
+}
+
+// 所有输入的签名数是否均已达到阈值
+func (ptx *PartialTransaction) IsComplete() bool {
+	for inID := range ptx.Inputs {
+		have, need := ptx.SignatureCount(inID)
+		if need == 0 || have < need {
+			return false
+		}
+	}
+
+	return true
+}
+
+// 是否已组装：所有输入均已有解锁脚本
+func (ptx *PartialTransaction) IsFinalized() bool {
+	for _, vin := range ptx.Tx.Vin {
+		if vin.ScriptSig == nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// 输入总额
+func (ptx *PartialTransaction) InputValue() int {
+	value := 0
+	for _, in := range ptx.Inputs {
+		value += in.PrevOut.Value
+	}
+
+	return value
+}
+
+// 输出总额
+func (ptx *PartialTransaction) OutputValue() int {
+	value := 0
+	for _, out := range ptx.Tx.Vout {
+		value += out.Value
+	}
+
+	return value
+}
+
+// 手续费：输入总额 - 输出总额
+func (ptx *PartialTransaction) Fee() int {
+	return ptx.InputValue() - ptx.OutputValue()
+}
+
+// 组装解锁脚本，并用被花费的输出校验，返回可广播的交易
+func (ptx *PartialTransaction) Finalize() (*Transaction, error) {
+	for inID := range ptx.Inputs {
+		if err := ptx.FinalizeInput(inID); err != nil {
+			return nil, err
+		}
+	}
+
+	tx := ptx.Tx
+	tx.Vin = make([]TXInput, len(ptx.Tx.Vin))
+	copy(tx.Vin, ptx.Tx.Vin)
+
+	return &tx, nil
+}
+
+// 组装第inID个输入的解锁脚本并校验
+// 多签：<sig1> ... <sigM> [赎回脚本]；其他：<sig> <pubKey> [赎回脚本]
+func (ptx *PartialTransaction) FinalizeInput(inID int) error {
+	in := ptx.Inputs[inID]
+	need, pubKeys, _ := in.signers()
+	if need == 0 {
+		return fmt.Errorf("input %d: %v", inID, ErrUnsupportedInput)
+	}
+
+	builder := NewScriptBuilder()
+	count := 0
+	if pubKeys != nil {
+		//签名须按公钥顺序排列
+		for _, pubKey := range pubKeys {
+			if count == need {
+				break
+			}
+			if sig, ok := ptx.validSignature(inID, pubKey); ok {
+				builder.AddData(sig)
+				count++
+			}
+		}
+	} else if key, sig, ok := ptx.singleSigner(inID); ok {
+		builder.AddData(sig).AddData(key)
+		count++
+	}
+	if count < need {
+		return fmt.Errorf("input %d has %d of %d signatures", inID, count, need)
+	}
+	if in.RedeemScript != nil {
+		builder.AddData(in.RedeemScript)
+	}
+	ptx.Tx.Vin[inID].ScriptSig = builder.Script()
+
+	if err := ptx.Tx.VerifyInput(inID, in.PrevOut.ScriptPubKey); err != nil {
+		return fmt.Errorf("input %d: %v", inID, err)
+	}
+
+	return nil
+}
+
+// 打印交易摘要：输入、输出、手续费及签名进度，供签名前核对
+func (ptx *PartialTransaction) String() string {
+	var lines []string
+
+	lines = append(lines, fmt.Sprintf("Transaction %x", ptx.Tx.ID))
+	for inID, in := range ptx.Inputs {
+		have, need := ptx.SignatureCount(inID)
+		lines = append(lines, fmt.Sprintf("  Input %d: %x:%d %d from %s (%d of %d signatures)",
+			inID, ptx.Tx.Vin[inID].Txid, ptx.Tx.Vin[inID].Vout, in.PrevOut.Value, describeScript(in.PrevOut.ScriptPubKey), have, need))
+	}
+	for outID, out := range ptx.Tx.Vout {
+		lines = append(lines, fmt.Sprintf("  Output %d: %d to %s", outID, out.Value, describeScript(out.ScriptPubKey)))
+	}
+	lines = append(lines, fmt.Sprintf("  Fee: %d", ptx.Fee()))
+	if ptx.Tx.LockTime != 0 {
+		lines = append(lines, fmt.Sprintf("  LockTime: %d", ptx.Tx.LockTime))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// 锁定脚本的可读描述：地址或脚本类型
+func describeScript(script []byte) string {
+	if address := ExtractAddress(script); address != "" {
+		return address
+	}
+
+	return ScriptClass(script)
+}
+
+// 保存部分签名交易到文件
+func (ptx PartialTransaction) SaveToFile(file string) {
+	var content bytes.Buffer
+
+	encoder := gob.NewEncoder(&content)
+	err := encoder.Encode(ptx)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	err = ioutil.WriteFile(file, content.Bytes(), 0644)
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// 从文件中加载部分签名交易
+func LoadPartialTransaction(file string) (*PartialTransaction, error) {
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		return nil, err
+	}
+
+	fileContent, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var ptx PartialTransaction
+	decoder := gob.NewDecoder(bytes.NewReader(fileContent))
+	err = decoder.Decode(&ptx)
+	if err != nil {
+		return nil, err
+	}
+	if len(ptx.Inputs) != len(ptx.Tx.Vin) {
+		return nil, errors.New("partially signed transaction is malformed")
+	}
+	//金额和手续费以被花费的交易为准，不信任文件中单独给出的输出
+	if err := ptx.CheckPrevTxs(); err != nil {
+		return nil, err
+	}
+	//gob不会编码空map
+	for inID := range ptx.Inputs {
+		if ptx.Inputs[inID].Signatures == nil {
+			ptx.Inputs[inID].Signatures = make(map[string][]byte)
+		}
+	}
+
+	return &ptx, nil
+}
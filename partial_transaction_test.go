@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartialTransactionWorkflow(t *testing.T) {
+	wallet := NewWallet()
+	keys := []*Wallet{NewWallet(), NewWallet()}
+	redeemScript := NewMultiSigScript(2, [][]byte{keys[0].PublicKey, keys[1].PublicKey})
+
+	p2pkh := newPrevTx(NewP2PKHScript(HashPubKey(wallet.PublicKey)))
+	p2sh := newPrevTx(NewTXOutput(10, scriptAddress(redeemScript)).ScriptPubKey)
+	prevTXs := map[string]Transaction{hexID(p2pkh): *p2pkh, hexID(p2sh): *p2sh}
+
+	tx := Transaction{nil, []TXInput{
+		{p2pkh.ID, 0, nil, SequenceFinal},
+		{p2sh.ID, 0, nil, SequenceFinal},
+	}, []TXOutput{*NewTXOutput(15, string(wallet.GetAddress()))}, 0}
+	tx.ID = tx.Hash()
+	ptx := PartialTransaction{tx, []PartialInput{
+		NewPartialInput(p2pkh, 0, nil),
+		NewPartialInput(p2sh, 0, redeemScript),
+	}}
+	assert.Equal(t, 5, ptx.Fee(), "amounts are known without the chain")
+
+	//各签名者分别签名同一份交易，再合并签名
+	copies := make([]*PartialTransaction, 3)
+	for i := range copies {
+		data := ptx
+		data.Inputs = []PartialInput{
+			NewPartialInput(p2pkh, 0, nil),
+			NewPartialInput(p2sh, 0, redeemScript),
+		}
+		copies[i] = &data
+	}
+	assert.Equal(t, 1, copies[0].Sign(wallet.PrivateKey))
+	assert.Equal(t, 1, copies[1].Sign(keys[0].PrivateKey))
+	assert.Equal(t, 1, copies[2].Sign(keys[1].PrivateKey))
+
+	combined := copies[0]
+	_, err := combined.Finalize()
+	assert.NotNil(t, err, "multisig input lacks signatures")
+
+	assert.Nil(t, combined.Combine(copies[1]))
+	assert.Nil(t, combined.Combine(copies[2]))
+	assert.True(t, combined.IsComplete())
+
+	final, err := combined.Finalize()
+	assert.Nil(t, err)
+	assert.True(t, combined.IsFinalized())
+	assert.True(t, final.Verify(prevTXs))
+	assert.Equal(t, tx.ID, final.Hash())
+
+	other := ptx
+	other.Tx.Vout = []TXOutput{*NewTXOutput(14, string(wallet.GetAddress()))}
+	assert.NotNil(t, combined.Combine(&other), "signatures of a different transaction")
+}
+
+func TestPartialTransactionPrevTxs(t *testing.T) {
+	wallet := NewWallet()
+	prevTx := newPrevTx(NewP2PKHScript(HashPubKey(wallet.PublicKey)))
+	tx := newSpendingTx(prevTx, string(wallet.GetAddress()))
+	tx.Vout[0].Value = 9
+	tx.ID = tx.Hash()
+	dir, err := ioutil.TempDir("", "pst")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "tx.pst")
+
+	ptx := PartialTransaction{*tx, []PartialInput{NewPartialInput(prevTx, 0, nil)}}
+	ptx.SaveToFile(file)
+	loaded, err := LoadPartialTransaction(file)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, loaded.Fee())
+
+	//单独修改被花费的输出，金额与被花费的交易不符
+	ptx.Inputs[0].PrevOut.Value = 100
+	ptx.SaveToFile(file)
+	_, err = LoadPartialTransaction(file)
+	assert.NotNil(t, err)
+
+	//一起修改被花费的交易，其hash不再等于输入的txid
+	ptx.Inputs[0].PrevTx.Vout = []TXOutput{ptx.Inputs[0].PrevOut}
+	ptx.SaveToFile(file)
+	_, err = LoadPartialTransaction(file)
+	assert.NotNil(t, err)
+}
+
+func TestPartialTransactionBadSignature(t *testing.T) {
+	keys := []*Wallet{NewWallet(), NewWallet()}
+	redeemScript := NewMultiSigScript(1, [][]byte{keys[0].PublicKey, keys[1].PublicKey})
+	prevTx := newPrevTx(NewTXOutput(10, scriptAddress(redeemScript)).ScriptPubKey)
+	tx := newSpendingTx(prevTx, string(keys[0].GetAddress()))
+	ptx := PartialTransaction{*tx, []PartialInput{NewPartialInput(prevTx, 0, redeemScript)}}
+
+	//篡改的签名不能合并
+	bad := ptx
+	bad.Inputs = []PartialInput{NewPartialInput(prevTx, 0, redeemScript)}
+	assert.Equal(t, 1, bad.Sign(keys[0].PrivateKey))
+	sig := bad.Inputs[0].Signatures[hex.EncodeToString(keys[0].PublicKey)]
+	sig[0] ^= 0xff
+	assert.NotNil(t, ptx.Combine(&bad))
+	assert.Empty(t, ptx.Inputs[0].Signatures, "nothing is merged")
+
+	//文件中已有的无效签名不计数，组装时跳过
+	ptx.Inputs[0].Signatures[hex.EncodeToString(keys[0].PublicKey)] = sig
+	have, need := ptx.SignatureCount(0)
+	assert.Equal(t, 0, have)
+	assert.Equal(t, 1, need)
+	_, err := ptx.Finalize()
+	assert.NotNil(t, err)
+
+	assert.Equal(t, 1, ptx.Sign(keys[1].PrivateKey))
+	assert.True(t, ptx.IsComplete())
+	final, err := ptx.Finalize()
+	assert.Nil(t, err)
+	assert.True(t, final.Verify(map[string]Transaction{hexID(prevTx): *prevTx}))
+}
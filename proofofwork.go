@@ -32,6 +32,14 @@ func NewProofOfWork(b *Block) *ProofOfWork {
 	return pow
 }
 
+// 区块的工作量：找到小于目标值的hash平均需要的计算次数，即2^256/(target+1)
+func (pow *ProofOfWork) Work() *big.Int {
+	denominator := new(big.Int).Add(pow.target, big.NewInt(1))
+	numerator := new(big.Int).Lsh(big.NewInt(1), 256)
+
+	return numerator.Div(numerator, denominator)
+}
+
 //参数哈希计算的数据
 func (pow *ProofOfWork) prepareData(nonce int) []byte {
 	data := bytes.Join(
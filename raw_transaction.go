@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+)
+
+// 交易的JSON表示，用于decoderawtransaction等命令的输出
+type TransactionJSON struct {
+	Txid     string         `json:"txid"`
+	LockTime int64          `json:"locktime"`
+	Vin      []TXInputJSON  `json:"vin"`
+	Vout     []TXOutputJSON `json:"vout"`
+}
+
+// 交易输入的JSON表示；coinbase交易只有数据
+type TXInputJSON struct {
+	Coinbase  string      `json:"coinbase,omitempty"`
+	Txid      string      `json:"txid,omitempty"`
+	Vout      int         `json:"vout"`
+	ScriptSig *ScriptJSON `json:"scriptSig,omitempty"`
+	Sequence  uint32      `json:"sequence"`
+}
+
+// 交易输出的JSON表示
+type TXOutputJSON struct {
+	Value        int        `json:"value"`
+	N            int        `json:"n"`
+	ScriptPubKey ScriptJSON `json:"scriptPubKey"`
+}
+
+// 脚本的JSON表示：反汇编、hex、类型及地址
+type ScriptJSON struct {
+	Asm     string `json:"asm"`
+	Hex     string `json:"hex"`
+	Type    string `json:"type,omitempty"`
+	Address string `json:"address,omitempty"`
+}
+
+// 交易转换为JSON表示
+func NewTransactionJSON(tx *Transaction) TransactionJSON {
+	txJSON := TransactionJSON{
+		Txid:     hex.EncodeToString(tx.ID),
+		LockTime: tx.LockTime,
+		Vin:      []TXInputJSON{},
+		Vout:     []TXOutputJSON{},
+	}
+
+	for _, vin := range tx.Vin {
+		in := TXInputJSON{Vout: vin.Vout, Sequence: vin.Sequence}
+		if tx.IsCoinbase() {
+			in.Coinbase = hex.EncodeToString(vin.ScriptSig)
+		} else {
+			in.Txid = hex.EncodeToString(vin.Txid)
+			in.ScriptSig = &ScriptJSON{Asm: DisasmScript(vin.ScriptSig), Hex: hex.EncodeToString(vin.ScriptSig)}
+		}
+		txJSON.Vin = append(txJSON.Vin, in)
+	}
+
+	for n, vout := range tx.Vout {
+		script := ScriptJSON{
+			Asm:     DisasmScript(vout.ScriptPubKey),
+			Hex:     hex.EncodeToString(vout.ScriptPubKey),
+			Type:    ScriptClass(vout.ScriptPubKey),
+			Address: ExtractAddress(vout.ScriptPubKey),
+		}
+		txJSON.Vout = append(txJSON.Vout, TXOutputJSON{vout.Value, n, script})
+	}
+
+	return txJSON
+}
+
+// 交易编码为hex字符串
+func EncodeRawTransaction(tx *Transaction) string {
+	return hex.EncodeToString(tx.Serialize())
+}
+
+// 从hex字符串解码交易，交易id按内容重新计算
+func DecodeRawTransaction(rawHex string) (*Transaction, error) {
+	data, err := hex.DecodeString(rawHex)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx Transaction
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+	err = decoder.Decode(&tx)
+	if err != nil {
+		return nil, err
+	}
+	if len(tx.Vin) == 0 || len(tx.Vout) == 0 {
+		return nil, errors.New("transaction must have inputs and outputs")
+	}
+	tx.ID = tx.Hash()
+
+	return &tx, nil
+}
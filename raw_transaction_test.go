@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRawTransactionRoundTrip(t *testing.T) {
+	wallet := NewWallet()
+	prevTx := newPrevTx(NewP2PKHScript(HashPubKey(wallet.PublicKey)))
+	tx := newSpendingTx(prevTx, string(wallet.GetAddress()))
+	tx.Sign(wallet.PrivateKey, map[string]Transaction{hexID(prevTx): *prevTx})
+
+	decoded, err := DecodeRawTransaction(EncodeRawTransaction(tx))
+	assert.Nil(t, err)
+	assert.Equal(t, tx.ID, decoded.ID)
+	assert.Equal(t, tx.Vin[0].ScriptSig, decoded.Vin[0].ScriptSig)
+
+	txJSON := NewTransactionJSON(decoded)
+	assert.Equal(t, string(wallet.GetAddress()), txJSON.Vout[0].ScriptPubKey.Address)
+	assert.Equal(t, ScriptPubKeyHash, txJSON.Vout[0].ScriptPubKey.Type)
+
+	_, err = DecodeRawTransaction("zz")
+	assert.NotNil(t, err)
+	_, err = DecodeRawTransaction("00ff")
+	assert.NotNil(t, err)
+}
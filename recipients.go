@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 收款人：地址及金额
+type Recipient struct {
+	Address string `json:"address"`
+	Amount  int    `json:"amount"`
+}
+
+// 解析收款人列表，格式 address:amount,address:amount,...
+func ParseRecipients(list string) ([]Recipient, error) {
+	var recipients []Recipient
+
+	for _, item := range strings.Split(list, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid recipient %q, expected address:amount", item)
+		}
+		amount, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid amount of recipient %q", item)
+		}
+		recipients = append(recipients, Recipient{parts[0], amount})
+	}
+
+	return recipients, nil
+}
+
+// 从文件加载收款人列表：.json为[{"address":...,"amount":...}]，其他按CSV解析，每行 address,amount
+func LoadRecipients(file string) ([]Recipient, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		var recipients []Recipient
+		err = json.Unmarshal(content, &recipients)
+		if err != nil {
+			return nil, err
+		}
+		return recipients, nil
+	}
+
+	var recipients []Recipient
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		amount, err := strconv.Atoi(strings.TrimSpace(record[1]))
+		if err != nil {
+			//首行可以是表头
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: invalid amount %q", line, record[1])
+		}
+		recipients = append(recipients, Recipient{strings.TrimSpace(record[0]), amount})
+	}
+
+	return recipients, nil
+}
+
+// 收款人列表转换为交易输出，校验地址和金额
+func RecipientOutputs(recipients []Recipient) ([]TXOutput, error) {
+	var outputs []TXOutput
+
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("no recipients")
+	}
+	for _, r := range recipients {
+		if !ValidateAddress(r.Address) {
+			return nil, fmt.Errorf("recipient address %q is not valid", r.Address)
+		}
+		if r.Amount <= 0 {
+			return nil, fmt.Errorf("amount for %s must be positive", r.Address)
+		}
+		outputs = append(outputs, *NewTXOutput(r.Amount, r.Address))
+	}
+
+	return outputs, nil
+}
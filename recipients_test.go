@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecipients(t *testing.T) {
+	a := string(NewWallet().GetAddress())
+	b := string(NewWallet().GetAddress())
+
+	recipients, err := ParseRecipients(a + ":2," + b + ":3")
+	assert.Nil(t, err)
+	assert.Equal(t, []Recipient{{a, 2}, {b, 3}}, recipients)
+
+	dir, err := ioutil.TempDir("", "recipients")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	csvFile := filepath.Join(dir, "pay.csv")
+	assert.Nil(t, ioutil.WriteFile(csvFile, []byte("address,amount\n"+a+",2\n"+b+", 3\n"), 0644))
+	fromCSV, err := LoadRecipients(csvFile)
+	assert.Nil(t, err)
+	assert.Equal(t, recipients, fromCSV)
+
+	jsonFile := filepath.Join(dir, "pay.json")
+	assert.Nil(t, ioutil.WriteFile(jsonFile, []byte(`[{"address":"`+a+`","amount":2},{"address":"`+b+`","amount":3}]`), 0644))
+	fromJSON, err := LoadRecipients(jsonFile)
+	assert.Nil(t, err)
+	assert.Equal(t, recipients, fromJSON)
+
+	outputs, err := RecipientOutputs(recipients)
+	assert.Nil(t, err)
+	assert.Len(t, outputs, 2)
+
+	_, err = RecipientOutputs([]Recipient{{a, 0}})
+	assert.NotNil(t, err)
+	_, err = RecipientOutputs([]Recipient{{"bogus", 1}})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"sort"
+	"sync"
+	"time"
+)
+
+// JSON-RPC服务：节点运行时独占数据库，通过RPC使用节点的区块链和钱包
+// 方法名为 Node.<方法>，例如 {"method":"Node.SendMany","params":[{...}],"id":1}
+type RPCService struct {
+	bc     *Blockchain
+	nodeID string
+
+	mu      sync.Mutex
+	wallets map[string]*loadedWallet //名称 -> 加载的钱包，默认钱包的名称为空
+}
+
+// SendMany的参数
+type SendManyArgs struct {
+	From        string      `json:"from"`
+	Recipients  []Recipient `json:"recipients"`
+	Strategy    string      `json:"strategy,omitempty"` //选币策略，为空时使用默认策略
+	Fee         int         `json:"fee,omitempty"`
+	Replaceable bool        `json:"replaceable,omitempty"` //是否允许替换（RBF）
+	Wallet      string      `json:"wallet,omitempty"`      //为空时为默认钱包
+}
+
+// SendMany的返回值
+type SendManyReply struct {
+	Txid string `json:"txid"`
+}
+
+// 批量转账：交易交由本节点处理，与收到的交易一样进入交易池并转发或打包
+func (s *RPCService) SendMany(args SendManyArgs, reply *SendManyReply) (err error) {
+	//创建交易时资金不足等错误以panic报告，转为RPC错误，避免节点退出
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	if !ValidateAddress(args.From) {
+		return fmt.Errorf("sender address is not valid")
+	}
+	outputs, err := RecipientOutputs(args.Recipients)
+	if err != nil {
+		return err
+	}
+	opts := PaymentOptions{Fee: args.Fee, Replaceable: args.Replaceable}
+	opts.Selector, err = GetCoinSelector(args.Strategy)
+	if err != nil {
+		return err
+	}
+	wallets, err := s.loadWallets(args.Wallet)
+	if err != nil {
+		return err
+	}
+	if err := wallets.CheckSpendable(args.From); err != nil {
+		return err
+	}
+	wallet := wallets.GetWallet(args.From)
+
+	//可以花费交易池中尚未确认的找零
+	opts.Change = wallets.NewChangeAddress()
+	tx := NewPaymentTransaction(&wallet, outputs, opts, MempoolUTXOView{UTXOSet{s.bc}, mempool})
+	saveChangeAddress(wallets, tx, opts.Change, WalletID(s.nodeID, args.Wallet))
+	sendTx(nodeAddress, tx)
+
+	reply.Txid = hex.EncodeToString(tx.ID)
+	return nil
+}
+
+// BumpFee的参数
+type BumpFeeArgs struct {
+	Txid   string `json:"txid"`
+	Fee    int    `json:"fee,omitempty"`    //新的手续费，为空时为替换所需的最低手续费
+	Wallet string `json:"wallet,omitempty"` //为空时为默认钱包
+}
+
+// BumpFee的返回值
+type BumpFeeReply struct {
+	Txid   string `json:"txid"`
+	OldFee int    `json:"oldfee"`
+	Fee    int    `json:"fee"`
+}
+
+// 提高交易池中允许替换（RBF）的交易的手续费：从找零中扣除增加的手续费，重新签名后替换原交易
+// 原交易的输入须都属于钱包中的同一个地址，并有找零给该地址的输出
+func (s *RPCService) BumpFee(args BumpFeeArgs, reply *BumpFeeReply) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	txID, err := hex.DecodeString(args.Txid)
+	if err != nil {
+		return err
+	}
+	tx, ok := mempool.Get(txID)
+	if !ok {
+		return fmt.Errorf("transaction %s is not in the mempool", args.Txid)
+	}
+	if !tx.SignalsReplacement() {
+		return ErrNotReplaceable
+	}
+	oldFee, _ := mempool.Fee(txID)
+	//替换时原交易在交易池中的后代也被移除，新交易须付清它们的手续费
+	minFee, _ := mempool.ReplacementFee(txID)
+	fee := args.Fee
+	if fee == 0 {
+		fee = minFee
+	}
+	if fee < minFee {
+		return fmt.Errorf("new fee %d must be at least %d, the fees of the transaction and its descendants plus %d", fee, minFee, minRelayFeeIncrement)
+	}
+
+	//原交易的输入须都来自钱包中的同一个地址
+	prevTXs, err := mempool.PrevTransactions(s.bc, &tx)
+	if err != nil {
+		return err
+	}
+	from := ""
+	for _, vin := range tx.Vin {
+		address := ExtractAddress(prevTXs[hex.EncodeToString(vin.Txid)].Vout[vin.Vout].ScriptPubKey)
+		if address == "" || (from != "" && address != from) {
+			return fmt.Errorf("inputs must be paid to a single wallet address")
+		}
+		from = address
+	}
+	wallets, err := s.loadWallets(args.Wallet)
+	if err != nil {
+		return err
+	}
+	if _, ok := wallets.Wallets[from]; !ok {
+		return fmt.Errorf("address %s is not in the wallet", from)
+	}
+	wallet := wallets.GetWallet(from)
+	if wallet.IsLocked() {
+		return ErrWalletLocked
+	}
+
+	//从找零中扣除增加的手续费，找零不足时无法提高手续费
+	//找零给转出地址，或钱包内部链上的地址
+	change := -1
+	for i, out := range tx.Vout {
+		address := ExtractAddress(out.ScriptPubKey)
+		if address == from || (wallets.Wallets[address] != nil && wallets.Wallets[address].IsChange()) {
+			change = i
+		}
+	}
+	if change < 0 {
+		return fmt.Errorf("transaction has no change output")
+	}
+	value := tx.Vout[change].Value - (fee - oldFee)
+	if value < 0 {
+		return fmt.Errorf("change %d is too small to pay fee %d", tx.Vout[change].Value, fee)
+	}
+	if value == 0 {
+		tx.Vout = append(tx.Vout[:change], tx.Vout[change+1:]...)
+	} else {
+		tx.Vout[change].Value = value
+	}
+
+	for i := range tx.Vin {
+		tx.Vin[i].ScriptSig = nil
+	}
+	tx.ID = tx.Hash()
+	//与send一致由签名器签名，使用钱包中保存的公钥
+	if err := tx.SignWith(NewLocalSigner(&wallet), wallet.PublicKey, prevTXs); err != nil {
+		return err
+	}
+	sendTx(nodeAddress, &tx)
+
+	reply.Txid = hex.EncodeToString(tx.ID)
+	reply.OldFee = oldFee
+	reply.Fee = fee
+	return nil
+}
+
+// 节点加载的钱包
+type loadedWallet struct {
+	key       []byte //walletpassphrase解锁后由密码派生的密钥，超时后清除
+	lockTimer *time.Timer
+}
+
+// LoadWallet、UnloadWallet的参数
+type LoadWalletArgs struct {
+	Name string `json:"name"`
+}
+
+// LoadWallet、UnloadWallet的返回值：加载的所有钱包
+type LoadWalletReply struct {
+	Wallets []string `json:"wallets"`
+}
+
+// 加载命名钱包，之后RPC调用可以用wallet参数指定它
+func (s *RPCService) LoadWallet(args LoadWalletArgs, reply *LoadWalletReply) error {
+	if !ValidateWalletName(args.Name) {
+		return fmt.Errorf("invalid wallet name %q", args.Name)
+	}
+	if !walletExists(WalletID(s.nodeID, args.Name)) {
+		return fmt.Errorf("wallet %q does not exist", args.Name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.wallets[args.Name]; ok {
+		return fmt.Errorf("wallet %q is already loaded", args.Name)
+	}
+	s.wallets[args.Name] = &loadedWallet{}
+
+	reply.Wallets = s.walletNames()
+	return nil
+}
+
+// 卸载钱包，并清除其解锁密钥
+func (s *RPCService) UnloadWallet(args LoadWalletArgs, reply *LoadWalletReply) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	loaded, ok := s.wallets[args.Name]
+	if !ok {
+		return fmt.Errorf("wallet %q is not loaded", args.Name)
+	}
+	if loaded.lockTimer != nil {
+		loaded.lockTimer.Stop()
+	}
+	delete(s.wallets, args.Name)
+
+	reply.Wallets = s.walletNames()
+	return nil
+}
+
+// WalletPassphrase的参数
+type WalletPassphraseArgs struct {
+	Passphrase string `json:"passphrase"`
+	Timeout    int    `json:"timeout"`          //解锁的秒数
+	Wallet     string `json:"wallet,omitempty"` //为空时为默认钱包
+}
+
+// WalletPassphrase的返回值
+type WalletPassphraseReply struct {
+	Expires int64 `json:"expires"` //自动锁定的时间
+}
+
+// 解锁加密的钱包：超时前节点可以用钱包私钥签名，超时后自动锁定
+func (s *RPCService) WalletPassphrase(args WalletPassphraseArgs, reply *WalletPassphraseReply) error {
+	if args.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+	wallets, err := s.loadWallets(args.Wallet)
+	if err != nil {
+		return err
+	}
+	err = wallets.Unlock(args.Passphrase)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	loaded, ok := s.wallets[args.Wallet]
+	if !ok {
+		return fmt.Errorf("wallet %q is not loaded", args.Wallet)
+	}
+	if loaded.lockTimer != nil {
+		loaded.lockTimer.Stop()
+	}
+	timeout := time.Duration(args.Timeout) * time.Second
+	loaded.key = wallets.EncryptionKey()
+	loaded.lockTimer = time.AfterFunc(timeout, func() { s.lockWallet(loaded) })
+
+	reply.Expires = time.Now().Add(timeout).Unix()
+	return nil
+}
+
+// WalletLock的参数
+type WalletLockArgs struct {
+	Wallet string `json:"wallet,omitempty"`
+}
+
+// WalletLock的返回值
+type WalletLockReply struct{}
+
+// 立即锁定钱包
+func (s *RPCService) WalletLock(args WalletLockArgs, reply *WalletLockReply) error {
+	wallets, err := s.loadWallets(args.Wallet)
+	if err != nil {
+		return err
+	}
+	if !wallets.IsEncrypted() {
+		return ErrWalletNotEncrypted
+	}
+
+	s.mu.Lock()
+	loaded := s.wallets[args.Wallet]
+	s.mu.Unlock()
+	if loaded != nil {
+		s.lockWallet(loaded)
+	}
+
+	return nil
+}
+
+// 清除解锁密钥
+func (s *RPCService) lockWallet(loaded *loadedWallet) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if loaded.lockTimer != nil {
+		loaded.lockTimer.Stop()
+		loaded.lockTimer = nil
+	}
+	loaded.key = nil
+}
+
+// 加载的钱包名称，默认钱包为空字符串
+func (s *RPCService) walletNames() []string {
+	var names []string
+	for name := range s.wallets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// 从文件读取已加载的钱包，已通过walletpassphrase解锁时用保存的密钥解锁
+func (s *RPCService) loadWallets(name string) (*Wallets, error) {
+	s.mu.Lock()
+	loaded, ok := s.wallets[name]
+	var key []byte
+	if ok {
+		key = loaded.key
+	}
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("wallet %q is not loaded", name)
+	}
+
+	wallets, err := NewWallets(WalletID(s.nodeID, name))
+	if err != nil {
+		return nil, err
+	}
+	if key != nil && wallets.IsLocked() {
+		err = wallets.UnlockWithKey(key)
+	}
+
+	return wallets, err
+}
+
+// 启动JSON-RPC服务，每个连接一个goroutine
+func StartRPCServer(address, nodeID string, bc *Blockchain) {
+	server := rpc.NewServer()
+	service := &RPCService{bc: bc, nodeID: nodeID, wallets: map[string]*loadedWallet{"": {}}}
+	err := server.RegisterName("Node", service)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	ln, err := net.Listen(protocol, address)
+	if err != nil {
+		log.Panic(err)
+	}
+	fmt.Printf("JSON-RPC server is listening on %s\n", address)
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Panic(err)
+		}
+		go server.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
@@ -0,0 +1,596 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 脚本操作码：锁定脚本（ScriptPubKey）和解锁脚本（ScriptSig）均由操作码和数据组成
+const (
+	OP_0                   = 0x00 //压入空字节数组
+	OP_FALSE               = OP_0
+	OP_DATA_1              = 0x01 //0x01-0x4b：压入紧随其后的n个字节
+	OP_DATA_75             = 0x4b
+	OP_PUSHDATA1           = 0x4c //下一个字节为数据长度
+	OP_PUSHDATA2           = 0x4d //下两个字节（小端）为数据长度
+	OP_1NEGATE             = 0x4f
+	OP_1                   = 0x51 //OP_1-OP_16：压入数字1-16
+	OP_TRUE                = OP_1
+	OP_16                  = 0x60
+	OP_NOP                 = 0x61
+	OP_IF                  = 0x63
+	OP_NOTIF               = 0x64
+	OP_ELSE                = 0x67
+	OP_ENDIF               = 0x68
+	OP_VERIFY              = 0x69
+	OP_RETURN              = 0x6a
+	OP_DROP                = 0x75
+	OP_DUP                 = 0x76
+	OP_SWAP                = 0x7c
+	OP_SIZE                = 0x82
+	OP_EQUAL               = 0x87
+	OP_EQUALVERIFY         = 0x88
+	OP_SHA256              = 0xa8
+	OP_HASH160             = 0xa9
+	OP_CHECKSIG            = 0xac
+	OP_CHECKSIGVERIFY      = 0xad
+	OP_CHECKMULTISIG       = 0xae
+	OP_CHECKMULTISIGVERIFY = 0xaf
+	OP_CHECKLOCKTIMEVERIFY = 0xb1
+	OP_CHECKSEQUENCEVERIFY = 0xb2
+)
+
+var opcodeNames = map[byte]string{
+	OP_0:                   "OP_0",
+	OP_PUSHDATA1:           "OP_PUSHDATA1",
+	OP_PUSHDATA2:           "OP_PUSHDATA2",
+	OP_1NEGATE:             "OP_1NEGATE",
+	OP_NOP:                 "OP_NOP",
+	OP_IF:                  "OP_IF",
+	OP_NOTIF:               "OP_NOTIF",
+	OP_ELSE:                "OP_ELSE",
+	OP_ENDIF:               "OP_ENDIF",
+	OP_VERIFY:              "OP_VERIFY",
+	OP_RETURN:              "OP_RETURN",
+	OP_DROP:                "OP_DROP",
+	OP_DUP:                 "OP_DUP",
+	OP_SWAP:                "OP_SWAP",
+	OP_SIZE:                "OP_SIZE",
+	OP_EQUAL:               "OP_EQUAL",
+	OP_EQUALVERIFY:         "OP_EQUALVERIFY",
+	OP_SHA256:              "OP_SHA256",
+	OP_HASH160:             "OP_HASH160",
+	OP_CHECKSIG:            "OP_CHECKSIG",
+	OP_CHECKSIGVERIFY:      "OP_CHECKSIGVERIFY",
+	OP_CHECKMULTISIG:       "OP_CHECKMULTISIG",
+	OP_CHECKMULTISIGVERIFY: "OP_CHECKMULTISIGVERIFY",
+	OP_CHECKLOCKTIMEVERIFY: "OP_CHECKLOCKTIMEVERIFY",
+	OP_CHECKSEQUENCEVERIFY: "OP_CHECKSEQUENCEVERIFY",
+}
+
+// ErrMalformedPush is returned when a push opcode runs past the end of the script
+var ErrMalformedPush = errors.New("malformed push in script")
+
+const sha256Size = 32
+
+// 数据输出（OP_RETURN）可携带的最大字节数
+const maxDataCarrierSize = 80
+
+// 解析后的一条脚本指令：操作码，以及数据压入类操作码所携带的数据
+type parsedOp struct {
+	opcode byte
+	data   []byte
+}
+
+// 是否为数据压入类操作码（包括OP_0、OP_1NEGATE、OP_1-OP_16）
+func (op parsedOp) isPush() bool {
+	return op.opcode <= OP_16 && op.opcode != 0x50
+}
+
+// 解析脚本为指令序列
+func parseScript(script []byte) ([]parsedOp, error) {
+	var ops []parsedOp
+
+	for i := 0; i < len(script); {
+		opcode := script[i]
+		i++
+
+		var size int
+		switch {
+		case opcode >= OP_DATA_1 && opcode <= OP_DATA_75:
+			size = int(opcode)
+		case opcode == OP_PUSHDATA1:
+			if i+1 > len(script) {
+				return nil, ErrMalformedPush
+			}
+			size = int(script[i])
+			i++
+		case opcode == OP_PUSHDATA2:
+			if i+2 > len(script) {
+				return nil, ErrMalformedPush
+			}
+			size = int(binary.LittleEndian.Uint16(script[i : i+2]))
+			i += 2
+		default:
+			ops = append(ops, parsedOp{opcode, nil})
+			continue
+		}
+
+		if i+size > len(script) {
+			return nil, ErrMalformedPush
+		}
+		ops = append(ops, parsedOp{opcode, script[i : i+size]})
+		i += size
+	}
+
+	return ops, nil
+}
+
+// IsPushOnly checks whether the script consists of data pushes only
+func IsPushOnly(script []byte) bool {
+	ops, err := parseScript(script)
+	if err != nil {
+		return false
+	}
+
+	for _, op := range ops {
+		if !op.isPush() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ScriptBuilder assembles a script from opcodes and data pushes
+type ScriptBuilder struct {
+	script []byte
+}
+
+// NewScriptBuilder returns an empty ScriptBuilder
+func NewScriptBuilder() *ScriptBuilder {
+	return &ScriptBuilder{}
+}
+
+// AddOp appends a single opcode
+func (b *ScriptBuilder) AddOp(opcode byte) *ScriptBuilder {
+	b.script = append(b.script, opcode)
+
+	return b
+}
+
+// AddData appends a push of data using the smallest push opcode
+func (b *ScriptBuilder) AddData(data []byte) *ScriptBuilder {
+	size := len(data)
+
+	switch {
+	case size <= OP_DATA_75:
+		b.script = append(b.script, byte(size))
+	case size <= 0xff:
+		b.script = append(b.script, OP_PUSHDATA1, byte(size))
+	default:
+		var buf [2]byte
+		binary.LittleEndian.PutUint16(buf[:], uint16(size))
+		b.script = append(b.script, OP_PUSHDATA2)
+		b.script = append(b.script, buf[:]...)
+	}
+	b.script = append(b.script, data...)
+
+	return b
+}
+
+// AddInt64 appends a push of a number, using OP_1-OP_16 where possible
+func (b *ScriptBuilder) AddInt64(n int64) *ScriptBuilder {
+	switch {
+	case n == 0:
+		return b.AddOp(OP_0)
+	case n == -1:
+		return b.AddOp(OP_1NEGATE)
+	case n >= 1 && n <= 16:
+		return b.AddOp(byte(OP_1 - 1 + n))
+	}
+
+	return b.AddData(scriptNum(n).Bytes())
+}
+
+// Script returns the assembled script
+func (b *ScriptBuilder) Script() []byte {
+	return b.script
+}
+
+// 脚本中的数字：小端序，最高字节的最高位为符号位
+type scriptNum int64
+
+// Bytes returns the minimal encoding of the number
+func (n scriptNum) Bytes() []byte {
+	if n == 0 {
+		return nil
+	}
+
+	negative := n < 0
+	abs := int64(n)
+	if negative {
+		abs = -abs
+	}
+
+	var result []byte
+	for abs > 0 {
+		result = append(result, byte(abs&0xff))
+		abs >>= 8
+	}
+
+	if result[len(result)-1]&0x80 != 0 {
+		extra := byte(0x00)
+		if negative {
+			extra = 0x80
+		}
+		result = append(result, extra)
+	} else if negative {
+		result[len(result)-1] |= 0x80
+	}
+
+	return result
+}
+
+// 解析脚本数字，maxLen限制字节数（普通运算4字节，时间锁5字节）
+func makeScriptNum(v []byte, maxLen int) (scriptNum, error) {
+	if len(v) > maxLen {
+		return 0, fmt.Errorf("script number overflow: %d > %d bytes", len(v), maxLen)
+	}
+	if len(v) == 0 {
+		return 0, nil
+	}
+
+	var result int64
+	for i, b := range v {
+		result |= int64(b) << uint(8*i)
+	}
+
+	if v[len(v)-1]&0x80 != 0 {
+		result &= ^(int64(0x80) << uint(8*(len(v)-1)))
+		return scriptNum(-result), nil
+	}
+
+	return scriptNum(result), nil
+}
+
+// 锁定脚本：P2PKH，向公钥哈希支付
+// OP_DUP OP_HASH160 <pubKeyHash> OP_EQUALVERIFY OP_CHECKSIG
+func NewP2PKHScript(pubKeyHash []byte) []byte {
+	return NewScriptBuilder().
+		AddOp(OP_DUP).
+		AddOp(OP_HASH160).
+		AddData(pubKeyHash).
+		AddOp(OP_EQUALVERIFY).
+		AddOp(OP_CHECKSIG).
+		Script()
+}
+
+// 锁定脚本：哈希锁，提供原像和签名后方可花费
+// OP_SHA256 <hash> OP_EQUALVERIFY OP_DUP OP_HASH160 <pubKeyHash> OP_EQUALVERIFY OP_CHECKSIG
+func NewHashLockScript(hash, pubKeyHash []byte) []byte {
+	return NewScriptBuilder().
+		AddOp(OP_SHA256).
+		AddData(hash).
+		AddOp(OP_EQUALVERIFY).
+		AddOp(OP_DUP).
+		AddOp(OP_HASH160).
+		AddData(pubKeyHash).
+		AddOp(OP_EQUALVERIFY).
+		AddOp(OP_CHECKSIG).
+		Script()
+}
+
+// 锁定脚本：时间锁，到达指定高度/时间后方可花费
+// <lockTime> OP_CHECKLOCKTIMEVERIFY OP_DROP OP_DUP OP_HASH160 <pubKeyHash> OP_EQUALVERIFY OP_CHECKSIG
+func NewTimeLockScript(lockTime int64, pubKeyHash []byte) []byte {
+	return NewScriptBuilder().
+		AddInt64(lockTime).
+		AddOp(OP_CHECKLOCKTIMEVERIFY).
+		AddOp(OP_DROP).
+		AddOp(OP_DUP).
+		AddOp(OP_HASH160).
+		AddData(pubKeyHash).
+		AddOp(OP_EQUALVERIFY).
+		AddOp(OP_CHECKSIG).
+		Script()
+}
+
+// 锁定脚本：M-of-N多签，需按公钥顺序提供至少M个签名
+// <M> <pubKey1> ... <pubKeyN> <N> OP_CHECKMULTISIG
+func NewMultiSigScript(m int, pubKeys [][]byte) []byte {
+	builder := NewScriptBuilder().AddInt64(int64(m))
+	for _, pubKey := range pubKeys {
+		builder.AddData(pubKey)
+	}
+
+	return builder.AddInt64(int64(len(pubKeys))).AddOp(OP_CHECKMULTISIG).Script()
+}
+
+// 锁定脚本：P2SH，向赎回脚本的哈希支付，花费时需提供赎回脚本并满足它
+// OP_HASH160 <scriptHash> OP_EQUAL
+func NewP2SHScript(scriptHash []byte) []byte {
+	return NewScriptBuilder().
+		AddOp(OP_HASH160).
+		AddData(scriptHash).
+		AddOp(OP_EQUAL).
+		Script()
+}
+
+// 锁定脚本：相对时间锁，被花费输出确认足够久后方可花费，sequence按BIP68编码
+// <sequence> OP_CHECKSEQUENCEVERIFY OP_DROP OP_DUP OP_HASH160 <pubKeyHash> OP_EQUALVERIFY OP_CHECKSIG
+func NewRelativeLockScript(sequence int64, pubKeyHash []byte) []byte {
+	return NewScriptBuilder().
+		AddInt64(sequence).
+		AddOp(OP_CHECKSEQUENCEVERIFY).
+		AddOp(OP_DROP).
+		AddOp(OP_DUP).
+		AddOp(OP_HASH160).
+		AddData(pubKeyHash).
+		AddOp(OP_EQUALVERIFY).
+		AddOp(OP_CHECKSIG).
+		Script()
+}
+
+// 锁定脚本：数据输出，OP_RETURN使脚本必然执行失败，该输出不可花费
+// OP_RETURN <data>
+func NewNullDataScript(data []byte) []byte {
+	return NewScriptBuilder().AddOp(OP_RETURN).AddData(data).Script()
+}
+
+// 解锁脚本：P2PKH，<signature> <pubKey>
+func NewP2PKHScriptSig(signature, pubKey []byte) []byte {
+	return NewScriptBuilder().AddData(signature).AddData(pubKey).Script()
+}
+
+// 从P2PKH锁定脚本中解析出公钥哈希，其他类型的脚本返回nil
+func ExtractPubKeyHash(script []byte) []byte {
+	if len(script) == 25 &&
+		script[0] == OP_DUP &&
+		script[1] == OP_HASH160 &&
+		script[2] == 20 &&
+		script[23] == OP_EQUALVERIFY &&
+		script[24] == OP_CHECKSIG {
+		return script[3:23]
+	}
+
+	return nil
+}
+
+// 从P2SH锁定脚本中解析出赎回脚本哈希，其他类型的脚本返回nil
+func ExtractScriptHash(script []byte) []byte {
+	if len(script) == 23 &&
+		script[0] == OP_HASH160 &&
+		script[1] == 20 &&
+		script[22] == OP_EQUAL {
+		return script[2:22]
+	}
+
+	return nil
+}
+
+// 从多签脚本中解析出M和公钥列表
+func ExtractMultiSig(script []byte) (int, [][]byte, bool) {
+	ops, err := parseScript(script)
+	if err != nil || len(ops) < 4 || ops[len(ops)-1].opcode != OP_CHECKMULTISIG {
+		return 0, nil, false
+	}
+
+	m, n := smallInt(ops[0].opcode), smallInt(ops[len(ops)-2].opcode)
+	if m < 1 || n < m || n != len(ops)-3 {
+		return 0, nil, false
+	}
+
+	var pubKeys [][]byte
+	for _, op := range ops[1 : len(ops)-2] {
+		if op.opcode < OP_DATA_1 || op.opcode > OP_PUSHDATA2 {
+			return 0, nil, false
+		}
+		pubKeys = append(pubKeys, op.data)
+	}
+
+	return m, pubKeys, true
+}
+
+// 脚本末尾的P2PKH部分：OP_DUP OP_HASH160 <pubKeyHash> OP_EQUALVERIFY OP_CHECKSIG，返回公钥哈希
+func extractP2PKHTail(ops []parsedOp) []byte {
+	if len(ops) != 5 ||
+		ops[0].opcode != OP_DUP ||
+		ops[1].opcode != OP_HASH160 ||
+		len(ops[2].data) != 20 ||
+		ops[3].opcode != OP_EQUALVERIFY ||
+		ops[4].opcode != OP_CHECKSIG {
+		return nil
+	}
+
+	return ops[2].data
+}
+
+// 从哈希锁脚本中解析出哈希和公钥哈希
+func ExtractHashLock(script []byte) ([]byte, []byte, bool) {
+	ops, err := parseScript(script)
+	if err != nil || len(ops) != 8 ||
+		ops[0].opcode != OP_SHA256 ||
+		len(ops[1].data) != sha256Size ||
+		ops[2].opcode != OP_EQUALVERIFY {
+		return nil, nil, false
+	}
+
+	pubKeyHash := extractP2PKHTail(ops[3:])
+	if pubKeyHash == nil {
+		return nil, nil, false
+	}
+
+	return ops[1].data, pubKeyHash, true
+}
+
+// 从时间锁脚本中解析出锁定时间和公钥哈希
+func ExtractTimeLock(script []byte) (int64, []byte, bool) {
+	return extractLock(script, OP_CHECKLOCKTIMEVERIFY)
+}
+
+// 从相对时间锁脚本中解析出序列号和公钥哈希
+func ExtractRelativeLock(script []byte) (int64, []byte, bool) {
+	return extractLock(script, OP_CHECKSEQUENCEVERIFY)
+}
+
+// 解析 <n> lockOpcode OP_DROP + P2PKH 形式的脚本
+func extractLock(script []byte, lockOpcode byte) (int64, []byte, bool) {
+	ops, err := parseScript(script)
+	if err != nil || len(ops) != 8 ||
+		!ops[0].isPush() ||
+		ops[1].opcode != lockOpcode ||
+		ops[2].opcode != OP_DROP {
+		return 0, nil, false
+	}
+
+	var lockTime scriptNum
+	if n := smallInt(ops[0].opcode); n >= 0 {
+		lockTime = scriptNum(n)
+	} else if lockTime, err = makeScriptNum(ops[0].data, 5); err != nil {
+		return 0, nil, false
+	}
+
+	pubKeyHash := extractP2PKHTail(ops[3:])
+	if pubKeyHash == nil {
+		return 0, nil, false
+	}
+
+	return int64(lockTime), pubKeyHash, true
+}
+
+// 脚本类型
+const (
+	ScriptPubKeyHash  = "pubkeyhash"
+	ScriptScriptHash  = "scripthash"
+	ScriptMultiSig    = "multisig"
+	ScriptHashLock    = "hashlock"
+	ScriptTimeLock    = "timelock"
+	ScriptRelLock     = "relativelock"
+	ScriptNullData    = "nulldata"
+	ScriptNonStandard = "nonstandard"
+)
+
+// 识别脚本类型
+func ScriptClass(script []byte) string {
+	if ExtractPubKeyHash(script) != nil {
+		return ScriptPubKeyHash
+	}
+	if ExtractScriptHash(script) != nil {
+		return ScriptScriptHash
+	}
+	if _, _, ok := ExtractMultiSig(script); ok {
+		return ScriptMultiSig
+	}
+	if _, _, ok := ExtractHashLock(script); ok {
+		return ScriptHashLock
+	}
+	if _, _, ok := ExtractTimeLock(script); ok {
+		return ScriptTimeLock
+	}
+	if _, _, ok := ExtractRelativeLock(script); ok {
+		return ScriptRelLock
+	}
+	if _, ok := ExtractNullData(script); ok {
+		return ScriptNullData
+	}
+
+	return ScriptNonStandard
+}
+
+// 锁定脚本对应的地址：仅P2PKH和P2SH脚本有地址，其他类型返回空串
+func ExtractAddress(script []byte) string {
+	if pubKeyHash := ExtractPubKeyHash(script); pubKeyHash != nil {
+		return string(encodeAddress(version, pubKeyHash))
+	}
+	if scriptHash := ExtractScriptHash(script); scriptHash != nil {
+		return string(encodeAddress(scriptHashVersion, scriptHash))
+	}
+
+	return ""
+}
+
+// 从数据输出脚本中解析出携带的数据
+func ExtractNullData(script []byte) ([]byte, bool) {
+	ops, err := parseScript(script)
+	if err != nil || len(ops) == 0 || len(ops) > 2 || ops[0].opcode != OP_RETURN {
+		return nil, false
+	}
+	if len(ops) == 1 {
+		return []byte{}, true
+	}
+	if !ops[1].isPush() {
+		return nil, false
+	}
+
+	return ops[1].data, true
+}
+
+// 脚本是否可证明不可花费：以OP_RETURN开头或超过脚本大小限制
+func IsUnspendable(script []byte) bool {
+	return (len(script) > 0 && script[0] == OP_RETURN) || len(script) > maxScriptSize
+}
+
+// 锁定脚本对应的地址哈希：P2PKH为公钥哈希，P2SH为赎回脚本哈希，裸多签为脚本本身的哈希
+// 因此多签地址既能匹配P2SH输出，也能匹配对应的裸多签输出
+func extractLockHash(script []byte) []byte {
+	if pubKeyHash := ExtractPubKeyHash(script); pubKeyHash != nil {
+		return pubKeyHash
+	}
+	if scriptHash := ExtractScriptHash(script); scriptHash != nil {
+		return scriptHash
+	}
+	if _, _, ok := ExtractMultiSig(script); ok {
+		return HashPubKey(script)
+	}
+
+	return nil
+}
+
+// OP_1-OP_16对应的数字，其他操作码返回-1
+func smallInt(opcode byte) int {
+	if opcode >= OP_1 && opcode <= OP_16 {
+		return int(opcode - OP_1 + 1)
+	}
+
+	return -1
+}
+
+// 从P2PKH解锁脚本中解析出公钥
+func extractScriptSigPubKey(scriptSig []byte) []byte {
+	ops, err := parseScript(scriptSig)
+	if err != nil || len(ops) != 2 || !ops[1].isPush() {
+		return nil
+	}
+
+	return ops[1].data
+}
+
+// DisasmScript returns a human-readable form of the script
+func DisasmScript(script []byte) string {
+	ops, err := parseScript(script)
+	if err != nil {
+		return fmt.Sprintf("[error: %s] %x", err, script)
+	}
+
+	var parts []string
+	for _, op := range ops {
+		switch {
+		case op.opcode >= OP_DATA_1 && op.opcode <= OP_PUSHDATA2:
+			parts = append(parts, hex.EncodeToString(op.data))
+		case op.opcode >= OP_1 && op.opcode <= OP_16:
+			parts = append(parts, fmt.Sprintf("OP_%d", op.opcode-OP_1+1))
+		default:
+			name, ok := opcodeNames[op.opcode]
+			if !ok {
+				name = fmt.Sprintf("OP_UNKNOWN_%#x", op.opcode)
+			}
+			parts = append(parts, name)
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
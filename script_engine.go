@@ -0,0 +1,447 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// 脚本执行限制
+const (
+	maxScriptSize         = 10000 //脚本最大字节数
+	maxScriptElementSize  = 520   //单个压入数据的最大字节数
+	maxOpsPerScript       = 201   //非数据压入类操作码的最大数量
+	maxStackSize          = 1000  //栈的最大深度
+	maxPubKeysPerMultiSig = 20    //多签中公钥的最大数量
+	lockTimeThreshold     = 500000000
+)
+
+// 脚本执行错误
+var (
+	ErrScriptTooBig       = errors.New("script is too big")
+	ErrElementTooBig      = errors.New("push exceeds maximum element size")
+	ErrTooManyOps         = errors.New("too many operations in script")
+	ErrStackOverflow      = errors.New("stack size limit exceeded")
+	ErrStackUnderflow     = errors.New("stack underflow")
+	ErrUnbalancedIf       = errors.New("unbalanced conditional")
+	ErrVerifyFailed       = errors.New("verify failed")
+	ErrEarlyReturn        = errors.New("OP_RETURN encountered")
+	ErrEvalFalse          = errors.New("script evaluated to false")
+	ErrScriptSigNotPushes = errors.New("ScriptSig must only push data")
+	ErrBadOpcode          = errors.New("invalid opcode")
+	ErrUnsatisfiedLock    = errors.New("locktime requirement not satisfied")
+	ErrNegativeLockTime   = errors.New("negative locktime")
+	ErrInvalidPubKeyCount = errors.New("invalid pubkey count in multisig")
+	ErrInvalidSigCount    = errors.New("invalid signature count in multisig")
+)
+
+// SigChecker gives the script engine access to the spending transaction
+type SigChecker interface {
+	// CheckSig verifies a signature (with hash type suffix) over the transaction,
+	// using script as the subscript committed to by the signature
+	CheckSig(sig, pubKey, script []byte) bool
+	// CheckLockTime reports whether the transaction satisfies an absolute lock
+	CheckLockTime(lockTime int64) bool
+	// CheckSequence reports whether the input satisfies a relative lock
+	CheckSequence(sequence int64) bool
+}
+
+// 脚本解释器：基于栈执行解锁脚本和锁定脚本
+type Engine struct {
+	checker   SigChecker
+	stack     [][]byte
+	condStack []bool //OP_IF嵌套的执行状态
+	numOps    int
+}
+
+// VerifyScript runs scriptSig followed by scriptPubKey and reports whether
+// the output is unlocked
+func VerifyScript(scriptSig, scriptPubKey []byte, checker SigChecker) error {
+	if !IsPushOnly(scriptSig) {
+		return ErrScriptSigNotPushes
+	}
+
+	vm := &Engine{checker: checker}
+	//先执行解锁脚本，将签名、公钥等数据压入栈
+	if err := vm.Execute(scriptSig); err != nil {
+		return err
+	}
+	//P2SH：保存执行锁定脚本前的栈，栈顶为赎回脚本
+	var savedStack [][]byte
+	isP2SH := ExtractScriptHash(scriptPubKey) != nil
+	if isP2SH {
+		savedStack = append(savedStack, vm.stack...)
+	}
+	//再以同一个栈执行锁定脚本
+	if err := vm.Execute(scriptPubKey); err != nil {
+		return err
+	}
+	if err := vm.checkResult(); err != nil {
+		return err
+	}
+	if !isP2SH {
+		return nil
+	}
+	//锁定脚本校验了赎回脚本的哈希，接着以剩余数据执行赎回脚本
+	vm.stack = savedStack
+	redeemScript, err := vm.pop()
+	if err != nil {
+		return err
+	}
+	if err := vm.Execute(redeemScript); err != nil {
+		return err
+	}
+
+	return vm.checkResult()
+}
+
+// 脚本执行完毕后栈顶须为真
+func (vm *Engine) checkResult() error {
+	if len(vm.stack) == 0 || !asBool(vm.stack[len(vm.stack)-1]) {
+		return ErrEvalFalse
+	}
+
+	return nil
+}
+
+// Execute runs a single script on the engine's current stack
+func (vm *Engine) Execute(script []byte) error {
+	if len(script) > maxScriptSize {
+		return ErrScriptTooBig
+	}
+
+	ops, err := parseScript(script)
+	if err != nil {
+		return err
+	}
+
+	vm.numOps = 0
+	vm.condStack = nil
+
+	for _, op := range ops {
+		if err := vm.step(op, script); err != nil {
+			return err
+		}
+		if len(vm.stack) > maxStackSize {
+			return ErrStackOverflow
+		}
+	}
+
+	if len(vm.condStack) != 0 {
+		return ErrUnbalancedIf
+	}
+
+	return nil
+}
+
+// 当前是否处于执行分支（所有外层OP_IF条件均为真）
+func (vm *Engine) executing() bool {
+	for _, cond := range vm.condStack {
+		if !cond {
+			return false
+		}
+	}
+
+	return true
+}
+
+// 执行一条指令
+func (vm *Engine) step(op parsedOp, script []byte) error {
+	if len(op.data) > maxScriptElementSize {
+		return ErrElementTooBig
+	}
+	if op.opcode > OP_16 {
+		vm.numOps++
+		if vm.numOps > maxOpsPerScript {
+			return ErrTooManyOps
+		}
+	}
+
+	//条件分支指令即使在未执行分支中也要处理，以维护嵌套关系
+	switch op.opcode {
+	case OP_IF, OP_NOTIF:
+		cond := false
+		if vm.executing() {
+			v, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			cond = asBool(v)
+			if op.opcode == OP_NOTIF {
+				cond = !cond
+			}
+		}
+		vm.condStack = append(vm.condStack, cond)
+		return nil
+	case OP_ELSE:
+		if len(vm.condStack) == 0 {
+			return ErrUnbalancedIf
+		}
+		vm.condStack[len(vm.condStack)-1] = !vm.condStack[len(vm.condStack)-1]
+		return nil
+	case OP_ENDIF:
+		if len(vm.condStack) == 0 {
+			return ErrUnbalancedIf
+		}
+		vm.condStack = vm.condStack[:len(vm.condStack)-1]
+		return nil
+	}
+
+	if !vm.executing() {
+		return nil
+	}
+
+	switch {
+	case op.opcode == OP_0:
+		vm.push(nil)
+	case op.opcode >= OP_DATA_1 && op.opcode <= OP_PUSHDATA2:
+		vm.push(op.data)
+	case op.opcode == OP_1NEGATE:
+		vm.push(scriptNum(-1).Bytes())
+	case op.opcode >= OP_1 && op.opcode <= OP_16:
+		vm.push(scriptNum(op.opcode - OP_1 + 1).Bytes())
+	case op.opcode == OP_NOP:
+	case op.opcode == OP_VERIFY:
+		v, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		if !asBool(v) {
+			return ErrVerifyFailed
+		}
+	case op.opcode == OP_RETURN:
+		return ErrEarlyReturn
+	case op.opcode == OP_DROP:
+		if _, err := vm.pop(); err != nil {
+			return err
+		}
+	case op.opcode == OP_DUP:
+		v, err := vm.peek(0)
+		if err != nil {
+			return err
+		}
+		vm.push(v)
+	case op.opcode == OP_SWAP:
+		if len(vm.stack) < 2 {
+			return ErrStackUnderflow
+		}
+		n := len(vm.stack)
+		vm.stack[n-1], vm.stack[n-2] = vm.stack[n-2], vm.stack[n-1]
+	case op.opcode == OP_SIZE:
+		v, err := vm.peek(0)
+		if err != nil {
+			return err
+		}
+		vm.push(scriptNum(len(v)).Bytes())
+	case op.opcode == OP_EQUAL || op.opcode == OP_EQUALVERIFY:
+		a, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		b, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		equal := bytes.Equal(a, b)
+		if op.opcode == OP_EQUALVERIFY {
+			if !equal {
+				return ErrVerifyFailed
+			}
+			return nil
+		}
+		vm.push(fromBool(equal))
+	case op.opcode == OP_SHA256:
+		v, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		hash := sha256.Sum256(v)
+		vm.push(hash[:])
+	case op.opcode == OP_HASH160:
+		v, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		vm.push(HashPubKey(v))
+	case op.opcode == OP_CHECKSIG || op.opcode == OP_CHECKSIGVERIFY:
+		pubKey, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		sig, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		valid := len(sig) > 0 && vm.checker.CheckSig(sig, pubKey, script)
+		if op.opcode == OP_CHECKSIGVERIFY {
+			if !valid {
+				return ErrVerifyFailed
+			}
+			return nil
+		}
+		vm.push(fromBool(valid))
+	case op.opcode == OP_CHECKMULTISIG || op.opcode == OP_CHECKMULTISIGVERIFY:
+		valid, err := vm.checkMultiSig(script)
+		if err != nil {
+			return err
+		}
+		if op.opcode == OP_CHECKMULTISIGVERIFY {
+			if !valid {
+				return ErrVerifyFailed
+			}
+			return nil
+		}
+		vm.push(fromBool(valid))
+	case op.opcode == OP_CHECKLOCKTIMEVERIFY:
+		//时间锁：栈顶元素不出栈，通常紧跟OP_DROP
+		v, err := vm.peek(0)
+		if err != nil {
+			return err
+		}
+		lockTime, err := makeScriptNum(v, 5)
+		if err != nil {
+			return err
+		}
+		if lockTime < 0 {
+			return ErrNegativeLockTime
+		}
+		if !vm.checker.CheckLockTime(int64(lockTime)) {
+			return ErrUnsatisfiedLock
+		}
+	case op.opcode == OP_CHECKSEQUENCEVERIFY:
+		v, err := vm.peek(0)
+		if err != nil {
+			return err
+		}
+		sequence, err := makeScriptNum(v, 5)
+		if err != nil {
+			return err
+		}
+		if sequence < 0 {
+			return ErrNegativeLockTime
+		}
+		if !vm.checker.CheckSequence(int64(sequence)) {
+			return ErrUnsatisfiedLock
+		}
+	default:
+		return fmt.Errorf("%v: %#x", ErrBadOpcode, op.opcode)
+	}
+
+	return nil
+}
+
+// 多签验证：栈中依次为 <sig1> ... <sigM> <M> <pubKey1> ... <pubKeyN> <N>
+// 签名须与公钥顺序一致
+func (vm *Engine) checkMultiSig(script []byte) (bool, error) {
+	n, err := vm.popInt()
+	if err != nil {
+		return false, err
+	}
+	if n < 0 || n > maxPubKeysPerMultiSig {
+		return false, ErrInvalidPubKeyCount
+	}
+	vm.numOps += n
+	if vm.numOps > maxOpsPerScript {
+		return false, ErrTooManyOps
+	}
+
+	pubKeys := make([][]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		if pubKeys[i], err = vm.pop(); err != nil {
+			return false, err
+		}
+	}
+
+	m, err := vm.popInt()
+	if err != nil {
+		return false, err
+	}
+	if m < 0 || m > n {
+		return false, ErrInvalidSigCount
+	}
+
+	sigs := make([][]byte, m)
+	for i := m - 1; i >= 0; i-- {
+		if sigs[i], err = vm.pop(); err != nil {
+			return false, err
+		}
+	}
+
+	//按顺序匹配签名和公钥，公钥用尽仍有签名未匹配则失败
+	keyIdx := 0
+	for _, sig := range sigs {
+		matched := false
+		for keyIdx < len(pubKeys) {
+			pubKey := pubKeys[keyIdx]
+			keyIdx++
+			if len(sig) > 0 && vm.checker.CheckSig(sig, pubKey, script) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func (vm *Engine) push(v []byte) {
+	vm.stack = append(vm.stack, v)
+}
+
+func (vm *Engine) pop() ([]byte, error) {
+	if len(vm.stack) == 0 {
+		return nil, ErrStackUnderflow
+	}
+	v := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+
+	return v, nil
+}
+
+func (vm *Engine) peek(depth int) ([]byte, error) {
+	if len(vm.stack) <= depth {
+		return nil, ErrStackUnderflow
+	}
+
+	return vm.stack[len(vm.stack)-1-depth], nil
+}
+
+func (vm *Engine) popInt() (int, error) {
+	v, err := vm.pop()
+	if err != nil {
+		return 0, err
+	}
+	n, err := makeScriptNum(v, 4)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
+
+// 栈元素的布尔值：全零（包括负零）为假
+func asBool(v []byte) bool {
+	for i, b := range v {
+		if b != 0 {
+			//负零
+			if i == len(v)-1 && b == 0x80 {
+				return false
+			}
+			return true
+		}
+	}
+
+	return false
+}
+
+func fromBool(v bool) []byte {
+	if v {
+		return []byte{1}
+	}
+
+	return nil
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 构造一笔花费prevTx第0个输出的交易
+func newSpendingTx(prevTx *Transaction, to string) *Transaction {
+	tx := Transaction{nil, []TXInput{{prevTx.ID, 0, nil, SequenceFinal}}, []TXOutput{*NewTXOutput(prevTx.Vout[0].Value, to)}, 0}
+	tx.ID = tx.Hash()
+
+	return &tx
+}
+
+func newPrevTx(scriptPubKey []byte) *Transaction {
+	tx := Transaction{nil, []TXInput{{[]byte{}, -1, []byte("prev"), SequenceFinal}}, []TXOutput{{10, scriptPubKey}}, 0}
+	tx.ID = tx.Hash()
+
+	return &tx
+}
+
+func hexID(tx *Transaction) string {
+	return hex.EncodeToString(tx.ID)
+}
+
+func TestScriptNum(t *testing.T) {
+	for _, n := range []int64{0, 1, -1, 127, 128, -128, 255, 256, 500000000, -32768} {
+		decoded, err := makeScriptNum(scriptNum(n).Bytes(), 5)
+		assert.Nil(t, err)
+		assert.Equal(t, n, int64(decoded))
+	}
+}
+
+func TestP2PKHSignAndVerify(t *testing.T) {
+	wallet := NewWallet()
+	other := NewWallet()
+	prevTx := newPrevTx(NewP2PKHScript(HashPubKey(wallet.PublicKey)))
+	prevTXs := map[string]Transaction{hexID(prevTx): *prevTx}
+
+	tx := newSpendingTx(prevTx, string(other.GetAddress()))
+	id := tx.ID
+	tx.Sign(wallet.PrivateKey, prevTXs)
+	assert.True(t, tx.Verify(prevTXs), "signed input unlocks the output")
+	assert.Equal(t, id, tx.Hash(), "signing does not change the transaction id")
+
+	tx.Vout[0].Value = 9
+	assert.False(t, tx.Verify(prevTXs), "signature commits to the outputs")
+
+	tx.Vout[0].Value = 11
+	tx.Sign(wallet.PrivateKey, prevTXs)
+	assert.False(t, tx.Verify(prevTXs), "outputs cannot exceed inputs")
+
+	tx.Vout[0].Value = 10
+	tx.Sign(other.PrivateKey, prevTXs)
+	assert.False(t, tx.Verify(prevTXs), "a different key cannot unlock the output")
+}
+
+func TestDuplicateInputs(t *testing.T) {
+	wallet := NewWallet()
+	prevTx := newPrevTx(NewP2PKHScript(HashPubKey(wallet.PublicKey)))
+	prevTXs := map[string]Transaction{hexID(prevTx): *prevTx}
+
+	//同一个输出花费两次，输出金额为其两倍
+	tx := newSpendingTx(prevTx, string(wallet.GetAddress()))
+	tx.Vin = append(tx.Vin, tx.Vin[0])
+	tx.Vout[0].Value = 20
+	tx.ID = tx.Hash()
+	tx.Sign(wallet.PrivateKey, prevTXs)
+	assert.Equal(t, ErrDuplicateInput, tx.CheckDuplicateInputs())
+	assert.False(t, tx.Verify(prevTXs), "the output value is counted once")
+}
+
+func TestHashLockScript(t *testing.T) {
+	wallet := NewWallet()
+	preimage := []byte("secret")
+	hash := sha256.Sum256(preimage)
+	prevTx := newPrevTx(NewHashLockScript(hash[:], HashPubKey(wallet.PublicKey)))
+	prevTXs := map[string]Transaction{hexID(prevTx): *prevTx}
+	tx := newSpendingTx(prevTx, string(wallet.GetAddress()))
+
+	script := prevTx.Vout[0].ScriptPubKey
+	sig := append(signHash(wallet.PrivateKey, tx.SignatureHash(0, script, SigHashAll)), SigHashAll)
+
+	tx.Vin[0].ScriptSig = NewScriptBuilder().AddData(sig).AddData(wallet.PublicKey).AddData(preimage).Script()
+	assert.True(t, tx.Verify(prevTXs))
+
+	tx.Vin[0].ScriptSig = NewScriptBuilder().AddData(sig).AddData(wallet.PublicKey).AddData([]byte("wrong")).Script()
+	assert.False(t, tx.Verify(prevTXs))
+}
+
+func TestCheckMultiSig(t *testing.T) {
+	keys := []*Wallet{NewWallet(), NewWallet(), NewWallet()}
+	builder := NewScriptBuilder().AddInt64(2)
+	for _, key := range keys {
+		builder.AddData(key.PublicKey)
+	}
+	script := builder.AddInt64(3).AddOp(OP_CHECKMULTISIG).Script()
+
+	prevTx := newPrevTx(script)
+	prevTXs := map[string]Transaction{hexID(prevTx): *prevTx}
+	tx := newSpendingTx(prevTx, string(keys[0].GetAddress()))
+	hash := tx.SignatureHash(0, script, SigHashAll)
+	sig0 := append(signHash(keys[0].PrivateKey, hash), SigHashAll)
+	sig2 := append(signHash(keys[2].PrivateKey, hash), SigHashAll)
+
+	tx.Vin[0].ScriptSig = NewScriptBuilder().AddData(sig0).AddData(sig2).Script()
+	assert.True(t, tx.Verify(prevTXs), "2 of 3 signatures in key order")
+
+	tx.Vin[0].ScriptSig = NewScriptBuilder().AddData(sig2).AddData(sig0).Script()
+	assert.False(t, tx.Verify(prevTXs), "signatures out of key order")
+
+	tx.Vin[0].ScriptSig = NewScriptBuilder().AddData(sig0).Script()
+	assert.False(t, tx.Verify(prevTXs), "below threshold")
+}
+
+func TestCheckLockTimeVerify(t *testing.T) {
+	wallet := NewWallet()
+	script := NewTimeLockScript(100, HashPubKey(wallet.PublicKey))
+	prevTx := newPrevTx(script)
+	prevTXs := map[string]Transaction{hexID(prevTx): *prevTx}
+
+	sign := func(tx *Transaction) {
+		hash := tx.SignatureHash(0, script, SigHashAll)
+		sig := append(signHash(wallet.PrivateKey, hash), SigHashAll)
+		tx.Vin[0].ScriptSig = NewP2PKHScriptSig(sig, wallet.PublicKey)
+	}
+
+	tx := newSpendingTx(prevTx, string(wallet.GetAddress()))
+	tx.Vin[0].Sequence = 0
+	tx.LockTime = 99
+	sign(tx)
+	assert.False(t, tx.Verify(prevTXs), "lock time not reached")
+
+	tx.LockTime = 100
+	sign(tx)
+	assert.True(t, tx.Verify(prevTXs))
+
+	tx.Vin[0].Sequence = SequenceFinal
+	sign(tx)
+	assert.False(t, tx.Verify(prevTXs), "final inputs disable lock time")
+}
+
+func TestExecutionLimits(t *testing.T) {
+	checker := txSigChecker{}
+
+	big := make([]byte, maxScriptElementSize+1)
+	err := VerifyScript(NewScriptBuilder().AddData(big).Script(), []byte{OP_DROP, OP_TRUE}, checker)
+	assert.Equal(t, ErrElementTooBig, err)
+
+	ops := NewScriptBuilder().AddOp(OP_TRUE)
+	for i := 0; i <= maxOpsPerScript; i++ {
+		ops.AddOp(OP_NOP)
+	}
+	err = VerifyScript(nil, ops.Script(), checker)
+	assert.Equal(t, ErrTooManyOps, err)
+
+	err = VerifyScript(nil, []byte{OP_TRUE, OP_IF, OP_TRUE}, checker)
+	assert.Equal(t, ErrUnbalancedIf, err)
+
+	err = VerifyScript([]byte{OP_DUP}, []byte{OP_TRUE}, checker)
+	assert.Equal(t, ErrScriptSigNotPushes, err)
+
+	err = VerifyScript(nil, []byte{OP_RETURN}, checker)
+	assert.Equal(t, ErrEarlyReturn, err)
+}
+
+func TestMultiSigPartialTransaction(t *testing.T) {
+	keys := []*Wallet{NewWallet(), NewWallet(), NewWallet()}
+	redeemScript := NewMultiSigScript(2, [][]byte{keys[0].PublicKey, keys[1].PublicKey, keys[2].PublicKey})
+	address := scriptAddress(redeemScript)
+	assert.True(t, ValidateAddress(address))
+
+	for _, bare := range []bool{false, true} {
+		out := NewTXOutput(10, address)
+		if bare {
+			out = &TXOutput{10, redeemScript}
+		}
+		assert.True(t, out.IsLockedWithKey(HashPubKey(redeemScript)))
+
+		prevTx := newPrevTx(out.ScriptPubKey)
+		prevTXs := map[string]Transaction{hexID(prevTx): *prevTx}
+		tx := newSpendingTx(prevTx, string(keys[0].GetAddress()))
+		in := NewPartialInput(prevTx, 0, redeemScript)
+		if bare {
+			in.RedeemScript = nil
+		}
+		ptx := PartialTransaction{*tx, []PartialInput{in}}
+
+		assert.Equal(t, 1, ptx.Sign(keys[2].PrivateKey))
+		assert.False(t, ptx.IsComplete())
+		_, err := ptx.Finalize()
+		assert.NotNil(t, err, "below threshold")
+
+		assert.Equal(t, 1, ptx.Sign(keys[0].PrivateKey))
+		assert.True(t, ptx.IsComplete())
+		final, err := ptx.Finalize()
+		assert.Nil(t, err)
+		assert.True(t, final.Verify(prevTXs))
+		assert.Equal(t, tx.ID, final.Hash())
+	}
+}
+
+func TestScriptHashAddress(t *testing.T) {
+	wallet := NewWallet()
+	preimage := []byte("secret")
+	hash := sha256.Sum256(preimage)
+	redeemScript := NewHashLockScript(hash[:], HashPubKey(wallet.PublicKey))
+	assert.Equal(t, ScriptHashLock, ScriptClass(redeemScript))
+
+	address := scriptAddress(redeemScript)
+	assert.True(t, ValidateAddress(address))
+	assert.Equal(t, byte('3'), address[0])
+
+	out := NewTXOutput(10, address)
+	assert.Equal(t, ScriptScriptHash, ScriptClass(out.ScriptPubKey))
+	assert.Equal(t, HashPubKey(redeemScript), ExtractScriptHash(out.ScriptPubKey))
+
+	prevTx := newPrevTx(out.ScriptPubKey)
+	prevTXs := map[string]Transaction{hexID(prevTx): *prevTx}
+	tx := newSpendingTx(prevTx, string(wallet.GetAddress()))
+	sig := append(signHash(wallet.PrivateKey, tx.SignatureHash(0, redeemScript, SigHashAll)), SigHashAll)
+
+	tx.Vin[0].ScriptSig = NewScriptBuilder().AddData(sig).AddData(wallet.PublicKey).AddData(preimage).AddData(redeemScript).Script()
+	assert.True(t, tx.Verify(prevTXs))
+
+	other := NewHashLockScript(hash[:], HashPubKey(NewWallet().PublicKey))
+	tx.Vin[0].ScriptSig = NewScriptBuilder().AddData(sig).AddData(wallet.PublicKey).AddData(preimage).AddData(other).Script()
+	assert.False(t, tx.Verify(prevTXs), "redeem script must match the committed hash")
+}
+
+func TestNullDataOutputs(t *testing.T) {
+	hash := sha256.Sum256([]byte("document"))
+	out := TXOutput{0, NewNullDataScript(hash[:])}
+	assert.Equal(t, ScriptNullData, ScriptClass(out.ScriptPubKey))
+	assert.False(t, out.IsSpendable())
+
+	data, ok := ExtractNullData(out.ScriptPubKey)
+	assert.True(t, ok)
+	assert.Equal(t, hash[:], data)
+
+	tx := newPrevTx(out.ScriptPubKey)
+	assert.Nil(t, tx.CheckDataOutputs())
+
+	tx.Vout[0].ScriptPubKey = NewNullDataScript(make([]byte, maxDataCarrierSize+1))
+	assert.NotNil(t, tx.CheckDataOutputs(), "data exceeds the size limit")
+
+	tx.Vout[0].ScriptPubKey = []byte{OP_RETURN, OP_DUP}
+	assert.NotNil(t, tx.CheckDataOutputs(), "data output must be push only")
+}
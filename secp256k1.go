@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"log"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v3"
+	k1ecdsa "github.com/decred/dcrd/dcrec/secp256k1/v3/ecdsa"
+)
+
+// secp256k1曲线（比特币使用的曲线）：y² = x³ + 7
+// 标准库只支持NIST曲线，密钥生成、签名和公钥压缩都交给dcrd的secp256k1实现，
+// 签名的私钥运算为常量时间，随机数按RFC 6979确定性生成
+// 钱包中的私钥仍以ecdsa.PrivateKey保存，曲线为Secp256k1()
+
+// Secp256k1 returns the secp256k1 curve
+func Secp256k1() elliptic.Curve {
+	return secp256k1.S256()
+}
+
+// 生成secp256k1私钥
+func newSecp256k1Key() ecdsa.PrivateKey {
+	key, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return *key.ToECDSA()
+}
+
+// 由32字节私钥恢复secp256k1私钥
+func secp256k1PrivateKey(d []byte) ecdsa.PrivateKey {
+	return *secp256k1.PrivKeyFromBytes(d).ToECDSA()
+}
+
+// 签名为r和s各32字节拼接，s取较小值（与比特币相同）
+func secp256k1Sign(privKey ecdsa.PrivateKey, hash []byte) []byte {
+	key := secp256k1.PrivKeyFromBytes(paddedBytes(privKey.D, 32))
+	defer key.Zero()
+
+	//紧凑签名为 恢复标志 + r + s
+	compact := k1ecdsa.SignCompact(key, hash, true)
+
+	return compact[1:]
+}
+
+// 验证签名，公钥为33字节压缩格式
+func secp256k1Verify(pubKey, signature, hash []byte) bool {
+	key, err := secp256k1.ParsePubKey(pubKey)
+	if err != nil {
+		return false
+	}
+	var r, s secp256k1.ModNScalar
+	if r.SetByteSlice(signature[:32]) || s.SetByteSlice(signature[32:]) || r.IsZero() || s.IsZero() {
+		return false
+	}
+
+	return k1ecdsa.NewSignature(&r, &s).Verify(hash, key)
+}
+
+// 压缩公钥：前缀0x02（y为偶数）或0x03（y为奇数）加32字节x坐标
+func secp256k1MarshalCompressed(pubKey ecdsa.PublicKey) []byte {
+	var x, y secp256k1.FieldVal
+	x.SetByteSlice(paddedBytes(pubKey.X, 32))
+	y.SetByteSlice(paddedBytes(pubKey.Y, 32))
+
+	return secp256k1.NewPublicKey(&x, &y).SerializeCompressed()
+}
+
+// 解压公钥，格式错误或不在曲线上时返回false
+func secp256k1UnmarshalCompressed(data []byte) (ecdsa.PublicKey, bool) {
+	if len(data) != secp256k1PubKeyLen {
+		return ecdsa.PublicKey{}, false
+	}
+	key, err := secp256k1.ParsePubKey(data)
+	if err != nil {
+		return ecdsa.PublicKey{}, false
+	}
+
+	return *key.ToECDSA(), true
+}
@@ -3,12 +3,12 @@ package main
 import (
 	"bytes"
 	"encoding/gob"
-	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"net"
+	"time"
 )
 
 const protocol = "tcp"
@@ -19,7 +19,7 @@ var nodeAddress string
 var miningAddress string
 var knownNodes = []string{"localhost:3000"}
 var blocksInTransit = [][]byte{}
-var mempool = make(map[string]Transaction)
+var mempool = NewMempool()
 
 type addr struct {
 	AddrList []string //地址列表
@@ -228,18 +228,26 @@ func handleBlock(request []byte, bc *Blockchain) {
 	block := DeserializeBlock(blockData)
 	//接收新的区块
 	fmt.Println("Recevied a new block!")
-	bc.AddBlock(block)
+	connected, disconnected := bc.AddBlock(block)
 
 	fmt.Printf("Added block %x\n", block.Hash)
+	//区块连接到主链后，从前往后移除交易池中已打包的交易及与之冲突的交易
+	for i := len(connected) - 1; i >= 0; i-- {
+		mempool.RemoveForBlock(connected[i])
+	}
+	//分叉切换时，旧主链上被断开的区块中的交易从前往后放回交易池
+	for i := len(disconnected) - 1; i >= 0; i-- {
+		mempool.RestoreForBlock(bc, disconnected[i])
+	}
+	if len(connected) > 0 {
+		mempool.Save(bc)
+	}
 	//若存在缺失的区块，则发送getdata，获取指定的区块
 	if len(blocksInTransit) > 0 {
 		blockHash := blocksInTransit[0]
 		sendGetData(payload.AddrFrom, "block", blockHash)
 
 		blocksInTransit = blocksInTransit[1:]
-	} else {
-		UTXOSet := UTXOSet{bc} //重建utxo索引
-		UTXOSet.Reindex()
 	}
 }
 
@@ -275,7 +283,7 @@ func handleInv(request []byte, bc *Blockchain) {
 	if payload.Type == "tx" {
 		txID := payload.Items[0]
 		//判断本地交易池汇总是否存在请求的交易信息，不存在，则向对端节点发送getdata请求，获取最新交易
-		if mempool[hex.EncodeToString(txID)].ID == nil {
+		if !mempool.Has(txID) {
 			sendGetData(payload.AddrFrom, "tx", txID)
 		}
 	}
@@ -322,11 +330,12 @@ func handleGetData(request []byte, bc *Blockchain) {
 	//请求类型：tx
 	if payload.Type == "tx" {
 		//解析payload信息，by交易hash，从交易池中获取交易信息
-		txID := hex.EncodeToString(payload.ID)
-		tx := mempool[txID]
+		tx, ok := mempool.Get(payload.ID)
+		if !ok {
+			return
+		}
 		//发送tx请求
 		sendTx(payload.AddrFrom, &tx)
-		// delete(mempool, txID)
 	}
 }
 
@@ -344,7 +353,17 @@ func handleTx(request []byte, bc *Blockchain) {
 	//反序列化交易信息
 	txData := payload.Transaction
 	tx := DeserializeTransaction(txData)
-	mempool[hex.EncodeToString(tx.ID)] = tx
+	//校验交易并加入交易池：非法、未生效的交易被拒绝，与池中交易冲突时按RBF规则替换
+	replaced, err := mempool.Accept(bc, &tx)
+	if err != nil {
+		fmt.Printf("Rejected transaction %x: %s\n", tx.ID, err)
+		return
+	}
+	for _, id := range replaced {
+		fmt.Printf("Transaction %s replaced by %x\n", id, tx.ID)
+	}
+	fmt.Printf("Added transaction %x to the mempool\n", tx.ID)
+	mempool.Save(bc)
 	//判断当前节点是否是新加入的节点
 	//1）向区块链中的其他节点发送Inv命令，获取交易信息
 	if nodeAddress == knownNodes[0] {
@@ -354,36 +373,25 @@ func handleTx(request []byte, bc *Blockchain) {
 			}
 		}
 	} else { //2)当前节点不是新加入的节点，判断交易池大小和挖矿地址
-		if len(mempool) >= 2 && len(miningAddress) > 0 {
+		if mempool.Count() >= 2 && len(miningAddress) > 0 {
 		MineTransactions:
-			var txs []*Transaction
-			//遍历交易池，验证交易，获取当前节点所有交易信息
-			for id := range mempool {
-				tx := mempool[id]
-				if bc.VerifyTransaction(&tx) {
-					txs = append(txs, &tx)
-				}
-			}
+			//按祖先组合的费率从交易池中选择已生效的交易，父交易排在子交易之前
+			txs, fees := mempool.SelectTransactions(bc, bc.GetBestHeight()+1, time.Now().Unix())
 			//所有交易非法，则就绪等待新交易的到来
 			if len(txs) == 0 {
 				fmt.Println("All transactions are invalid! Waiting for new ones...")
 				return
 			}
-			//创建coinbase交易
-			cbTx := NewCoinbaseTX(miningAddress, "")
+			//创建coinbase交易：奖励加上所有交易的手续费
+			cbTx := NewFeeCoinbaseTX(miningAddress, fees)
 			txs = append(txs, cbTx)
-			//挖矿，产生新的区块
+			//挖矿，产生新的区块，utxo集合和数据索引随区块一起更新
 			newBlock := bc.MineBlock(txs)
-			//重建utxo索引
-			UTXOSet := UTXOSet{bc}
-			UTXOSet.Reindex()
 
 			fmt.Println("New block is mined!")
-			//清空交易池中的所有交易信息
-			for _, tx := range txs {
-				txID := hex.EncodeToString(tx.ID)
-				delete(mempool, txID)
-			}
+			//移除交易池中已打包的交易
+			mempool.RemoveForBlock(newBlock)
+			mempool.Save(bc)
 			//向其他节点广播最新区块
 			for _, node := range knownNodes {
 				if node != nodeAddress {
@@ -391,7 +399,7 @@ func handleTx(request []byte, bc *Blockchain) {
 				}
 			}
 			//交易池中存在交易，则不断进行挖矿
-			if len(mempool) > 0 {
+			if mempool.Count() > 0 {
 				goto MineTransactions
 			}
 		}
@@ -459,7 +467,7 @@ func handleConnection(conn net.Conn, bc *Blockchain) {
 }
 
 // 启动一个节点
-func StartServer(nodeID, minerAddress string) {
+func StartServer(nodeID, minerAddress, rpcPort string) {
 	//nodeAddress = fmt.Sprintf("localhost:%s", nodeID)
 	nodeAddress = fmt.Sprintf("localhost:%s", "3000")
 	miningAddress = minerAddress
@@ -470,6 +478,12 @@ func StartServer(nodeID, minerAddress string) {
 	defer ln.Close()
 
 	bc := NewBlockchain(nodeID)
+	//恢复保存的交易池
+	mempool = LoadMempool(bc)
+	//启用JSON-RPC服务
+	if rpcPort != "" {
+		go StartRPCServer(fmt.Sprintf("localhost:%s", rpcPort), nodeID, bc)
+	}
 	//不是第一个节点，发送version交互命令
 	if nodeAddress != knownNodes[0] {
 		sendVersion(knownNodes[0], bc)
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// 签名器：对签名哈希签名，私钥可以不在节点进程中（外部签名程序、硬件安全模块）
+// 签名为r和s各32字节拼接，不含签名哈希类型
+type Signer interface {
+	SignHash(pubKey, hash []byte) ([]byte, error)
+}
+
+// 签名器的错误
+var (
+	ErrUnknownSigningKey = errors.New("signer has no key for the public key")
+	ErrPKCS11Unavailable = errors.New("PKCS#11 signing is not available in this build")
+)
+
+// 本地签名器：用内存中的钱包私钥签名
+type LocalSigner struct {
+	keys map[string]ecdsa.PrivateKey //公钥hex -> 私钥
+}
+
+// 由钱包创建本地签名器，锁定的钱包没有私钥，不能签名
+func NewLocalSigner(wallets ...*Wallet) *LocalSigner {
+	signer := &LocalSigner{make(map[string]ecdsa.PrivateKey)}
+	for _, wallet := range wallets {
+		if !wallet.IsLocked() {
+			signer.keys[hex.EncodeToString(wallet.PublicKey)] = wallet.PrivateKey
+		}
+	}
+
+	return signer
+}
+
+// SignHash signs with the private key of pubKey
+func (s *LocalSigner) SignHash(pubKey, hash []byte) ([]byte, error) {
+	privKey, ok := s.keys[hex.EncodeToString(pubKey)]
+	if !ok {
+		return nil, ErrUnknownSigningKey
+	}
+
+	return signHash(privKey, hash), nil
+}
+
+// 外部签名器：每次签名启动一次外部程序，通过标准输入输出交换JSON
+// 输入 {"pubkey":"<hex>","hash":"<hex>"}，输出 {"signature":"<hex>"} 或 {"error":"..."}
+// 外部程序的标准错误输出给用户，可用于提示确认
+type ExternalSigner struct {
+	Command string
+	Args    []string
+}
+
+// 外部签名程序的请求
+type signRequest struct {
+	PubKey string `json:"pubkey"`
+	Hash   string `json:"hash"`
+}
+
+// 外部签名程序的返回
+type signResponse struct {
+	Signature string `json:"signature,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// SignHash runs the external signer and checks the returned signature
+func (s *ExternalSigner) SignHash(pubKey, hash []byte) ([]byte, error) {
+	request, err := json.Marshal(signRequest{hex.EncodeToString(pubKey), hex.EncodeToString(hash)})
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.Command(s.Command, s.Args...)
+	cmd.Stdin = bytes.NewReader(request)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("external signer: %v", err)
+	}
+
+	var response signResponse
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return nil, fmt.Errorf("external signer: invalid response: %v", err)
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("external signer: %s", response.Error)
+	}
+	signature, err := hex.DecodeString(response.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("external signer: invalid signature: %v", err)
+	}
+	//签名须能用公钥验证，避免外部程序用错私钥
+	if !verifySignature(pubKey, signature, hash) {
+		return nil, errors.New("external signer: signature does not match the public key")
+	}
+
+	return signature, nil
+}
+
+// PKCS#11签名器（占位）：私钥保存在硬件安全模块中，由模块按公钥查找私钥签名
+// 当前版本未链接PKCS#11库，签名总是返回ErrPKCS11Unavailable
+type PKCS11Signer struct {
+	Module string //PKCS#11库的路径
+	Slot   string //令牌所在的槽
+}
+
+// SignHash is not implemented without a PKCS#11 library
+func (s *PKCS11Signer) SignHash(pubKey, hash []byte) ([]byte, error) {
+	return nil, ErrPKCS11Unavailable
+}
+
+// 按描述创建签名器：空字符串或local为钱包私钥（返回nil），
+// external:COMMAND ARGS... 为外部签名程序，pkcs11:MODULE[:SLOT] 为PKCS#11模块
+func ParseSigner(spec string) (Signer, error) {
+	kind, arg := spec, ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		kind, arg = spec[:i], spec[i+1:]
+	}
+
+	switch kind {
+	case "", "local":
+		return nil, nil
+	case "external":
+		fields := strings.Fields(arg)
+		if len(fields) == 0 {
+			return nil, errors.New("external signer needs a command")
+		}
+		return &ExternalSigner{fields[0], fields[1:]}, nil
+	case "pkcs11":
+		if arg == "" {
+			return nil, errors.New("PKCS#11 signer needs a module")
+		}
+		signer := &PKCS11Signer{Module: arg}
+		if i := strings.LastIndex(arg, ":"); i >= 0 {
+			signer.Module, signer.Slot = arg[:i], arg[i+1:]
+		}
+		return signer, nil
+	}
+
+	return nil, fmt.Errorf("unknown signer %q", spec)
+}
+
+// 签名使用的钱包：用钱包私钥签名时须为可花费的地址；
+// 用其他签名器时只需要公钥，可以是锁定的钱包或由扩展公钥派生的只读地址
+func (ws *Wallets) SigningWallet(address string, signer Signer) (Wallet, error) {
+	if signer == nil {
+		if err := ws.CheckSpendable(address); err != nil {
+			return Wallet{}, err
+		}
+		return ws.GetWallet(address), nil
+	}
+	if wallet, ok := ws.Wallets[address]; ok {
+		return Wallet{PublicKey: wallet.PublicKey, Path: wallet.Path}, nil
+	}
+	if watched, ok := ws.WatchOnly[address]; ok && watched.PublicKey != nil {
+		return Wallet{PublicKey: watched.PublicKey, Path: watched.Path}, nil
+	}
+
+	return Wallet{}, errors.New("public key of the address is not in the wallet")
+}
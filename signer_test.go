@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 作为外部签名程序运行：私钥由环境变量传入
+func TestExternalSignerProcess(t *testing.T) {
+	encoded := os.Getenv("SIGNER_TEST_KEY")
+	if encoded == "" {
+		return
+	}
+	privKey, err := DecodePrivateKey(encoded)
+	if err != nil {
+		os.Exit(1)
+	}
+	var request signRequest
+	if err := json.NewDecoder(os.Stdin).Decode(&request); err != nil {
+		os.Exit(1)
+	}
+	hash, _ := hex.DecodeString(request.Hash)
+	json.NewEncoder(os.Stdout).Encode(signResponse{Signature: hex.EncodeToString(signHash(privKey, hash))})
+	os.Exit(0)
+}
+
+func TestSigners(t *testing.T) {
+	wallet := NewWallet()
+	prevTx := newPrevTx(NewP2PKHScript(HashPubKey(wallet.PublicKey)))
+	prevTXs := map[string]Transaction{hexID(prevTx): *prevTx}
+
+	tx := newSpendingTx(prevTx, string(NewWallet().GetAddress()))
+	assert.Nil(t, tx.SignWith(NewLocalSigner(wallet), wallet.PublicKey, prevTXs))
+	assert.True(t, tx.Verify(prevTXs))
+	assert.Equal(t, ErrUnknownSigningKey, tx.SignWith(NewLocalSigner(NewWallet()), wallet.PublicKey, prevTXs))
+
+	os.Setenv("SIGNER_TEST_KEY", EncodePrivateKey(wallet.PrivateKey))
+	defer os.Unsetenv("SIGNER_TEST_KEY")
+	external := &ExternalSigner{os.Args[0], []string{"-test.run=TestExternalSignerProcess"}}
+	tx = newSpendingTx(prevTx, string(NewWallet().GetAddress()))
+	assert.Nil(t, tx.SignWith(external, wallet.PublicKey, prevTXs))
+	assert.True(t, tx.Verify(prevTXs))
+	//外部程序用错私钥时签名被拒绝
+	other := NewWallet()
+	assert.NotNil(t, tx.SignWith(external, other.PublicKey, prevTXs))
+
+	_, err := (&PKCS11Signer{Module: "/usr/lib/softhsm/libsofthsm2.so"}).SignHash(wallet.PublicKey, tx.ID)
+	assert.Equal(t, ErrPKCS11Unavailable, err)
+}
+
+func TestParseSigner(t *testing.T) {
+	signer, err := ParseSigner("")
+	assert.Nil(t, err)
+	assert.Nil(t, signer)
+
+	signer, err = ParseSigner("external:hwi-sign --device 1")
+	assert.Nil(t, err)
+	assert.Equal(t, &ExternalSigner{"hwi-sign", []string{"--device", "1"}}, signer)
+
+	signer, err = ParseSigner("pkcs11:/usr/lib/libsofthsm2.so:0")
+	assert.Nil(t, err)
+	assert.Equal(t, &PKCS11Signer{"/usr/lib/libsofthsm2.so", "0"}, signer)
+
+	for _, spec := range []string{"external:", "pkcs11:", "ledger"} {
+		_, err = ParseSigner(spec)
+		assert.NotNil(t, err, fmt.Sprintf("spec %q", spec))
+	}
+}
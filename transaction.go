@@ -3,25 +3,31 @@ package main
 import (
 	"bytes"
 	"crypto/ecdsa"
-	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
-	"math/big"
 	"strings"
 
 	"encoding/gob"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 )
 
 const subsidy = 10
 
+// 签名哈希类型：签名覆盖交易的所有输入和输出
+const SigHashAll = 0x01
+
+// 同一个输出在交易中被花费了多次
+var ErrDuplicateInput = errors.New("transaction spends the same output more than once")
+
 // 交易
 type Transaction struct {
-	ID   []byte     //交易id
-	Vin  []TXInput  //交易输入：交易可能有一个或多个输入
-	Vout []TXOutput //交易输出：交易可能有一个或多个输出
+	ID       []byte     //交易id
+	Vin      []TXInput  //交易输入：交易可能有一个或多个输入
+	Vout     []TXOutput //交易输出：交易可能有一个或多个输出
+	LockTime int64      //锁定时间：区块高度或时间戳，供OP_CHECKLOCKTIMEVERIFY使用
 }
 
 // IsCoinbase checks whether the transaction is coinbase
@@ -48,6 +54,13 @@ func (tx *Transaction) Hash() []byte {
 
 	txCopy := *tx
 	txCopy.ID = []byte{}
+	//交易id不包含解锁脚本，签名前后id保持不变（coinbase的解锁脚本为任意数据，需保留）
+	if !tx.IsCoinbase() {
+		txCopy.Vin = make([]TXInput, len(tx.Vin))
+		for i, vin := range tx.Vin {
+			txCopy.Vin[i] = TXInput{vin.Txid, vin.Vout, nil, vin.Sequence}
+		}
+	}
 
 	hash = sha256.Sum256(txCopy.Serialize())
 
@@ -56,37 +69,51 @@ func (tx *Transaction) Hash() []byte {
 
 //  交易签名
 func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transaction) {
+	wallet := NewWalletFromPrivateKey(privKey)
+	if err := tx.SignWith(NewLocalSigner(wallet), wallet.PublicKey, prevTXs); err != nil {
+		log.Panic("ERROR: ", err)
+	}
+}
+
+// 由签名器对交易签名：所有输入都花费pubKey的输出，私钥可以不在本进程中
+func (tx *Transaction) SignWith(signer Signer, pubKey []byte, prevTXs map[string]Transaction) error {
 	// coinbase 交易签名为空
 	if tx.IsCoinbase() {
-		return
+		return nil
 	}
 	//遍历交易中的所有inputs，判断引用大家交易是否存在
 	for _, vin := range tx.Vin {
 		if prevTXs[hex.EncodeToString(vin.Txid)].ID == nil {
-			log.Panic("ERROR: Previous transaction is not correct")
+			return errors.New("previous transaction is not correct")
 		}
 	}
 
-	txCopy := tx.TrimmedCopy()
-
-	for inID, vin := range txCopy.Vin {
-		//交易hash
+	for inID, vin := range tx.Vin {
 		prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
-		txCopy.Vin[inID].Signature = nil
-		//上一个交易输出中的公钥hash作为交易input中的公钥
-		txCopy.Vin[inID].PubKey = prevTx.Vout[vin.Vout].PubKeyHash
-
-		dataToSign := fmt.Sprintf("%x\n", txCopy)
-
-		r, s, err := ecdsa.Sign(rand.Reader, &privKey, []byte(dataToSign))
+		//上一个交易输出的锁定脚本参与签名
+		hash := tx.SignatureHash(inID, prevTx.Vout[vin.Vout].ScriptPubKey, SigHashAll)
+		signature, err := signer.SignHash(pubKey, hash)
 		if err != nil {
-			log.Panic(err)
+			return err
 		}
-		signature := append(r.Bytes(), s.Bytes()...)
 
-		tx.Vin[inID].Signature = signature
-		txCopy.Vin[inID].PubKey = nil
+		tx.Vin[inID].ScriptSig = NewP2PKHScriptSig(append(signature, SigHashAll), pubKey)
 	}
+
+	return nil
+}
+
+// 计算第inID个输入的签名哈希：清空所有解锁脚本，以被花费输出的锁定脚本（subscript）替换该输入的解锁脚本
+func (tx *Transaction) SignatureHash(inID int, subscript []byte, hashType byte) []byte {
+	txCopy := tx.TrimmedCopy()
+	txCopy.ID = nil
+	txCopy.Vin[inID].ScriptSig = subscript
+
+	data := append(txCopy.Serialize(), hashType)
+	firstSHA := sha256.Sum256(data)
+	secondSHA := sha256.Sum256(firstSHA[:])
+
+	return secondSHA[:]
 }
 
 // String returns a human-readable representation of a transaction
@@ -100,16 +127,22 @@ func (tx Transaction) String() string {
 		lines = append(lines, fmt.Sprintf("     Input %d:", i))
 		lines = append(lines, fmt.Sprintf("       TXID:      %x", input.Txid))
 		lines = append(lines, fmt.Sprintf("       Out:       %d", input.Vout))
-		lines = append(lines, fmt.Sprintf("       Signature: %x", input.Signature))
-		lines = append(lines, fmt.Sprintf("       PubKey:    %x", input.PubKey))
+		if tx.IsCoinbase() {
+			lines = append(lines, fmt.Sprintf("       Data:      %x", input.ScriptSig))
+		} else {
+			lines = append(lines, fmt.Sprintf("       ScriptSig: %s", DisasmScript(input.ScriptSig)))
+		}
+		lines = append(lines, fmt.Sprintf("       Sequence:  %d", input.Sequence))
 	}
 
 	for i, output := range tx.Vout {
 		lines = append(lines, fmt.Sprintf("     Output %d:", i))
 		lines = append(lines, fmt.Sprintf("       Value:  %d", output.Value))
-		lines = append(lines, fmt.Sprintf("       Script: %x", output.PubKeyHash))
+		lines = append(lines, fmt.Sprintf("       Script: %s", DisasmScript(output.ScriptPubKey)))
 	}
 
+	lines = append(lines, fmt.Sprintf("     LockTime: %d", tx.LockTime))
+
 	return strings.Join(lines, "\n")
 }
 
@@ -119,19 +152,37 @@ func (tx *Transaction) TrimmedCopy() Transaction {
 	var outputs []TXOutput
 
 	for _, vin := range tx.Vin {
-		inputs = append(inputs, TXInput{vin.Txid, vin.Vout, nil, nil})
+		inputs = append(inputs, TXInput{vin.Txid, vin.Vout, nil, vin.Sequence})
 	}
 
 	for _, vout := range tx.Vout {
-		outputs = append(outputs, TXOutput{vout.Value, vout.PubKeyHash})
+		outputs = append(outputs, TXOutput{vout.Value, vout.ScriptPubKey})
 	}
 
-	txCopy := Transaction{tx.ID, inputs, outputs}
+	txCopy := Transaction{tx.ID, inputs, outputs, tx.LockTime}
 
 	return txCopy
 }
 
-// 验证交易中所有input的签名是否合法
+// 校验数据输出：携带的数据不能超过大小限制
+func (tx *Transaction) CheckDataOutputs() error {
+	for outIdx, out := range tx.Vout {
+		if out.IsSpendable() {
+			continue
+		}
+		data, ok := ExtractNullData(out.ScriptPubKey)
+		if !ok {
+			return fmt.Errorf("output %d is not a valid data output", outIdx)
+		}
+		if len(data) > maxDataCarrierSize {
+			return fmt.Errorf("output %d carries %d bytes, limit is %d", outIdx, len(data), maxDataCarrierSize)
+		}
+	}
+
+	return nil
+}
+
+// 验证交易中所有input的解锁脚本是否能解锁所引用的output
 func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
 	//coinbase交易签名为空
 	if tx.IsCoinbase() {
@@ -144,36 +195,122 @@ func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
 		}
 	}
 
-	txCopy := tx.TrimmedCopy()
-	curve := elliptic.P256()
-	//验签
-	for inID, vin := range tx.Vin {
+	var prevOuts []TXOutput
+	for _, vin := range tx.Vin {
 		prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
-		txCopy.Vin[inID].Signature = nil
-		txCopy.Vin[inID].PubKey = prevTx.Vout[vin.Vout].PubKeyHash
+		if vin.Vout < 0 || vin.Vout >= len(prevTx.Vout) {
+			return false
+		}
+		prevOuts = append(prevOuts, prevTx.Vout[vin.Vout])
+	}
 
-		r := big.Int{}
-		s := big.Int{}
-		sigLen := len(vin.Signature)
-		r.SetBytes(vin.Signature[:(sigLen / 2)])
-		s.SetBytes(vin.Signature[(sigLen / 2):])
+	return tx.VerifyPrevOuts(prevOuts) == nil
+}
 
-		x := big.Int{}
-		y := big.Int{}
-		keyLen := len(vin.PubKey)
-		x.SetBytes(vin.PubKey[:(keyLen / 2)])
-		y.SetBytes(vin.PubKey[(keyLen / 2):])
+// 检查交易的输入是否重复引用同一个输出，否则该输出的金额会被计算多次
+func (tx *Transaction) CheckDuplicateInputs() error {
+	spent := make(map[string]bool)
+	for _, vin := range tx.Vin {
+		key := fmt.Sprintf("%x:%d", vin.Txid, vin.Vout)
+		if spent[key] {
+			return ErrDuplicateInput
+		}
+		spent[key] = true
+	}
 
-		dataToVerify := fmt.Sprintf("%x\n", txCopy)
+	return nil
+}
 
-		rawPubKey := ecdsa.PublicKey{Curve: curve, X: &x, Y: &y}
-		if ecdsa.Verify(&rawPubKey, []byte(dataToVerify), &r, &s) == false {
-			return false
+// 用各输入所花费的输出验证交易：输入不能重复，输出总额不能超过输入总额，解锁脚本须能解锁对应的锁定脚本
+func (tx *Transaction) VerifyPrevOuts(prevOuts []TXOutput) error {
+	if len(prevOuts) != len(tx.Vin) {
+		return errors.New("previous outputs do not match the inputs")
+	}
+	if err := tx.CheckDuplicateInputs(); err != nil {
+		return err
+	}
+
+	inputValue, outputValue := 0, 0
+	for _, prevOut := range prevOuts {
+		inputValue += prevOut.Value
+	}
+	for _, vout := range tx.Vout {
+		if vout.Value < 0 {
+			return errors.New("output value is negative")
+		}
+		outputValue += vout.Value
+	}
+	if outputValue > inputValue {
+		return fmt.Errorf("outputs (%d) exceed inputs (%d)", outputValue, inputValue)
+	}
+	//执行脚本：解锁脚本 + 锁定脚本
+	for inID, prevOut := range prevOuts {
+		if err := tx.VerifyInput(inID, prevOut.ScriptPubKey); err != nil {
+			return fmt.Errorf("input %d: %v", inID, err)
 		}
-		txCopy.Vin[inID].PubKey = nil
 	}
 
-	return true
+	return nil
+}
+
+// 验证第inID个输入的解锁脚本能否解锁指定的锁定脚本
+func (tx *Transaction) VerifyInput(inID int, scriptPubKey []byte) error {
+	checker := txSigChecker{tx, inID}
+
+	return VerifyScript(tx.Vin[inID].ScriptSig, scriptPubKey, checker)
+}
+
+// 脚本执行时对交易的签名、时间锁校验
+type txSigChecker struct {
+	tx   *Transaction
+	inID int
+}
+
+// CheckSig verifies a signature over the input being spent
+func (c txSigChecker) CheckSig(sig, pubKey, script []byte) bool {
+	hashType := sig[len(sig)-1]
+	if hashType != SigHashAll {
+		return false
+	}
+	hash := c.tx.SignatureHash(c.inID, script, hashType)
+
+	return verifySignature(pubKey, sig[:len(sig)-1], hash)
+}
+
+// CheckLockTime compares the required lock with the transaction lock time;
+// both must be heights or both timestamps
+func (c txSigChecker) CheckLockTime(lockTime int64) bool {
+	txLockTime := c.tx.LockTime
+	if (txLockTime < lockTimeThreshold) != (lockTime < lockTimeThreshold) {
+		return false
+	}
+	if lockTime > txLockTime {
+		return false
+	}
+	//序列号为默认值的输入不启用时间锁
+	return c.tx.Vin[c.inID].Sequence != SequenceFinal
+}
+
+// CheckSequence compares the required relative lock with the input sequence;
+// both must count blocks or both count time
+func (c txSigChecker) CheckSequence(sequence int64) bool {
+	//脚本中的相对时间锁设置了禁用标志：不做要求
+	if sequence&SequenceLockTimeDisabled != 0 {
+		return true
+	}
+	txSequence := int64(c.tx.Vin[c.inID].Sequence)
+	if txSequence&SequenceLockTimeDisabled != 0 {
+		return false
+	}
+
+	mask := int64(SequenceLockTimeTypeFlag | SequenceLockTimeMask)
+	sequence &= mask
+	txSequence &= mask
+	if (sequence < SequenceLockTimeTypeFlag) != (txSequence < SequenceLockTimeTypeFlag) {
+		return false
+	}
+
+	return sequence <= txSequence
 }
 
 // 创建coinbase交易
@@ -190,30 +327,149 @@ func NewCoinbaseTX(to, data string) *Transaction {
 	}
 	//特殊的交易输入
 	//]byte{}, -1, nil, []byte(data)
-	txin := TXInput{[]byte{}, -1, nil, []byte(data)}
+	txin := TXInput{[]byte{}, -1, []byte(data), SequenceFinal}
 	//交易输出
 	//价值；以地址作为锁定脚本
 	txout := NewTXOutput(subsidy, to)
-	tx := Transaction{nil, []TXInput{txin}, []TXOutput{*txout}}
+	tx := Transaction{nil, []TXInput{txin}, []TXOutput{*txout}, 0}
 	tx.ID = tx.Hash()
 
 	return &tx
 }
 
+// 创建coinbase交易：奖励加上区块中所有交易的手续费
+func NewFeeCoinbaseTX(to string, fees int) *Transaction {
+	tx := NewCoinbaseTX(to, "")
+	tx.Vout[0].Value += fees
+	tx.ID = tx.Hash()
+
+	return tx
+}
+
+// 转账交易的可选参数
+type PaymentOptions struct {
+	LockTime    int64        //锁定时间：区块高度或时间戳，到达之前交易不能被打包
+	Fee         int          //手续费：输入总额 - 输出总额
+	Replaceable bool         //是否允许被手续费更高的交易替换（RBF）
+	Selector    CoinSelector //选币策略，nil为默认策略
+	Change      string       //找零地址，为空时找零给钱包地址
+	Signer      Signer       //签名器，nil时用钱包私钥签名
+}
+
 // 创建转账交易：钱包、转入地址、资产、utxo集合
 func NewUTXOTransaction(wallet *Wallet, to string, amount int, UTXOSet *UTXOSet) *Transaction {
-	var inputs []TXInput
-	var outputs []TXOutput
-	//从钱包的公钥中获取公钥hash
+	return NewPaymentTransaction(wallet, []TXOutput{*NewTXOutput(amount, to)}, PaymentOptions{}, UTXOSet)
+}
+
+// 创建转账交易：钱包、指定的交易输出、可选参数、utxo视图（可包含交易池中未确认的输出）
+func NewPaymentTransaction(wallet *Wallet, outputs []TXOutput, opts PaymentOptions, view UTXOView) *Transaction {
+	//加密的钱包锁定时没有私钥，无法签名；使用其他签名器时不需要私钥
+	if wallet.IsLocked() && opts.Signer == nil {
+		log.Panic("ERROR: ", ErrWalletLocked)
+	}
+
+	selector := opts.Selector
+	if selector == nil {
+		selector = DefaultCoinSelector
+	}
+	//交易至少需要一个输入（如只含数据输出的交易）
+	needed := opts.Fee
+	for _, out := range outputs {
+		needed += out.Value
+	}
+	if needed == 0 {
+		needed = 1
+	}
+	//从钱包公钥hash可花费的输出中选币
 	pubKeyHash := HashPubKey(wallet.PublicKey)
-	//从公钥hash中查查找可花费的output（未花费的utxo）
-	acc, validOutputs := UTXOSet.FindSpendableOutputs(pubKeyHash, amount)
+	selected, err := selector.Select(view.FindUnspentOutputs(pubKeyHash), needed)
+	if err != nil {
+		log.Panic("ERROR: Not enough funds")
+	}
+
+	return NewPaymentTransactionFromInputs(wallet, selected, outputs, opts, view)
+}
+
+// 用指定的未花费输出作为输入创建转账交易（手动选币），扣除手续费后不足部分找零给钱包地址
+func NewPaymentTransactionFromInputs(wallet *Wallet, utxos []UnspentOutput, outputs []TXOutput, opts PaymentOptions, view UTXOView) *Transaction {
+	var inputs []TXInput
+
+	if wallet.IsLocked() && opts.Signer == nil {
+		log.Panic("ERROR: ", ErrWalletLocked)
+	}
+
+	amount := opts.Fee
+	for _, out := range outputs {
+		amount += out.Value
+	}
+	acc := sumUnspentOutputs(utxos)
 	//总资产小于待转账资产
-	if acc < amount {
+	if len(utxos) == 0 || acc < amount {
 		log.Panic("ERROR: Not enough funds")
 	}
 
+	//输入均为默认序列号时锁定时间不生效
+	lockTime := opts.LockTime
+	var sequence uint32 = SequenceFinal
+	if lockTime != 0 {
+		sequence = SequenceFinal - 1
+	}
+	if opts.Replaceable {
+		sequence = SequenceReplaceable
+	}
+
 	// 构建交易中的input
+	for _, utxo := range utxos {
+		inputs = append(inputs, TXInput{utxo.Txid, utxo.Vout, nil, sequence})
+	}
+
+	//找零
+	change := opts.Change
+	if change == "" {
+		change = fmt.Sprintf("%s", wallet.GetAddress())
+	}
+	if acc > amount {
+		outputs = append(outputs, *NewTXOutput(acc-amount, change)) // a change
+	}
+	//计算交易hash
+	tx := Transaction{nil, inputs, outputs, lockTime}
+	tx.ID = tx.Hash()
+	//签名
+	signer := opts.Signer
+	if signer == nil {
+		signer = NewLocalSigner(wallet)
+	}
+	SignTransactionWithView(&tx, signer, wallet.PublicKey, view)
+
+	return &tx
+}
+
+// 创建花费P2SH地址资金的交易：赎回脚本须以P2PKH结尾（如哈希锁、时间锁、相对时间锁），由钱包私钥签名
+// 解锁脚本为 <signature> <pubKey> [<preimage>] <redeemScript>
+func NewScriptHashTransaction(wallet *Wallet, redeemScript []byte, preimage []byte, to string, amount int, UTXOSet *UTXOSet) *Transaction {
+	var inputs []TXInput
+	var outputs []TXOutput
+
+	if wallet.IsLocked() {
+		log.Panic("ERROR: ", ErrWalletLocked)
+	}
+
+	acc, validOutputs := UTXOSet.FindSpendableOutputs(HashPubKey(redeemScript), amount)
+	if acc < amount {
+		log.Panic("ERROR: Not enough funds")
+	}
+	//时间锁：交易的锁定时间须达到脚本要求，且输入不能使用默认序列号
+	var lockTime int64
+	var sequence uint32 = SequenceFinal
+	if lt, _, ok := ExtractTimeLock(redeemScript); ok {
+		lockTime = lt
+		sequence = SequenceFinal - 1
+	}
+	//相对时间锁：输入的序列号须达到脚本要求
+	if seq, _, ok := ExtractRelativeLock(redeemScript); ok {
+		sequence = uint32(seq)
+	}
+
 	for txid, outs := range validOutputs {
 		txID, err := hex.DecodeString(txid)
 		if err != nil {
@@ -221,23 +477,34 @@ func NewUTXOTransaction(wallet *Wallet, to string, amount int, UTXOSet *UTXOSet)
 		}
 
 		for _, out := range outs {
-			input := TXInput{txID, out, nil, wallet.PublicKey}
-			inputs = append(inputs, input)
+			inputs = append(inputs, TXInput{txID, out, nil, sequence})
 		}
 	}
 
-	// 构建交易中的ouput
-	from := fmt.Sprintf("%s", wallet.GetAddress())
 	outputs = append(outputs, *NewTXOutput(amount, to))
-	//找零
+	//找零回P2SH地址
 	if acc > amount {
-		outputs = append(outputs, *NewTXOutput(acc-amount, from)) // a change
+		outputs = append(outputs, *NewTXOutput(acc-amount, scriptAddress(redeemScript)))
 	}
-	//计算交易hash
-	tx := Transaction{nil, inputs, outputs}
+
+	tx := Transaction{nil, inputs, outputs, lockTime}
 	tx.ID = tx.Hash()
-	//签名
-	UTXOSet.Blockchain.SignTransaction(&tx, wallet.PrivateKey)
+	//签名针对赎回脚本
+	signer := NewLocalSigner(wallet)
+	for inID := range tx.Vin {
+		hash := tx.SignatureHash(inID, redeemScript, SigHashAll)
+		signature, err := signer.SignHash(wallet.PublicKey, hash)
+		if err != nil {
+			log.Panic("ERROR: ", err)
+		}
+		signature = append(signature, SigHashAll)
+
+		builder := NewScriptBuilder().AddData(signature).AddData(wallet.PublicKey)
+		if preimage != nil {
+			builder.AddData(preimage)
+		}
+		tx.Vin[inID].ScriptSig = builder.AddData(redeemScript).Script()
+	}
 
 	return &tx
 }
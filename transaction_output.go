@@ -8,20 +8,34 @@ import (
 
 // 交易输出
 type TXOutput struct {
-	Value      int    //币，价值
-	PubKeyHash []byte //交易输出锁定脚本，以地址作为锁定脚本
+	Value        int    //币，价值
+	ScriptPubKey []byte //交易输出锁定脚本
 }
 
-// 锁定签名output
+// 锁定签名output：根据地址的版本号，P2SH地址锁定为P2SH脚本，普通地址锁定为P2PKH脚本
 func (out *TXOutput) Lock(address []byte) {
-	pubKeyHash := Base58Decode(address)
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
-	out.PubKeyHash = pubKeyHash
+	addressVersion, hash := decodeAddress(string(address))
+
+	switch addressVersion {
+	case version:
+		out.ScriptPubKey = NewP2PKHScript(hash)
+	case scriptHashVersion:
+		out.ScriptPubKey = NewP2SHScript(hash)
+	default:
+		log.Panicf("ERROR: Unsupported address version %#x", addressVersion)
+	}
 }
 
-// 判断未花费的utxo能否被指定的公钥hash花费：从地址中解析出公钥hash，然后和ouput中的公钥hash比对
+// 判断未花费的utxo是否归属于指定的地址哈希：从锁定脚本中解析出地址哈希（公钥hash或脚本hash），然后比对
 func (out *TXOutput) IsLockedWithKey(pubKeyHash []byte) bool {
-	return bytes.Compare(out.PubKeyHash, pubKeyHash) == 0
+	lockingHash := extractLockHash(out.ScriptPubKey)
+
+	return lockingHash != nil && bytes.Compare(lockingHash, pubKeyHash) == 0
+}
+
+// 输出是否可花费：数据输出永远不会进入utxo集合
+func (out *TXOutput) IsSpendable() bool {
+	return !IsUnspendable(out.ScriptPubKey)
 }
 
 // 创建一个交易输出
@@ -35,6 +49,7 @@ func NewTXOutput(value int, address string) *TXOutput {
 // TXOutputs collects TXOutput
 type TXOutputs struct {
 	Outputs []TXOutput
+	Indexes []int //每个输出在交易中的索引：已花费和不可花费的输出不在集合中，索引不再连续
 }
 
 // Serialize serializes TXOutputs
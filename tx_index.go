@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"log"
+)
+
+// 交易索引（可选）：交易ID到所在的主链区块和在区块中的位置，查找交易不需要遍历区块
+// 启用状态记录在meta桶中；启用时随链状态在连接、断开区块时更新，由reindexutxo重建
+
+const txIndexBucket = "txindex"
+
+// 交易索引启用状态在meta桶中的键
+const txIndexKey = "txindex"
+
+// TxIndex maps transaction IDs to their blocks in the main chain
+type TxIndex struct {
+	Blockchain *Blockchain
+}
+
+// 交易所在的区块和位置
+type TxLocation struct {
+	BlockHash []byte
+	Position  int
+}
+
+// Serialize serializes the TxLocation
+func (l TxLocation) Serialize() []byte {
+	var buff bytes.Buffer
+
+	err := gob.NewEncoder(&buff).Encode(l)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return buff.Bytes()
+}
+
+// DeserializeTxLocation deserializes a TxLocation
+func DeserializeTxLocation(data []byte) TxLocation {
+	var location TxLocation
+
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&location)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return location
+}
+
+// 交易索引是否启用
+func (t TxIndex) Enabled() bool {
+	return t.Blockchain.db.Get(metaBucket, []byte(txIndexKey)) != nil
+}
+
+// 启用或停用交易索引，之后由Reindex建立或删除索引
+func (t TxIndex) SetEnabled(enabled bool) {
+	batch := &WriteBatch{}
+	if enabled {
+		batch.Put(metaBucket, []byte(txIndexKey), []byte{1})
+	} else {
+		batch.Delete(metaBucket, []byte(txIndexKey))
+	}
+	err := t.Blockchain.db.Write(batch)
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// 查询交易所在的区块和位置；索引未启用或交易不在主链上时返回false
+func (t TxIndex) Find(txID []byte) (TxLocation, bool) {
+	encoded := t.Blockchain.db.Get(txIndexBucket, txID)
+	if encoded == nil {
+		return TxLocation{}, false
+	}
+
+	return DeserializeTxLocation(encoded), true
+}
+
+// 重建交易索引：按高度从创世块开始，ID重复的交易以较晚的为准；未启用时只删除索引
+func (t TxIndex) Reindex() {
+	batch := &WriteBatch{}
+	batch.DeleteBucket(txIndexBucket)
+
+	if t.Enabled() {
+		bc := t.Blockchain
+		for height := 0; height <= bc.GetBestHeight(); height++ {
+			block, err := bc.GetBlockByHeight(height)
+			if err != nil {
+				log.Panic(err)
+			}
+			t.index(batch, block)
+		}
+	}
+
+	err := t.Blockchain.db.Write(batch)
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// 把新区块中的交易加入批量写
+func (t TxIndex) connect(batch *WriteBatch, block *Block) {
+	if t.Enabled() {
+		t.index(batch, block)
+	}
+}
+
+// 撤销区块对交易索引的修改：删除指向该区块的记录
+func (t TxIndex) disconnect(batch *WriteBatch, block *Block) {
+	if !t.Enabled() {
+		return
+	}
+	for _, tx := range block.Transactions {
+		encoded := batch.Get(t.Blockchain.db, txIndexBucket, tx.ID)
+		if encoded != nil && bytes.Equal(DeserializeTxLocation(encoded).BlockHash, block.Hash) {
+			batch.Delete(txIndexBucket, tx.ID)
+		}
+	}
+}
+
+func (t TxIndex) index(batch *WriteBatch, block *Block) {
+	for i, tx := range block.Transactions {
+		batch.Put(txIndexBucket, tx.ID, TxLocation{block.Hash, i}.Serialize())
+	}
+}
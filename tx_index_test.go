@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxIndex(t *testing.T) {
+	wallet := NewWallet()
+	bc := newTestBlockchain(t, wallet)
+	genesis := bc.Iterator().Next()
+	txIndex := TxIndex{bc}
+	assert.False(t, txIndex.Enabled())
+
+	//启用后重建，包含已有的区块
+	txIndex.SetEnabled(true)
+	bc.Reindex()
+	location, ok := txIndex.Find(genesis.Transactions[0].ID)
+	assert.True(t, ok)
+	assert.Equal(t, TxLocation{genesis.Hash, 0}, location)
+
+	spend := newTestSpend(wallet, genesis.Transactions[0], 0, []int{10}, SequenceFinal)
+	old := bc.MineBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), ""), spend})
+	tx, block, err := bc.findTransactionBlock(spend.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, spend.ID, tx.ID)
+	assert.Equal(t, old.Hash, block.Hash)
+
+	//链重组后旧分支上的交易不再能查到
+	fork := NewBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), "fork")}, genesis.Hash, 1)
+	bc.AddBlock(fork)
+	next := NewBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), "next")}, fork.Hash, 2)
+	bc.AddBlock(next)
+	_, err = bc.FindTransaction(spend.ID)
+	assert.NotNil(t, err)
+	location, ok = txIndex.Find(next.Transactions[0].ID)
+	assert.True(t, ok)
+	assert.Equal(t, TxLocation{next.Hash, 0}, location)
+
+	//停用后删除索引，查找退回遍历区块
+	txIndex.SetEnabled(false)
+	bc.Reindex()
+	_, ok = txIndex.Find(next.Transactions[0].ID)
+	assert.False(t, ok)
+	tx, err = bc.FindTransaction(fork.Transactions[0].ID)
+	assert.Nil(t, err)
+	assert.Equal(t, fork.Transactions[0].ID, tx.ID)
+}
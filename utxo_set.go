@@ -3,8 +3,6 @@ package main
 import (
 	"encoding/hex"
 	"log"
-
-	"github.com/boltdb/bolt"
 )
 
 const utxoBucket = "chainstate"
@@ -15,60 +13,54 @@ type UTXOSet struct {
 }
 
 // 查找和返回input中引用的未花费ouput
+// 按默认选币策略选择总额不小于amount的输出，资金不足时返回0
 func (u UTXOSet) FindSpendableOutputs(pubkeyHash []byte, amount int) (int, map[string][]int) {
+	return u.SelectOutputs(pubkeyHash, amount, DefaultCoinSelector)
+}
+
+// 按选币策略选择输出，返回总额和txid -> 输出索引
+func (u UTXOSet) SelectOutputs(pubKeyHash []byte, amount int, selector CoinSelector) (int, map[string][]int) {
 	unspentOutputs := make(map[string][]int)
-	accumulated := 0
-	db := u.Blockchain.db
-
-	err := db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(utxoBucket))
-		c := b.Cursor()
-		//遍历utxo节后
-		for k, v := c.First(); k != nil; k, v = c.Next() {
-			txID := hex.EncodeToString(k)
-			outs := DeserializeOutputs(v)
-			//遍历未花费的output，统计累加加金额，记录未花费的ouput，记录txid和outindex
-			for outIdx, out := range outs.Outputs {
-				if out.IsLockedWithKey(pubkeyHash) && accumulated < amount {
-					accumulated += out.Value
-					unspentOutputs[txID] = append(unspentOutputs[txID], outIdx)
-				}
-			}
-		}
 
-		return nil
-	})
+	selected, err := selector.Select(u.FindUnspentOutputs(pubKeyHash), amount)
 	if err != nil {
-		log.Panic(err)
+		return 0, unspentOutputs
+	}
+	for _, utxo := range selected {
+		txID := hex.EncodeToString(utxo.Txid)
+		unspentOutputs[txID] = append(unspentOutputs[txID], utxo.Vout)
 	}
 
-	return accumulated, unspentOutputs
+	return sumUnspentOutputs(selected), unspentOutputs
 }
 
-// by公钥hash查询余额：未花费utxo总额
-func (u UTXOSet) FindUTXO(pubKeyHash []byte) []TXOutput {
-	var UTXOs []TXOutput
-	db := u.Blockchain.db
-
-	err := db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(utxoBucket))
-		c := b.Cursor()
+// 查询公钥hash（或脚本hash）可花费的所有未花费输出，由地址索引查询
+func (u UTXOSet) FindUnspentOutputs(pubKeyHash []byte) []UnspentOutput {
+	return AddressIndex{u.Blockchain}.UnspentOutputs(pubKeyHash)
+}
 
-		for k, v := c.First(); k != nil; k, v = c.Next() {
-			outs := DeserializeOutputs(v)
+// 查询指定的输出是否未花费
+func (u UTXOSet) FindUnspentOutput(txID []byte, vout int) (UnspentOutput, bool) {
+	outs, ok := u.Blockchain.db.GetUTXOs(txID)
+	if !ok {
+		return UnspentOutput{}, false
+	}
 
-			for _, out := range outs.Outputs {
-				//判断output中的pubkeyhash和公钥hash是否相等，相等则说明该未花费的utxo归属于当前地址
-				if out.IsLockedWithKey(pubKeyHash) {
-					UTXOs = append(UTXOs, out)
-				}
-			}
+	for outIdx, out := range outs.Outputs {
+		if outs.Indexes[outIdx] == vout {
+			return UnspentOutput{append([]byte{}, txID...), vout, out}, true
 		}
+	}
 
-		return nil
-	})
-	if err != nil {
-		log.Panic(err)
+	return UnspentOutput{}, false
+}
+
+// by公钥hash查询余额：未花费utxo总额
+func (u UTXOSet) FindUTXO(pubKeyHash []byte) []TXOutput {
+	var UTXOs []TXOutput
+
+	for _, utxo := range u.FindUnspentOutputs(pubKeyHash) {
+		UTXOs = append(UTXOs, utxo.Output)
 	}
 
 	return UTXOs
@@ -76,16 +68,10 @@ func (u UTXOSet) FindUTXO(pubKeyHash []byte) []TXOutput {
 
 // 统计交易数
 func (u UTXOSet) CountTransactions() int {
-	db := u.Blockchain.db
 	counter := 0
 
-	err := db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(utxoBucket))
-		c := b.Cursor()
-
-		for k, _ := c.First(); k != nil; k, _ = c.Next() {
-			counter++
-		}
+	err := u.Blockchain.db.ForEachUTXO(func(txID []byte, outs TXOutputs) error {
+		counter++
 
 		return nil
 	})
@@ -96,100 +82,118 @@ func (u UTXOSet) CountTransactions() int {
 	return counter
 }
 
-//重建utxo
+//重建utxo：删除utxo集合后写入遍历区块得到的未花费输出，在一次批量写中完成
 func (u UTXOSet) Reindex() {
-	db := u.Blockchain.db
-	bucketName := []byte(utxoBucket)
-	//删除utxo集合
-	err := db.Update(func(tx *bolt.Tx) error {
-		err := tx.DeleteBucket(bucketName)
-		if err != nil && err != bolt.ErrBucketNotFound {
-			log.Panic(err)
-		}
-
-		_, err = tx.CreateBucket(bucketName)
+	batch := &WriteBatch{}
+	batch.DeleteBucket(utxoBucket)
+	//遍历所有区块，查找所有未花费的交易输出，并删除已花费的交易输出，返回txid-outputs map
+	UTXO := u.Blockchain.FindUTXO()
+	for txID, outs := range UTXO {
+		key, err := hex.DecodeString(txID)
 		if err != nil {
 			log.Panic(err)
 		}
 
-		return nil
-	})
+		batch.PutUTXOs(key, outs)
+	}
+	//更新db
+	err := u.Blockchain.db.Write(batch)
 	if err != nil {
 		log.Panic(err)
 	}
-	//遍历所有区块，查找所有未花费的交易输出，并删除已花费的交易输出，返回txid-outputs map
-	UTXO := u.Blockchain.FindUTXO()
-	//更新db
-	err = db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(bucketName)
-
-		for txID, outs := range UTXO {
-			key, err := hex.DecodeString(txID)
-			if err != nil {
-				log.Panic(err)
-			}
-
-			err = b.Put(key, outs.Serialize())
-			if err != nil {
-				log.Panic(err)
-			}
-		}
-
-		return nil
-	})
 }
 
 // 更新utxo集合
 // Update updates the UTXO set with transactions from the Block
 // The Block is considered to be the tip of a blockchain
 func (u UTXOSet) Update(block *Block) {
-	db := u.Blockchain.db
-
-	err := db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(utxoBucket))
-
-		for _, tx := range block.Transactions {
-			if tx.IsCoinbase() == false {
-				for _, vin := range tx.Vin {
-					updatedOuts := TXOutputs{}
-					outsBytes := b.Get(vin.Txid)
-					outs := DeserializeOutputs(outsBytes)
-
-					for outIdx, out := range outs.Outputs {
-						if outIdx != vin.Vout {
-							updatedOuts.Outputs = append(updatedOuts.Outputs, out)
-						}
-					}
+	batch := &WriteBatch{}
+	u.connect(batch, block)
+	err := u.Blockchain.db.Write(batch)
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// 查询批量写提交后交易的未花费输出
+func (u UTXOSet) batchOutputs(batch *WriteBatch, txID []byte) TXOutputs {
+	outsBytes := batch.Get(u.Blockchain.db, utxoBucket, txID)
+	if outsBytes == nil {
+		return TXOutputs{}
+	}
+
+	return DeserializeOutputs(outsBytes)
+}
 
-					if len(updatedOuts.Outputs) == 0 {
-						err := b.Delete(vin.Txid)
-						if err != nil {
-							log.Panic(err)
-						}
+// 把区块对utxo集合的修改加入批量写，返回区块花费的输出（撤销数据）
+// 同一区块中的交易可以花费前面的交易的输出，读取时包含批量写中的修改
+func (u UTXOSet) connect(batch *WriteBatch, block *Block) []SpentOutput {
+	var spent []SpentOutput
+
+	for _, tx := range block.Transactions {
+		if tx.IsCoinbase() == false {
+			for _, vin := range tx.Vin {
+				updatedOuts := TXOutputs{}
+				outs := u.batchOutputs(batch, vin.Txid)
+
+				for outIdx, out := range outs.Outputs {
+					if outs.Indexes[outIdx] != vin.Vout {
+						updatedOuts.Outputs = append(updatedOuts.Outputs, out)
+						updatedOuts.Indexes = append(updatedOuts.Indexes, outs.Indexes[outIdx])
 					} else {
-						err := b.Put(vin.Txid, updatedOuts.Serialize())
-						if err != nil {
-							log.Panic(err)
-						}
+						spent = append(spent, SpentOutput{vin.Txid, vin.Vout, out})
 					}
+				}
 
+				if len(updatedOuts.Outputs) == 0 {
+					batch.DeleteUTXOs(vin.Txid)
+				} else {
+					batch.PutUTXOs(vin.Txid, updatedOuts)
 				}
 			}
+		}
 
-			newOutputs := TXOutputs{}
-			for _, out := range tx.Vout {
-				newOutputs.Outputs = append(newOutputs.Outputs, out)
+		newOutputs := TXOutputs{}
+		for outIdx, out := range tx.Vout {
+			//数据输出不可花费，不进入utxo集合
+			if !out.IsSpendable() {
+				continue
 			}
+			newOutputs.Outputs = append(newOutputs.Outputs, out)
+			newOutputs.Indexes = append(newOutputs.Indexes, outIdx)
+		}
+		if len(newOutputs.Outputs) == 0 {
+			continue
+		}
 
-			err := b.Put(tx.ID, newOutputs.Serialize())
-			if err != nil {
-				log.Panic(err)
-			}
+		batch.PutUTXOs(tx.ID, newOutputs)
+	}
+
+	return spent
+}
+
+// 撤销区块对utxo集合的修改：删除区块中交易的输出，恢复区块花费的输出
+func (u UTXOSet) disconnect(batch *WriteBatch, block *Block, spent []SpentOutput) {
+	created := make(map[string]bool)
+	for _, tx := range block.Transactions {
+		batch.DeleteUTXOs(tx.ID)
+		created[hex.EncodeToString(tx.ID)] = true
+	}
+
+	//花费同一区块中的交易的输出不需要恢复
+	for _, s := range spent {
+		if created[hex.EncodeToString(s.Txid)] {
+			continue
+		}
+		outs := u.batchOutputs(batch, s.Txid)
+		//按输出索引的顺序插入
+		i := 0
+		for i < len(outs.Indexes) && outs.Indexes[i] < s.Vout {
+			i++
 		}
+		outs.Outputs = append(outs.Outputs[:i], append([]TXOutput{s.Output}, outs.Outputs[i:]...)...)
+		outs.Indexes = append(outs.Indexes[:i], append([]int{s.Vout}, outs.Indexes[i:]...)...)
 
-		return nil
-	})
-	if err != nil {
-		log.Panic(err)
+		batch.PutUTXOs(s.Txid, outs)
 	}
 }
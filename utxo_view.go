@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/hex"
+	"log"
+)
+
+// 创建交易时看到的未花费输出：可以只是链上的utxo集合，也可以叠加交易池中尚未确认的交易
+type UTXOView interface {
+	FindUnspentOutputs(pubKeyHash []byte) []UnspentOutput
+	FindUnspentOutput(txID []byte, vout int) (UnspentOutput, bool)
+	FindTransaction(ID []byte) (Transaction, error)
+}
+
+// 查找链上的交易，签名时使用
+func (u UTXOSet) FindTransaction(ID []byte) (Transaction, error) {
+	return u.Blockchain.FindTransaction(ID)
+}
+
+// 叠加交易池的utxo视图：交易池中的交易花费的输出不再可用，其产生的输出可以继续花费
+type MempoolUTXOView struct {
+	UTXOSet UTXOSet
+	Mempool *Mempool
+}
+
+// FindUnspentOutputs returns confirmed and unconfirmed outputs that are not spent in the mempool
+func (v MempoolUTXOView) FindUnspentOutputs(pubKeyHash []byte) []UnspentOutput {
+	var utxos []UnspentOutput
+	for _, utxo := range v.UTXOSet.FindUnspentOutputs(pubKeyHash) {
+		if !v.Mempool.IsSpent(utxo.Txid, utxo.Vout) {
+			utxos = append(utxos, utxo)
+		}
+	}
+
+	return append(utxos, v.Mempool.FindUnspentOutputs(pubKeyHash)...)
+}
+
+// FindUnspentOutput looks the output up in the mempool first, then in the UTXO set
+func (v MempoolUTXOView) FindUnspentOutput(txID []byte, vout int) (UnspentOutput, bool) {
+	if v.Mempool.IsSpent(txID, vout) {
+		return UnspentOutput{}, false
+	}
+	if utxo, ok := v.Mempool.FindUnspentOutput(txID, vout); ok {
+		return utxo, true
+	}
+
+	return v.UTXOSet.FindUnspentOutput(txID, vout)
+}
+
+// FindTransaction looks the transaction up in the mempool first, then on the chain
+func (v MempoolUTXOView) FindTransaction(ID []byte) (Transaction, error) {
+	if tx, ok := v.Mempool.Get(ID); ok {
+		return tx, nil
+	}
+
+	return v.UTXOSet.FindTransaction(ID)
+}
+
+// 用视图中查到的被花费交易对交易签名，被花费的交易可以尚未确认
+func SignTransactionWithView(tx *Transaction, signer Signer, pubKey []byte, view UTXOView) {
+	prevTXs := make(map[string]Transaction)
+	for _, vin := range tx.Vin {
+		prevTX, err := view.FindTransaction(vin.Txid)
+		if err != nil {
+			log.Panic(err)
+		}
+		prevTXs[hex.EncodeToString(prevTX.ID)] = prevTX
+	}
+
+	if err := tx.SignWith(signer, pubKey, prevTXs); err != nil {
+		log.Panic("ERROR: ", err)
+	}
+}
@@ -3,10 +3,13 @@ package main
 import (
 	"bytes"
 	"crypto/ecdsa"
-	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/gob"
+	"errors"
 	"log"
+	"math/big"
+	"strings"
 
 	"golang.org/x/crypto/ripemd160"
 )
@@ -14,28 +17,106 @@ import (
 //版本号：1个字节
 const version = byte(0x00)
 
+//脚本哈希（P2SH）地址的版本号：地址中编码的是赎回脚本的哈希
+const scriptHashVersion = byte(0x05)
+
+//导出私钥的版本号（与WIF相同）
+const privateKeyVersion = byte(0x80)
+
+//WIF中表示公钥为压缩格式的标志，用于secp256k1私钥
+const compressedKeyFlag = byte(0x01)
+
 //校验和：4个字节
 const addressChecksumLen = 4
 
+// 导入的私钥格式错误
+var ErrInvalidPrivateKey = errors.New("invalid private key")
+
 // 钱包的本质是公私钥对，私钥->公钥->钱包地址
 type Wallet struct {
 	PrivateKey ecdsa.PrivateKey
 	PublicKey  []byte
+	Path       string //HD派生路径，随机生成的私钥为空
 }
 
 // 创建一个钱包，返回钱包地址
 func NewWallet() *Wallet {
-	private, public := newKeyPair()
-	wallet := Wallet{private, public}
+	return NewWalletOfType(KeyTypeP256)
+}
+
+// 创建指定密钥类型的钱包
+func NewWalletOfType(keyType KeyType) *Wallet {
+	private, public := newKeyPair(keyType)
+	wallet := Wallet{private, public, ""}
 
 	return &wallet
 }
 
+// 由已有的私钥创建钱包，如导入的私钥
+func NewWalletFromPrivateKey(privKey ecdsa.PrivateKey) *Wallet {
+	return &Wallet{privKey, encodePubKey(privKey.PublicKey), ""}
+}
+
+// 由HD扩展私钥创建钱包
+func newHDWallet(key *ExtendedKey, path string) (*Wallet, error) {
+	private, err := key.ECPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Wallet{private, key.WalletPublicKey(), path}, nil
+}
+
+// 是否为HD钱包内部链（找零）上的地址
+func (w Wallet) IsChange() bool {
+	return strings.HasPrefix(w.Path, hdAccountPath+"/1/")
+}
+
+// 钱包文件中保存的形式：私钥只保存32字节的D，曲线由公钥的格式确定
+type walletData struct {
+	PrivateKey []byte
+	PublicKey  []byte
+	Path       string
+}
+
+// GobEncode stores the private key scalar instead of the ecdsa structure
+func (w *Wallet) GobEncode() ([]byte, error) {
+	var buff bytes.Buffer
+	data := walletData{nil, w.PublicKey, w.Path}
+	//钱包加密时私钥不写入文件
+	if w.PrivateKey.D != nil {
+		data.PrivateKey = paddedBytes(w.PrivateKey.D, 32)
+	}
+	err := gob.NewEncoder(&buff).Encode(data)
+
+	return buff.Bytes(), err
+}
+
+// GobDecode restores the P-256 private key from its scalar
+func (w *Wallet) GobDecode(b []byte) error {
+	var data walletData
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&data); err != nil {
+		return err
+	}
+	if data.PrivateKey != nil {
+		w.PrivateKey = walletKeyType(data.PublicKey).PrivateKey(data.PrivateKey)
+	}
+	w.PublicKey = data.PublicKey
+	w.Path = data.Path
+
+	return nil
+}
+
 // 生成钱包地址
 func (w Wallet) GetAddress() []byte {
 	pubKeyHash := HashPubKey(w.PublicKey)
 
-	versionedPayload := append([]byte{version}, pubKeyHash...)
+	return encodeAddress(version, pubKeyHash)
+}
+
+// 地址编码：Base58Check(版本号 + 哈希 + 校验和)
+func encodeAddress(version byte, hash []byte) []byte {
+	versionedPayload := append([]byte{version}, hash...)
 	checksum := checksum(versionedPayload)
 
 	fullPayload := append(versionedPayload, checksum...)
@@ -44,6 +125,60 @@ func (w Wallet) GetAddress() []byte {
 	return address
 }
 
+// 地址解码：返回版本号和哈希（公钥哈希或赎回脚本哈希）
+func decodeAddress(address string) (byte, []byte) {
+	payload := Base58Decode([]byte(address))
+
+	return payload[0], payload[1 : len(payload)-addressChecksumLen]
+}
+
+// 私钥编码（类似WIF）：Base58Check(版本号 + 32字节私钥 + 校验和)
+// secp256k1私钥与比特币的压缩公钥WIF相同，私钥后加0x01
+func EncodePrivateKey(privKey ecdsa.PrivateKey) string {
+	payload := append([]byte{privateKeyVersion}, paddedBytes(privKey.D, 32)...)
+	if curveKeyType(privKey.Curve) == KeyTypeSecp256k1 {
+		payload = append(payload, compressedKeyFlag)
+	}
+	payload = append(payload, checksum(payload)...)
+
+	return string(Base58Encode(payload))
+}
+
+// 解码导出的私钥，校验版本号、校验和以及私钥范围
+func DecodePrivateKey(s string) (ecdsa.PrivateKey, error) {
+	if s == "" || strings.Trim(s, string(b58Alphabet)) != "" {
+		return ecdsa.PrivateKey{}, ErrInvalidPrivateKey
+	}
+	payload := Base58Decode([]byte(s))
+	if len(payload) < 1+32+addressChecksumLen || payload[0] != privateKeyVersion {
+		return ecdsa.PrivateKey{}, ErrInvalidPrivateKey
+	}
+	data, sum := payload[:len(payload)-addressChecksumLen], payload[len(payload)-addressChecksumLen:]
+	if !bytes.Equal(checksum(data), sum) {
+		return ecdsa.PrivateKey{}, ErrInvalidPrivateKey
+	}
+	//带压缩标志的为secp256k1私钥
+	keyType := KeyTypeP256
+	switch {
+	case len(data) == 1+32:
+	case len(data) == 1+32+1 && data[1+32] == compressedKeyFlag:
+		keyType = KeyTypeSecp256k1
+	default:
+		return ecdsa.PrivateKey{}, ErrInvalidPrivateKey
+	}
+	d := new(big.Int).SetBytes(data[1 : 1+32])
+	if d.Sign() == 0 || d.Cmp(keyType.Curve().Params().N) >= 0 {
+		return ecdsa.PrivateKey{}, ErrInvalidPrivateKey
+	}
+
+	return keyType.PrivateKey(data[1 : 1+32]), nil
+}
+
+// 赎回脚本对应的P2SH地址
+func scriptAddress(redeemScript []byte) string {
+	return string(encodeAddress(scriptHashVersion, HashPubKey(redeemScript)))
+}
+
 // 先sha256运算，再ripemd160运算，获得公钥哈希
 func HashPubKey(pubKey []byte) []byte {
 	publicSHA256 := sha256.Sum256(pubKey)
@@ -60,15 +195,24 @@ func HashPubKey(pubKey []byte) []byte {
 
 // 校验地址是否合法
 // (1)钱包地址base58解码
-// (2)解析获得版本号、公钥哈希和校验和
+// (2)解析获得版本号、公钥哈希和校验和，版本号须为普通地址或P2SH地址
 // (3)对版比你好和公钥哈希进行两次sha256运算，取其最后4个字节作为目标校验和
 // (4)比对校验和是否一致，即可判断地址是否合法
 func ValidateAddress(address string) bool {
+	if address == "" {
+		return false
+	}
 	pubKeyHash := Base58Decode([]byte(address))
+	if len(pubKeyHash) != 1+20+addressChecksumLen {
+		return false
+	}
 	actualChecksum := pubKeyHash[len(pubKeyHash)-addressChecksumLen:]
-	version := pubKeyHash[0]
+	addressVersion := pubKeyHash[0]
+	if addressVersion != version && addressVersion != scriptHashVersion {
+		return false
+	}
 	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-addressChecksumLen]
-	targetChecksum := checksum(append([]byte{version}, pubKeyHash...))
+	targetChecksum := checksum(append([]byte{addressVersion}, pubKeyHash...))
 
 	return bytes.Compare(actualChecksum, targetChecksum) == 0
 }
@@ -82,13 +226,63 @@ func checksum(payload []byte) []byte {
 }
 
 //椭圆曲线，生成公私钥对
-func newKeyPair() (ecdsa.PrivateKey, []byte) {
-	curve := elliptic.P256()
-	private, err := ecdsa.GenerateKey(curve, rand.Reader)
+func newKeyPair(keyType KeyType) (ecdsa.PrivateKey, []byte) {
+	if keyType == KeyTypeSecp256k1 {
+		private := newSecp256k1Key()
+		return private, encodePubKey(private.PublicKey)
+	}
+	private, err := ecdsa.GenerateKey(keyType.Curve(), rand.Reader)
 	if err != nil {
 		log.Panic(err)
 	}
-	pubKey := append(private.PublicKey.X.Bytes(), private.PublicKey.Y.Bytes()...)
+	pubKey := encodePubKey(private.PublicKey)
 
 	return *private, pubKey
 }
+
+// 公钥编码：P-256为X坐标和Y坐标各补齐到32字节后拼接，secp256k1为33字节压缩格式
+func encodePubKey(pubKey ecdsa.PublicKey) []byte {
+	if curveKeyType(pubKey.Curve) == KeyTypeSecp256k1 {
+		return secp256k1MarshalCompressed(pubKey)
+	}
+
+	return append(paddedBytes(pubKey.X, 32), paddedBytes(pubKey.Y, 32)...)
+}
+
+// 对哈希签名，签名为r和s各32字节拼接
+func signHash(privKey ecdsa.PrivateKey, hash []byte) []byte {
+	if curveKeyType(privKey.Curve) == KeyTypeSecp256k1 {
+		return secp256k1Sign(privKey, hash)
+	}
+	r, s, err := ecdsa.Sign(rand.Reader, &privKey, hash)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	signature := make([]byte, 64)
+	rBytes, sBytes := r.Bytes(), s.Bytes()
+	copy(signature[32-len(rBytes):32], rBytes)
+	copy(signature[64-len(sBytes):], sBytes)
+
+	return signature
+}
+
+// 用公钥验证哈希的签名，曲线由公钥的格式确定
+func verifySignature(pubKey, signature, hash []byte) bool {
+	if len(signature) != 64 || len(pubKey) == 0 {
+		return false
+	}
+
+	if keyType, ok := pubKeyType(pubKey); ok && keyType == KeyTypeSecp256k1 {
+		return secp256k1Verify(pubKey, signature, hash)
+	}
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+
+	rawPubKey, ok := decodePubKey(pubKey)
+	if !ok {
+		return false
+	}
+
+	return ecdsa.Verify(&rawPubKey, hash, r, s)
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// 钱包加密：私钥和HD种子用由密码派生的密钥（scrypt）以AES-GCM加密保存
+// 地址、公钥和派生路径不加密，锁定时仍可查询余额、派生新地址
+
+// scrypt参数
+const (
+	walletScryptN       = 32768
+	walletScryptR       = 8
+	walletScryptP       = 1
+	walletKeyLen        = 32
+	walletPassphraseEnv = "WALLET_PASSPHRASE" //设置该环境变量时，命令执行期间自动解锁钱包
+)
+
+// 加密后用于核对密码的数据
+var walletEncryptionCheck = []byte("wallet")
+
+// 钱包加密错误
+var (
+	ErrWalletLocked        = errors.New("wallet is locked")
+	ErrWalletNotEncrypted  = errors.New("wallet is not encrypted")
+	ErrWalletEncrypted     = errors.New("wallet is already encrypted")
+	ErrIncorrectPassphrase = errors.New("incorrect wallet passphrase")
+)
+
+// 加密的私钥和种子
+type WalletEncryption struct {
+	Salt        []byte
+	Keys        map[string][]byte //地址 -> 加密的私钥，HD地址的私钥由种子派生，不单独保存
+	Seed        []byte            //加密的HD种子
+	Check       []byte            //加密的校验数据，用于核对密码
+	AccountXpub string            //账户扩展公钥：锁定时派生新地址
+}
+
+// 钱包是否已加密
+func (ws *Wallets) IsEncrypted() bool {
+	return ws.Encryption != nil
+}
+
+// 钱包是否已锁定：已加密且未解锁
+func (ws *Wallets) IsLocked() bool {
+	return ws.Encryption != nil && ws.encryptionKey == nil
+}
+
+// 私钥是否不可用
+func (w Wallet) IsLocked() bool {
+	return w.PrivateKey.D == nil
+}
+
+// 用密码加密钱包，加密后钱包处于锁定状态
+func (ws *Wallets) Encrypt(passphrase string) error {
+	if ws.IsEncrypted() {
+		return ErrWalletEncrypted
+	}
+	if passphrase == "" {
+		return errors.New("passphrase is empty")
+	}
+
+	salt := make([]byte, 16)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return err
+	}
+	key, err := walletEncryptionKey(passphrase, salt)
+	if err != nil {
+		return err
+	}
+
+	encryption := &WalletEncryption{Salt: salt, Keys: make(map[string][]byte)}
+	encryption.Check, err = sealWalletSecret(key, walletEncryptionCheck)
+	if err != nil {
+		return err
+	}
+	if ws.Seed != nil {
+		encryption.Seed, err = sealWalletSecret(key, ws.Seed)
+		if err != nil {
+			return err
+		}
+		account, err := ws.DeriveKey(hdAccountPath)
+		if err != nil {
+			return err
+		}
+		encryption.AccountXpub = account.Neuter().String()
+	}
+	ws.Encryption = encryption
+	ws.encryptionKey = key
+	if err := ws.sealKeys(); err != nil {
+		ws.Encryption, ws.encryptionKey = nil, nil
+		return err
+	}
+	ws.Lock()
+
+	return nil
+}
+
+// 用密码解锁钱包
+func (ws *Wallets) Unlock(passphrase string) error {
+	if !ws.IsEncrypted() {
+		return ErrWalletNotEncrypted
+	}
+	key, err := walletEncryptionKey(passphrase, ws.Encryption.Salt)
+	if err != nil {
+		return err
+	}
+
+	return ws.UnlockWithKey(key)
+}
+
+// 用由密码派生的密钥解锁钱包：解密种子和私钥，HD地址的私钥由种子重新派生
+func (ws *Wallets) UnlockWithKey(key []byte) error {
+	if !ws.IsEncrypted() {
+		return ErrWalletNotEncrypted
+	}
+	if _, err := openWalletSecret(key, ws.Encryption.Check); err != nil {
+		return ErrIncorrectPassphrase
+	}
+
+	var seed []byte
+	if ws.Encryption.Seed != nil {
+		var err error
+		seed, err = openWalletSecret(key, ws.Encryption.Seed)
+		if err != nil {
+			return ErrIncorrectPassphrase
+		}
+	}
+	keys := make(map[string][]byte)
+	for address, sealed := range ws.Encryption.Keys {
+		d, err := openWalletSecret(key, sealed)
+		if err != nil {
+			return ErrIncorrectPassphrase
+		}
+		keys[address] = d
+	}
+
+	ws.Seed = seed
+	ws.encryptionKey = key
+	for address, wallet := range ws.Wallets {
+		if d, ok := keys[address]; ok {
+			wallet.PrivateKey = walletKeyType(wallet.PublicKey).PrivateKey(d)
+			continue
+		}
+		if wallet.Path != "" && seed != nil {
+			hdKey, err := ws.DeriveKey(wallet.Path)
+			if err != nil {
+				return err
+			}
+			wallet.PrivateKey, err = hdKey.ECPrivateKey()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// 锁定钱包：清除内存中的种子和私钥
+func (ws *Wallets) Lock() {
+	if !ws.IsEncrypted() {
+		return
+	}
+	ws.Seed = nil
+	ws.encryptionKey = nil
+	for _, wallet := range ws.Wallets {
+		wallet.PrivateKey = ecdsa.PrivateKey{PublicKey: wallet.PrivateKey.PublicKey}
+	}
+}
+
+// 解锁时使用的密钥，锁定时为nil
+func (ws *Wallets) EncryptionKey() []byte {
+	return ws.encryptionKey
+}
+
+// 设置了WALLET_PASSPHRASE环境变量时解锁钱包
+func (ws *Wallets) unlockFromEnv() error {
+	passphrase := os.Getenv(walletPassphraseEnv)
+	if passphrase == "" || !ws.IsEncrypted() {
+		return nil
+	}
+
+	return ws.Unlock(passphrase)
+}
+
+// 生成HD种子；加密的钱包须已解锁，种子加密保存
+func (ws *Wallets) initSeed() error {
+	if ws.IsLocked() {
+		return ErrWalletLocked
+	}
+	seed := make([]byte, 32)
+	_, err := rand.Read(seed)
+	if err != nil {
+		return err
+	}
+	ws.Seed = seed
+	if !ws.IsEncrypted() {
+		return nil
+	}
+
+	ws.Encryption.Seed, err = sealWalletSecret(ws.encryptionKey, seed)
+	if err != nil {
+		return err
+	}
+	account, err := ws.DeriveKey(hdAccountPath)
+	if err != nil {
+		return err
+	}
+	ws.Encryption.AccountXpub = account.Neuter().String()
+
+	return nil
+}
+
+// 加密尚未加密保存的非HD私钥
+func (ws *Wallets) sealKeys() error {
+	for address, wallet := range ws.Wallets {
+		if wallet.Path != "" || wallet.IsLocked() {
+			continue
+		}
+		if _, ok := ws.Encryption.Keys[address]; ok {
+			continue
+		}
+		sealed, err := sealWalletSecret(ws.encryptionKey, paddedBytes(wallet.PrivateKey.D, 32))
+		if err != nil {
+			return err
+		}
+		ws.Encryption.Keys[address] = sealed
+	}
+
+	return nil
+}
+
+// 写入文件的钱包：加密的钱包不含明文私钥和种子
+func (ws *Wallets) fileContent() (*Wallets, error) {
+	if !ws.IsEncrypted() {
+		return ws, nil
+	}
+	if ws.encryptionKey != nil {
+		if err := ws.sealKeys(); err != nil {
+			return nil, err
+		}
+	}
+
+	stripped := &Wallets{
+		Wallets:      make(map[string]*Wallet),
+		Scripts:      ws.Scripts,
+		WatchOnly:    ws.WatchOnly,
+		Xpubs:        ws.Xpubs,
+		Transactions: ws.Transactions,
+		ScanTip:      ws.ScanTip,
+		Labels:       ws.Labels,
+		NextExternal: ws.NextExternal,
+		NextInternal: ws.NextInternal,
+		Encryption:   ws.Encryption,
+	}
+	for address, wallet := range ws.Wallets {
+		if wallet.Path == "" && !wallet.IsLocked() && ws.Encryption.Keys[address] == nil {
+			return nil, fmt.Errorf("key of %s is not encrypted", address)
+		}
+		stripped.Wallets[address] = &Wallet{ecdsa.PrivateKey{}, wallet.PublicKey, wallet.Path}
+	}
+
+	return stripped, nil
+}
+
+// 由密码派生加密密钥
+func walletEncryptionKey(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, walletScryptN, walletScryptR, walletScryptP, walletKeyLen)
+}
+
+// AES-GCM加密，结果为 nonce + 密文
+func sealWalletSecret(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newWalletGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// AES-GCM解密，密钥错误时认证失败
+func openWalletSecret(key, sealed []byte) ([]byte, error) {
+	gcm, err := newWalletGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("encrypted data is too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func newWalletGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalletEncryption(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "wallet")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmp)
+	dir, err := os.Getwd()
+	assert.Nil(t, err)
+	assert.Nil(t, os.Chdir(tmp))
+	defer os.Chdir(dir)
+
+	wallets, _ := NewWallets("test")
+	first := wallets.CreateWallet()
+	//非HD私钥单独加密保存
+	imported := NewWallet()
+	importedAddress := string(imported.GetAddress())
+	wallets.Wallets[importedAddress] = imported
+	key := wallets.Wallets[first].PrivateKey.D
+	importedKey := imported.PrivateKey.D
+	seed := wallets.Seed
+
+	assert.Nil(t, wallets.Encrypt("secret"))
+	assert.Equal(t, ErrWalletEncrypted, wallets.Encrypt("secret"))
+	assert.True(t, wallets.IsLocked())
+	assert.True(t, wallets.Wallets[first].IsLocked())
+	assert.Nil(t, wallets.Seed)
+
+	//锁定时由账户扩展公钥派生新地址，解锁后可以签名
+	second := wallets.CreateWallet()
+	assert.True(t, wallets.Wallets[second].IsLocked())
+	//上次保存中断时残留的临时文件权限过宽
+	assert.Nil(t, ioutil.WriteFile("wallet_test.dat.tmp", []byte("stale"), 0644))
+	wallets.SaveToFile("test")
+
+	//文件中没有明文私钥和种子
+	content, err := ioutil.ReadFile("wallet_test.dat")
+	assert.Nil(t, err)
+	assert.False(t, bytes.Contains(content, seed))
+	assert.False(t, bytes.Contains(content, paddedBytes(key, 32)))
+	info, err := os.Stat("wallet_test.dat")
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	loaded, err := NewWallets("test")
+	assert.Nil(t, err)
+	assert.True(t, loaded.IsLocked())
+	assert.Equal(t, ErrIncorrectPassphrase, loaded.Unlock("wrong"))
+	assert.True(t, loaded.IsLocked())
+	assert.Nil(t, loaded.Unlock("secret"))
+	assert.Equal(t, seed, loaded.Seed)
+	assert.Equal(t, key, loaded.Wallets[first].PrivateKey.D)
+	assert.Equal(t, importedKey, loaded.Wallets[importedAddress].PrivateKey.D)
+	assert.False(t, loaded.Wallets[second].IsLocked())
+	derived, err := loaded.DeriveKey(loaded.Wallets[second].Path)
+	assert.Nil(t, err)
+	assert.Equal(t, derived.WalletPublicKey(), loaded.Wallets[second].PublicKey)
+
+	encryptionKey := loaded.EncryptionKey()
+	loaded.Lock()
+	assert.True(t, loaded.Wallets[first].IsLocked())
+	assert.Nil(t, loaded.EncryptionKey())
+	assert.Nil(t, loaded.UnlockWithKey(encryptionKey))
+	assert.Equal(t, key, loaded.Wallets[first].PrivateKey.D)
+
+	//设置环境变量时加载即解锁
+	os.Setenv(walletPassphraseEnv, "secret")
+	defer os.Unsetenv(walletPassphraseEnv)
+	unlocked, err := NewWallets("test")
+	assert.Nil(t, err)
+	assert.False(t, unlocked.IsLocked())
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"sort"
+)
+
+// 钱包交易记录：扫描区块链和交易池，记录与钱包地址（含只读地址和P2SH地址）有关的交易
+// 记录保存在钱包文件中，之后只扫描新区块；导入地址或链重组后重新扫描全部区块
+
+// 与钱包有关的交易
+type WalletTransaction struct {
+	Txid           string
+	BlockHash      string //未确认时为空
+	Height         int    //区块高度，未确认时为-1
+	Index          int    //在区块（或交易池）中的位置
+	Time           int64  //区块时间戳
+	Coinbase       bool
+	Deltas         map[string]int //钱包地址 -> 收到的减去花费的金额
+	Fee            int            //手续费，只在输入来自钱包时记录
+	Counterparties []string       //转出时为收款地址，收到时为付款地址
+}
+
+// 交易使钱包（或钱包中的某个地址）余额的变化，含手续费
+func (t *WalletTransaction) Amount(address string) int {
+	if address != "" {
+		return t.Deltas[address]
+	}
+	amount := 0
+	for _, delta := range t.Deltas {
+		amount += delta
+	}
+
+	return amount
+}
+
+// 交易的确认数，未确认为0
+func (t *WalletTransaction) Confirmations(bestHeight int) int {
+	if t.Height < 0 {
+		return 0
+	}
+
+	return bestHeight - t.Height + 1
+}
+
+// 交易类别：generate（挖矿奖励）、send、receive
+func (t *WalletTransaction) Category(address string) string {
+	switch amount := t.Amount(address); {
+	case t.Coinbase:
+		return "generate"
+	case amount < 0:
+		return "send"
+	case amount > 0:
+		return "receive"
+	}
+
+	return "self"
+}
+
+// 钱包中的所有地址：有私钥的地址、P2SH地址和只读地址
+func (ws *Wallets) walletAddresses() map[string]bool {
+	owned := make(map[string]bool)
+	for address := range ws.Wallets {
+		owned[address] = true
+	}
+	for address := range ws.Scripts {
+		owned[address] = true
+	}
+	for address := range ws.WatchOnly {
+		owned[address] = true
+	}
+
+	return owned
+}
+
+// 扫描区块链和交易池，更新交易记录，返回扫描的区块数
+// 从上次扫描到的区块之后开始；上次的区块已不在链上（重组）或ScanTip为空时重新扫描全部区块
+func (ws *Wallets) Rescan(bc *Blockchain, mempool *Mempool) int {
+	var blocks []*Block
+	found := false
+	bci := bc.Iterator()
+	for {
+		block := bci.Next()
+		if ws.ScanTip != nil && bytes.Equal(block.Hash, ws.ScanTip) {
+			found = true
+			break
+		}
+		blocks = append(blocks, block)
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+	if !found || ws.Transactions == nil {
+		ws.Transactions = make(map[string]*WalletTransaction)
+	}
+	//未确认的交易每次重新扫描
+	for txid, record := range ws.Transactions {
+		if record.Height < 0 {
+			delete(ws.Transactions, txid)
+		}
+	}
+
+	owned := ws.walletAddresses()
+	outputs := make(map[string]TXOutput)
+	prevOut := func(vin TXInput) (TXOutput, bool) {
+		if out, ok := outputs[outPoint(vin.Txid, vin.Vout)]; ok {
+			return out, true
+		}
+		//增量扫描时被花费的输出在之前的区块中
+		tx, err := bc.FindTransaction(vin.Txid)
+		if err != nil || vin.Vout < 0 || vin.Vout >= len(tx.Vout) {
+			return TXOutput{}, false
+		}
+		return tx.Vout[vin.Vout], true
+	}
+	scan := func(tx *Transaction) *WalletTransaction {
+		record := newWalletTransaction(tx, owned, prevOut)
+		for i, out := range tx.Vout {
+			outputs[outPoint(tx.ID, i)] = out
+		}
+		if record != nil {
+			ws.Transactions[record.Txid] = record
+		}
+		return record
+	}
+
+	for i := len(blocks) - 1; i >= 0; i-- {
+		block := blocks[i]
+		for index, tx := range block.Transactions {
+			if record := scan(tx); record != nil {
+				record.BlockHash = hex.EncodeToString(block.Hash)
+				record.Height = block.Height
+				record.Index = index
+				record.Time = block.Timestamp
+			}
+		}
+	}
+	for index, tx := range mempool.Transactions() {
+		tx := tx
+		if record := scan(&tx); record != nil {
+			record.Index = index
+		}
+	}
+	ws.ScanTip = bc.tip
+
+	return len(blocks)
+}
+
+// 按时间顺序排列的交易记录，未确认的交易排在最后；指定地址时只返回与该地址有关的交易
+func (ws *Wallets) History(address string) []*WalletTransaction {
+	var history []*WalletTransaction
+	for _, record := range ws.Transactions {
+		if _, ok := record.Deltas[address]; ok || address == "" {
+			history = append(history, record)
+		}
+	}
+
+	sort.Slice(history, func(i, j int) bool {
+		a, b := history[i], history[j]
+		if (a.Height < 0) != (b.Height < 0) {
+			return b.Height < 0
+		}
+		if a.Height != b.Height {
+			return a.Height < b.Height
+		}
+		return a.Index < b.Index
+	})
+
+	return history
+}
+
+// 设置地址或交易的标签，标签为空时删除
+func (ws *Wallets) SetLabel(key, label string) {
+	if ws.Labels == nil {
+		ws.Labels = make(map[string]string)
+	}
+	if label == "" {
+		delete(ws.Labels, key)
+		return
+	}
+
+	ws.Labels[key] = label
+}
+
+// 由交易生成钱包交易记录，交易与钱包地址无关时返回nil
+func newWalletTransaction(tx *Transaction, owned map[string]bool, prevOut func(vin TXInput) (TXOutput, bool)) *WalletTransaction {
+	deltas := make(map[string]int)
+	var senders, recipients []string
+	fromWallet := false
+	inputs, outputs := 0, 0
+
+	if !tx.IsCoinbase() {
+		for _, vin := range tx.Vin {
+			out, ok := prevOut(vin)
+			if !ok {
+				continue
+			}
+			inputs += out.Value
+			address := ExtractAddress(out.ScriptPubKey)
+			if owned[address] {
+				deltas[address] -= out.Value
+				fromWallet = true
+			} else if address != "" {
+				senders = appendAddress(senders, address)
+			}
+		}
+	}
+	for _, out := range tx.Vout {
+		outputs += out.Value
+		address := ExtractAddress(out.ScriptPubKey)
+		if owned[address] {
+			deltas[address] += out.Value
+		} else if address != "" {
+			recipients = appendAddress(recipients, address)
+		}
+	}
+	if len(deltas) == 0 {
+		return nil
+	}
+
+	record := &WalletTransaction{Txid: hex.EncodeToString(tx.ID), Height: -1, Coinbase: tx.IsCoinbase(), Deltas: deltas}
+	if fromWallet {
+		record.Fee = inputs - outputs
+		record.Counterparties = recipients
+	} else {
+		record.Counterparties = senders
+	}
+
+	return record
+}
+
+// 追加不重复的地址
+func appendAddress(addresses []string, address string) []string {
+	for _, a := range addresses {
+		if a == address {
+			return addresses
+		}
+	}
+
+	return append(addresses, address)
+}
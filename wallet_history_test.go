@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalletHistory(t *testing.T) {
+	wallet := NewWallet()
+	bc := newTestBlockchain(t, wallet)
+	address := string(wallet.GetAddress())
+	other := string(NewWallet().GetAddress())
+	wallets := Wallets{Wallets: map[string]*Wallet{address: wallet}}
+
+	//转出3，手续费1，找零6
+	sent := NewPaymentTransaction(wallet, []TXOutput{*NewTXOutput(3, other)}, PaymentOptions{Fee: 1}, UTXOSet{bc})
+	block := bc.MineBlock([]*Transaction{NewFeeCoinbaseTX(other, 1), sent})
+	UTXOSet{bc}.Update(block)
+	mempool := NewMempool()
+	received := NewPaymentTransaction(wallet, []TXOutput{*NewTXOutput(2, address)}, PaymentOptions{}, MempoolUTXOView{UTXOSet{bc}, mempool})
+	_, err := mempool.Accept(bc, received)
+	assert.Nil(t, err)
+
+	assert.Equal(t, 2, wallets.Rescan(bc, mempool))
+	history := wallets.History("")
+	assert.Equal(t, 3, len(history))
+	assert.Equal(t, "generate", history[0].Category(""))
+	assert.Equal(t, 2, history[0].Confirmations(bc.GetBestHeight()))
+	assert.Equal(t, "send", history[1].Category(""))
+	assert.Equal(t, -4, history[1].Amount(""))
+	assert.Equal(t, 1, history[1].Fee)
+	assert.Equal(t, []string{other}, history[1].Counterparties)
+	assert.Equal(t, hexID(received), history[2].Txid)
+	assert.Equal(t, 0, history[2].Confirmations(bc.GetBestHeight()))
+	assert.Equal(t, "self", history[2].Category(""))
+
+	//增量扫描：没有新区块，未确认的交易已被打包
+	block = bc.MineBlock([]*Transaction{NewCoinbaseTX(other, ""), received})
+	assert.Equal(t, 1, wallets.Rescan(bc, NewMempool()))
+	assert.Equal(t, 3, len(wallets.History(address)))
+	assert.Equal(t, block.Height, wallets.Transactions[hexID(received)].Height)
+	assert.Equal(t, 0, wallets.Rescan(bc, NewMempool()))
+
+	//与地址无关的交易不记录
+	assert.Empty(t, wallets.History(other))
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrivateKeyEncoding(t *testing.T) {
+	wallet := NewWallet()
+	encoded := EncodePrivateKey(wallet.PrivateKey)
+
+	privKey, err := DecodePrivateKey(encoded)
+	assert.Nil(t, err)
+	assert.Equal(t, wallet.PrivateKey.D, privKey.D)
+	assert.Equal(t, wallet.GetAddress(), NewWalletFromPrivateKey(privKey).GetAddress())
+
+	//校验和、版本号或字符错误
+	corrupted := []byte(encoded)
+	corrupted[len(corrupted)-1] = b58Alphabet[(bytes.IndexByte(b58Alphabet, corrupted[len(corrupted)-1])+1)%58]
+	for _, s := range []string{"", "0OIl", string(corrupted), string(encodeAddress(version, HashPubKey(wallet.PublicKey)))} {
+		_, err := DecodePrivateKey(s)
+		assert.Equal(t, ErrInvalidPrivateKey, err, s)
+	}
+}
+
+func TestImportPrivateKey(t *testing.T) {
+	wallet := NewWallet()
+	wallets := Wallets{Wallets: make(map[string]*Wallet)}
+	wallets.CreateWallet()
+
+	address, err := wallets.ImportPrivateKey(wallet.PrivateKey)
+	assert.Nil(t, err)
+	assert.Equal(t, string(wallet.GetAddress()), address)
+	assert.Equal(t, "", wallets.Wallets[address].Path)
+
+	//锁定的加密钱包不能导入，解锁后导入的私钥加密保存
+	assert.Nil(t, wallets.Encrypt("secret"))
+	other := NewWallet()
+	_, err = wallets.ImportPrivateKey(other.PrivateKey)
+	assert.Equal(t, ErrWalletLocked, err)
+	assert.Nil(t, wallets.Unlock("secret"))
+	address, err = wallets.ImportPrivateKey(other.PrivateKey)
+	assert.Nil(t, err)
+	assert.Contains(t, wallets.Encryption.Keys, address)
+}
+
+func TestWatchOnly(t *testing.T) {
+	//冷钱包
+	cold := Wallets{Wallets: make(map[string]*Wallet)}
+	first := cold.CreateWallet()
+	second := cold.CreateWallet()
+	change := cold.NewChangeAddress()
+	account, err := cold.DeriveKey(hdAccountPath)
+	assert.Nil(t, err)
+
+	watching := Wallets{Wallets: make(map[string]*Wallet)}
+	watching.CreateWallet()
+	_, err = watching.ImportXpub(account.String(), nil, hdGapLimit)
+	assert.NotNil(t, err)
+
+	used := map[string]bool{second: true, change: true}
+	found, err := watching.ImportXpub(account.Neuter().String(), func(address string) bool { return used[address] }, hdGapLimit)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, found)
+	assert.True(t, watching.IsWatchOnly(second))
+	assert.True(t, watching.IsWatchOnly(change))
+	assert.False(t, watching.IsWatchOnly(first))
+	assert.Equal(t, "m/1/0", watching.WatchOnly[change].Path)
+	assert.Equal(t, cold.Wallets[change].PublicKey, watching.WatchOnly[change].PublicKey)
+	//外部链上下一个收款地址
+	assert.Equal(t, 3, len(watching.WatchOnly))
+	assert.Equal(t, uint32(2), watching.Xpubs[account.Neuter().String()].NextExternal)
+
+	assert.Nil(t, watching.ImportAddress(first))
+	assert.True(t, watching.IsWatchOnly(first))
+	assert.Equal(t, ErrWatchOnly, watching.CheckSpendable(first))
+	assert.Equal(t, ErrAddressInWallet, watching.ImportAddress(watching.GetAddresses()[0]))
+	assert.Nil(t, watching.CheckSpendable(watching.GetAddresses()[0]))
+}
+
+func TestNamedWallets(t *testing.T) {
+	assert.Equal(t, "3000", WalletID("3000", ""))
+	assert.Equal(t, "3000_alice", WalletID("3000", "alice"))
+
+	assert.True(t, ValidateWalletName(""))
+	assert.True(t, ValidateWalletName("cold-storage_2"))
+	assert.False(t, ValidateWalletName("../3001"))
+	assert.False(t, ValidateWalletName("a b"))
+}
+
+// 旧版本的钱包文件直接保存ecdsa私钥，加载时转换并按当前格式重写
+func TestLegacyWalletFile(t *testing.T) {
+	data, err := ioutil.ReadFile("wallet_btnode1.dat")
+	assert.Nil(t, err)
+	dir, err := ioutil.TempDir("", "wallet")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	cwd, err := os.Getwd()
+	assert.Nil(t, err)
+	assert.Nil(t, os.Chdir(dir))
+	defer os.Chdir(cwd)
+	assert.Nil(t, ioutil.WriteFile("wallet_legacy.dat", data, 0600))
+
+	wallets, err := NewWallets("legacy")
+	assert.Nil(t, err)
+	assert.Len(t, wallets.Wallets, 2)
+	assert.Contains(t, wallets.GetAddresses(), "1Pgp8fPP5fM1NkmV32qZgwSjrvebdP8Dka")
+	for address, wallet := range wallets.Wallets {
+		assert.Equal(t, address, string(wallet.GetAddress()))
+		hash := sha256.Sum256([]byte(address))
+		assert.True(t, verifySignature(wallet.PublicKey, signHash(wallet.PrivateKey, hash[:]), hash[:]))
+	}
+
+	rewritten, err := ioutil.ReadFile("wallet_legacy.dat")
+	assert.Nil(t, err)
+	assert.NotEqual(t, data, rewritten)
+	reloaded, err := NewWallets("legacy")
+	assert.Nil(t, err)
+	for address, wallet := range wallets.Wallets {
+		assert.Equal(t, wallet.PrivateKey.D, reloaded.Wallets[address].PrivateKey.D)
+	}
+}
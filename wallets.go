@@ -2,34 +2,118 @@ package main
 
 import (
 	"bytes"
+	"crypto/ecdsa"
 	"crypto/elliptic"
 	"encoding/gob"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math/big"
 	"os"
+	"path/filepath"
+	"regexp"
 )
 
 const walletFile = "wallet_%s.dat"
 
+// 命名钱包的名称
+var walletNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]*$`)
+
+// HD钱包账户的派生路径：外部链 m/0'/0/i 用于收款，内部链 m/0'/1/i 用于找零
+const hdAccountPath = "m/0'"
+
+// 恢复钱包时，连续这么多个地址未被使用即认为之后的地址也未被使用
+const hdGapLimit = 20
+
+// 随机生成的secp256k1密钥不由HD种子派生，助记词和种子无法恢复，不与HD地址放在同一个钱包中
+var ErrMixedKeyTypes = errors.New("secp256k1 keys are not derived from the HD seed, keep them in a separate wallet (-wallet NAME)")
+
 // 钱包
 type Wallets struct {
-	Wallets map[string]*Wallet
+	Wallets      map[string]*Wallet
+	Scripts      map[string][]byte             //P2SH地址 -> 赎回脚本
+	Seed         []byte                        //HD种子：新地址都由其派生，备份种子即可恢复
+	NextExternal uint32                        //外部链上下一个地址的序号
+	NextInternal uint32                        //内部链上下一个地址的序号
+	Encryption   *WalletEncryption             //加密的私钥和种子，未加密时为nil
+	WatchOnly    map[string]*WatchOnlyAddress  //只读地址：没有私钥，只跟踪余额和交易
+	Xpubs        map[string]*WatchedXpub       //跟踪的扩展公钥，其派生的地址加入只读地址
+	Transactions map[string]*WalletTransaction //txid -> 与钱包有关的交易
+	ScanTip      []byte                        //交易记录已扫描到的区块
+	Labels       map[string]string             //地址或txid -> 标签
+
+	encryptionKey []byte //解锁后由密码派生的密钥
+}
+
+// 钱包文件的ID：默认钱包为节点ID，命名钱包为 节点ID_名称，文件为 wallet_<ID>.dat
+func WalletID(nodeID, name string) string {
+	if name == "" {
+		return nodeID
+	}
+
+	return nodeID + "_" + name
+}
+
+// 钱包名称只能包含字母、数字、-和_，为空时为默认钱包
+func ValidateWalletName(name string) bool {
+	return walletNamePattern.MatchString(name)
+}
+
+// 钱包文件是否存在
+func walletExists(walletID string) bool {
+	_, err := os.Stat(fmt.Sprintf(walletFile, walletID))
+
+	return err == nil
 }
 
-// 从文件中加载钱包地址
-func NewWallets(nodeID string) (*Wallets, error) {
+// 从文件中加载钱包地址，walletID见WalletID
+func NewWallets(walletID string) (*Wallets, error) {
 	wallets := Wallets{}
 	wallets.Wallets = make(map[string]*Wallet)
+	wallets.Scripts = make(map[string][]byte)
+	wallets.WatchOnly = make(map[string]*WatchOnlyAddress)
+	wallets.Xpubs = make(map[string]*WatchedXpub)
+	wallets.Transactions = make(map[string]*WalletTransaction)
+	wallets.Labels = make(map[string]string)
 
-	err := wallets.LoadFromFile(nodeID)
+	err := wallets.LoadFromFile(walletID)
+	if err == nil {
+		err = wallets.unlockFromEnv()
+	}
 
 	return &wallets, err
 }
 
-// 创建新的钱包
+// 创建新的钱包：从HD种子的外部链派生下一个地址
 func (ws *Wallets) CreateWallet() string {
-	wallet := NewWallet()
+	address := ws.deriveAddress(0, ws.NextExternal)
+	ws.NextExternal++
+
+	return address
+}
+
+// 从HD种子的内部链派生下一个找零地址
+// 没有种子的secp256k1钱包返回空地址，找零给转出地址
+func (ws *Wallets) NewChangeAddress() string {
+	if ws.isSecp256k1Wallet() {
+		return ""
+	}
+	address := ws.deriveAddress(1, ws.NextInternal)
+	ws.NextInternal++
+
+	return address
+}
+
+// 派生账户中chain链上第index个地址并加入钱包；没有种子时先生成种子
+func (ws *Wallets) deriveAddress(chain, index uint32) string {
+	//锁定的加密钱包由账户扩展公钥派生地址
+	if !ws.HasSeed() {
+		if err := ws.initSeed(); err != nil {
+			log.Panic("ERROR: ", err)
+		}
+	}
+	wallet := ws.deriveWallet(chain, index)
 	address := fmt.Sprintf("%s", wallet.GetAddress())
 
 	ws.Wallets[address] = wallet
@@ -37,6 +121,158 @@ func (ws *Wallets) CreateWallet() string {
 	return address
 }
 
+// 派生账户中chain链上第index个地址的钱包
+func (ws *Wallets) deriveWallet(chain, index uint32) *Wallet {
+	path := fmt.Sprintf("%s/%d/%d", hdAccountPath, chain, index)
+	if ws.Seed == nil && ws.IsEncrypted() {
+		account, err := ParseExtendedKey(ws.Encryption.AccountXpub)
+		if err != nil {
+			log.Panic(err)
+		}
+		key, err := account.Derive(fmt.Sprintf("m/%d/%d", chain, index))
+		if err != nil {
+			log.Panic(err)
+		}
+		return &Wallet{ecdsa.PrivateKey{}, key.WalletPublicKey(), path}
+	}
+	key, err := ws.DeriveKey(path)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallet, err := newHDWallet(key, path)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return wallet
+}
+
+// 设置HD种子（如由助记词生成），已有种子的钱包不能更换
+func (ws *Wallets) SetSeed(seed []byte) error {
+	if ws.HasSeed() {
+		return errors.New("wallet already has an HD seed")
+	}
+	if ws.isSecp256k1Wallet() {
+		return ErrMixedKeyTypes
+	}
+	if ws.IsEncrypted() {
+		return ErrWalletEncrypted
+	}
+	if _, err := NewMasterKey(seed); err != nil {
+		return err
+	}
+	ws.Seed = seed
+
+	return nil
+}
+
+// 恢复地址：在外部链和内部链上依次派生，直到连续gapLimit个地址都未被使用，返回找回的地址数
+func (ws *Wallets) DiscoverAddresses(used func(address string) bool, gapLimit int) int {
+	var wallet *Wallet
+	derive := func(chain, index uint32) string {
+		wallet = ws.deriveWallet(chain, index)
+		return fmt.Sprintf("%s", wallet.GetAddress())
+	}
+
+	return discoverChains([]*uint32{&ws.NextExternal, &ws.NextInternal}, derive, used, gapLimit, func(address string) {
+		ws.Wallets[address] = wallet
+		ws.ScanTip = nil
+	})
+}
+
+// 在各条链上从next开始依次派生地址，直到连续gapLimit个地址都未被使用
+// 已使用的地址交给add，next更新为最后一个已使用地址的下一个序号；返回找到的地址数
+func discoverChains(next []*uint32, derive func(chain, index uint32) string, used func(address string) bool, gapLimit int, add func(address string)) int {
+	found := 0
+	for chain := range next {
+		for index, gap := *next[chain], 0; gap < gapLimit; index++ {
+			address := derive(uint32(chain), index)
+			if !used(address) {
+				gap++
+				continue
+			}
+
+			add(address)
+			*next[chain] = index + 1
+			gap = 0
+			found++
+		}
+	}
+
+	return found
+}
+
+// 由HD种子按路径派生扩展私钥
+func (ws *Wallets) DeriveKey(path string) (*ExtendedKey, error) {
+	if ws.Seed == nil {
+		return nil, errors.New("wallet has no HD seed")
+	}
+	master, err := NewMasterKey(ws.Seed)
+	if err != nil {
+		return nil, err
+	}
+
+	return master.Derive(path)
+}
+
+// 钱包是否有HD种子；加密的钱包锁定时只有账户扩展公钥
+func (ws *Wallets) HasSeed() bool {
+	return ws.Seed != nil || ws.IsEncrypted() && ws.Encryption.AccountXpub != ""
+}
+
+// 没有HD种子、保存随机secp256k1密钥的钱包，不再生成种子
+func (ws *Wallets) isSecp256k1Wallet() bool {
+	if ws.HasSeed() {
+		return false
+	}
+	for _, wallet := range ws.Wallets {
+		if walletKeyType(wallet.PublicKey) == KeyTypeSecp256k1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 创建指定密钥类型的地址：P-256由HD种子派生，secp256k1随机生成，只能在没有种子的钱包中创建
+func (ws *Wallets) CreateWalletOfType(keyType KeyType) (string, error) {
+	if keyType == KeyTypeP256 {
+		if ws.isSecp256k1Wallet() {
+			return "", ErrMixedKeyTypes
+		}
+		return ws.CreateWallet(), nil
+	}
+	if ws.HasSeed() {
+		return "", ErrMixedKeyTypes
+	}
+
+	return ws.ImportPrivateKey(NewWalletOfType(keyType).PrivateKey)
+}
+
+// 导入私钥，返回其地址；加密的钱包须已解锁，导入的私钥加密保存
+func (ws *Wallets) ImportPrivateKey(privKey ecdsa.PrivateKey) (string, error) {
+	wallet := NewWalletFromPrivateKey(privKey)
+	address := fmt.Sprintf("%s", wallet.GetAddress())
+	if existing, ok := ws.Wallets[address]; ok && !existing.IsLocked() {
+		return address, nil
+	}
+	if ws.IsLocked() {
+		return "", ErrWalletLocked
+	}
+
+	ws.Wallets[address] = wallet
+	if ws.IsEncrypted() {
+		if err := ws.sealKeys(); err != nil {
+			delete(ws.Wallets, address)
+			return "", err
+		}
+	}
+	//导入的私钥可能已有历史交易
+	ws.ScanTip = nil
+
+	return address, nil
+}
+
 //查询所有钱包地址
 func (ws *Wallets) GetAddresses() []string {
 	var addresses []string
@@ -48,14 +284,30 @@ func (ws *Wallets) GetAddresses() []string {
 	return addresses
 }
 
+// 保存赎回脚本，返回对应的P2SH地址
+func (ws *Wallets) AddScript(redeemScript []byte) string {
+	address := scriptAddress(redeemScript)
+
+	ws.Scripts[address] = redeemScript
+
+	return address
+}
+
+// byP2SH地址返回赎回脚本
+func (ws Wallets) GetScript(address string) ([]byte, bool) {
+	script, ok := ws.Scripts[address]
+
+	return script, ok
+}
+
 // by地址返回钱包
 func (ws Wallets) GetWallet(address string) Wallet {
 	return *ws.Wallets[address]
 }
 
 // 从本地文件中加载钱包
-func (ws *Wallets) LoadFromFile(nodeID string) error {
-	walletFile := fmt.Sprintf(walletFile, nodeID)
+func (ws *Wallets) LoadFromFile(walletID string) error {
+	walletFile := fmt.Sprintf(walletFile, walletID)
 	if _, err := os.Stat(walletFile); os.IsNotExist(err) {
 		return err
 	}
@@ -66,32 +318,134 @@ func (ws *Wallets) LoadFromFile(nodeID string) error {
 	}
 
 	var wallets Wallets
-	gob.Register(elliptic.P256())
 	decoder := gob.NewDecoder(bytes.NewReader(fileContent))
 	err = decoder.Decode(&wallets)
 	if err != nil {
-		log.Panic(err)
+		//旧版本的钱包文件直接保存ecdsa私钥，转换后按当前格式重写
+		legacy, legacyErr := decodeLegacyWallets(fileContent)
+		if legacyErr != nil {
+			log.Panic(err)
+		}
+		ws.Wallets = legacy
+		ws.SaveToFile(walletID)
+		return nil
 	}
 
 	ws.Wallets = wallets.Wallets
+	if wallets.Scripts != nil {
+		ws.Scripts = wallets.Scripts
+	}
+	ws.Seed = wallets.Seed
+	ws.Encryption = wallets.Encryption
+	if wallets.WatchOnly != nil {
+		ws.WatchOnly = wallets.WatchOnly
+	}
+	if wallets.Xpubs != nil {
+		ws.Xpubs = wallets.Xpubs
+	}
+	if wallets.Transactions != nil {
+		ws.Transactions = wallets.Transactions
+	}
+	ws.ScanTip = wallets.ScanTip
+	if wallets.Labels != nil {
+		ws.Labels = wallets.Labels
+	}
+	ws.NextExternal = wallets.NextExternal
+	ws.NextInternal = wallets.NextInternal
 
 	return nil
 }
 
-// 保存钱到文件：公私钥对和钱包地址
-func (ws Wallets) SaveToFile(nodeID string) {
-	var content bytes.Buffer
-	walletFile := fmt.Sprintf(walletFile, nodeID)
+// 旧版本钱包文件的格式：Wallets只有钱包，每个钱包直接保存ecdsa.PrivateKey
+// 解码时忽略曲线（均为P-256），只取坐标和私钥
+type legacyWallets struct {
+	Wallets map[string]*legacyWallet
+}
+
+type legacyWallet struct {
+	PrivateKey struct {
+		PublicKey struct {
+			X, Y *big.Int
+		}
+		D *big.Int
+	}
+	PublicKey []byte
+}
+
+// 解码旧版本的钱包文件；公钥按原样保留，旧版本未补齐的公钥与其地址保持一致
+func decodeLegacyWallets(fileContent []byte) (map[string]*Wallet, error) {
+	var legacy legacyWallets
+	err := gob.NewDecoder(bytes.NewReader(fileContent)).Decode(&legacy)
+	if err != nil {
+		return nil, err
+	}
+	if len(legacy.Wallets) == 0 {
+		return nil, errors.New("no wallets in the legacy wallet file")
+	}
 
-	gob.Register(elliptic.P256())
+	wallets := make(map[string]*Wallet)
+	for address, old := range legacy.Wallets {
+		key := old.PrivateKey
+		if key.D == nil || key.PublicKey.X == nil || key.PublicKey.Y == nil {
+			return nil, fmt.Errorf("legacy wallet %s has no private key", address)
+		}
+		privKey := ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: elliptic.P256(), X: key.PublicKey.X, Y: key.PublicKey.Y}, D: key.D}
+		wallet := &Wallet{privKey, old.PublicKey, ""}
+		if fmt.Sprintf("%s", wallet.GetAddress()) != address {
+			return nil, fmt.Errorf("legacy wallet %s does not match its public key", address)
+		}
+		wallets[address] = wallet
+	}
+
+	return wallets, nil
+}
+
+// 保存钱到文件：公私钥对和钱包地址；加密的钱包只保存加密的私钥和种子
+// 先写入临时文件再重命名，避免写到一半时损坏钱包；文件只有所有者可以读写
+func (ws *Wallets) SaveToFile(walletID string) {
+	var content bytes.Buffer
+	walletFile := fmt.Sprintf(walletFile, walletID)
 
+	data, err := ws.fileContent()
+	if err != nil {
+		log.Panic(err)
+	}
 	encoder := gob.NewEncoder(&content)
-	err := encoder.Encode(ws)
+	err = encoder.Encode(data)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	err = ioutil.WriteFile(walletFile, content.Bytes(), 0644)
+	//已存在的临时文件可能权限过宽，写入前收紧权限；写入磁盘后再重命名
+	tmpFile := walletFile + ".tmp"
+	f, err := os.OpenFile(tmpFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Panic(err)
+	}
+	err = f.Chmod(0600)
+	if err == nil {
+		_, err = f.Write(content.Bytes())
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Panic(err)
+	}
+	err = os.Rename(tmpFile, walletFile)
+	if err != nil {
+		log.Panic(err)
+	}
+	//同步目录，使重命名在断电后也保留
+	dir, err := os.Open(filepath.Dir(walletFile))
+	if err != nil {
+		log.Panic(err)
+	}
+	defer dir.Close()
+	err = dir.Sync()
 	if err != nil {
 		log.Panic(err)
 	}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+// 只读钱包：导入地址或扩展公钥，不需要私钥即可跟踪冷钱包的余额和交易
+// 只读地址不能直接转账，可以用createpst创建未签名的交易，到持有私钥的机器上签名
+
+// 只读地址的错误
+var (
+	ErrWatchOnly       = errors.New("address is watch-only, use createpst to build an unsigned transaction")
+	ErrAddressInWallet = errors.New("address is already in the wallet")
+)
+
+// 只读地址
+type WatchOnlyAddress struct {
+	PublicKey []byte //由扩展公钥派生的公钥，importaddress导入的地址为空
+	Xpub      string //派生该地址的扩展公钥
+	Path      string //相对于扩展公钥的派生路径，如 m/0/3
+}
+
+// 跟踪的扩展公钥：与HD钱包一样分外部链（0）和内部链（1）
+type WatchedXpub struct {
+	NextExternal uint32
+	NextInternal uint32
+}
+
+// 是否为只读地址
+func (ws *Wallets) IsWatchOnly(address string) bool {
+	_, ok := ws.WatchOnly[address]
+
+	return ok
+}
+
+// 导入只读地址
+func (ws *Wallets) ImportAddress(address string) error {
+	if !ValidateAddress(address) {
+		return fmt.Errorf("address %s is not valid", address)
+	}
+	if _, ok := ws.Wallets[address]; ok {
+		return ErrAddressInWallet
+	}
+	if ws.WatchOnly == nil {
+		ws.WatchOnly = make(map[string]*WatchOnlyAddress)
+	}
+	if _, ok := ws.WatchOnly[address]; !ok {
+		ws.WatchOnly[address] = &WatchOnlyAddress{}
+		ws.ScanTip = nil
+	}
+
+	return nil
+}
+
+// 导入扩展公钥：在其外部链和内部链上找回已使用的地址作为只读地址，并加入外部链上下一个收款地址
+// 再次导入同一个扩展公钥时从上次的位置继续查找；返回找到的已使用地址数
+func (ws *Wallets) ImportXpub(xpub string, used func(address string) bool, gapLimit int) (int, error) {
+	key, err := ParseExtendedKey(xpub)
+	if err != nil {
+		return 0, err
+	}
+	if key.Private {
+		return 0, errors.New("expected an extended public key, got a private one")
+	}
+	xpub = key.String()
+
+	if ws.WatchOnly == nil {
+		ws.WatchOnly = make(map[string]*WatchOnlyAddress)
+	}
+	if ws.Xpubs == nil {
+		ws.Xpubs = make(map[string]*WatchedXpub)
+	}
+	watched, ok := ws.Xpubs[xpub]
+	if !ok {
+		watched = &WatchedXpub{}
+	}
+
+	var entry *WatchOnlyAddress
+	derive := func(chain, index uint32) string {
+		path := fmt.Sprintf("m/%d/%d", chain, index)
+		child, err := key.Derive(path)
+		if err != nil {
+			log.Panic(err)
+		}
+		entry = &WatchOnlyAddress{child.WalletPublicKey(), xpub, path}
+		return fmt.Sprintf("%s", encodeAddress(version, HashPubKey(entry.PublicKey)))
+	}
+	add := func(address string) {
+		if _, ok := ws.Wallets[address]; !ok {
+			ws.WatchOnly[address] = entry
+			ws.ScanTip = nil
+		}
+	}
+	found := discoverChains([]*uint32{&watched.NextExternal, &watched.NextInternal}, derive, used, gapLimit, add)
+	add(derive(0, watched.NextExternal))
+	ws.Xpubs[xpub] = watched
+
+	return found, nil
+}
+
+// 只读地址的所有地址
+func (ws *Wallets) GetWatchOnlyAddresses() []string {
+	var addresses []string
+
+	for address := range ws.WatchOnly {
+		addresses = append(addresses, address)
+	}
+
+	return addresses
+}
+
+// 转出地址须为钱包中有私钥的地址
+func (ws *Wallets) CheckSpendable(address string) error {
+	if ws.IsWatchOnly(address) {
+		return ErrWatchOnly
+	}
+	if _, ok := ws.Wallets[address]; !ok {
+		return errors.New("address is not in the wallet")
+	}
+
+	return nil
+}